@@ -2,12 +2,15 @@ package main
 
 import (
 	"os"
+	"performance/internal/pkg/config"
 	"performance/internal/pkg/flags"
+	"performance/internal/pkg/tlsconfig"
 	"performance/pkg/cmpfeeds"
-	"performance/pkg/cmpnodestxspeed"
-	"performance/pkg/cmpnodestxspeedhttp"
 	"performance/pkg/cmptxspeed"
 	measuretxpropagationtime "performance/pkg/measure_tx_propagation_time"
+	"performance/pkg/race"
+	"performance/pkg/scenario"
+	"performance/pkg/senderpool"
 
 	"github.com/urfave/cli/v2"
 	"go.uber.org/zap"
@@ -15,7 +18,24 @@ import (
 )
 
 func main() {
-	app := &cli.App{
+	var app *cli.App
+
+	// run runs a command with the settings of the config file passed with --config.
+	run := func(args []string) error {
+		args, err := config.ExpandArgs(args)
+		if err != nil {
+			return err
+		}
+
+		return app.Run(append([]string{os.Args[0]}, args...))
+	}
+
+	scenarios := scenario.NewScenarioService(run)
+
+	senderPool := senderpool.NewSenderPoolService()
+	races := race.NewRaceService()
+
+	app = &cli.App{
 		Name:  "evmcompare",
 		Usage: "compares stream of txs/blocks from gateway vs node",
 		Commands: []*cli.Command{
@@ -25,9 +45,18 @@ func main() {
 				Flags: []cli.Flag{
 					flags.Gateway,
 					flags.FeedWSEndpoint,
+					flags.Source,
 					flags.TxFeedName,
 					flags.MinGasPrice,
 					flags.Addresses,
+					flags.FromAddresses,
+					flags.MethodIDs,
+					flags.MinValue,
+					flags.MaxValue,
+					flags.TxTypes,
+					flags.MinPriorityFee,
+					flags.ContractCreation,
+					flags.Filters,
 					flags.ExcludeTxContents,
 					flags.Interval,
 					flags.NumIntervals,
@@ -36,13 +65,25 @@ func main() {
 					flags.Dump,
 					flags.ExcludeDuplicates,
 					flags.TxIgnoreDelta,
+					flags.HistogramBuckets,
+					flags.MetricsAddr,
+					flags.Record,
 					flags.UseCloudAPI,
 					flags.Verbose,
 					flags.ExcludeFromBlockchain,
 					flags.CloudAPIWSURI,
 					flags.AuthHeader,
 					flags.UseGoGateway,
+					flags.CommandConfigFile,
+					flags.ReportFormat,
+					flags.ReportFile,
+					flags.TLSInsecureSkipVerify,
+					flags.TLSCAFile,
+					flags.TLSCertFile,
+					flags.TLSKeyFile,
+					flags.TLSServerName,
 				},
+				Before: tlsconfig.Configure,
 				Action: cmpfeeds.NewTxFeedsCompareService().Run,
 			},
 			{
@@ -53,18 +94,101 @@ func main() {
 					flags.FeedWSEndpoint,
 					flags.BkFeedName,
 					flags.ExcludeBkContents,
+					flags.VerifyBkContents,
+					flags.EthCall,
 					flags.Interval,
 					flags.NumIntervals,
 					flags.LeadTime,
 					flags.BkTrailTime,
 					flags.Dump,
 					flags.BkIgnoreDelta,
+					flags.HistogramBuckets,
+					flags.MetricsAddr,
+					flags.Record,
 					flags.UseCloudAPI,
 					flags.AuthHeader,
 					flags.CloudAPIWSURI,
+					flags.CommandConfigFile,
+					flags.ReportFormat,
+					flags.ReportFile,
+					flags.TLSInsecureSkipVerify,
+					flags.TLSCAFile,
+					flags.TLSCertFile,
+					flags.TLSKeyFile,
+					flags.TLSServerName,
 				},
+				Before: tlsconfig.Configure,
 				Action: cmpfeeds.NewBkFeedsCompareService().Run,
 			},
+			{
+				Name:  "replay",
+				Usage: "replays a recording of the transactions or blocks command",
+				Flags: []cli.Flag{
+					flags.Recording,
+					flags.ReplaySpeed,
+					flags.MinGasPrice,
+					flags.Addresses,
+					flags.FromAddresses,
+					flags.MethodIDs,
+					flags.MinValue,
+					flags.MaxValue,
+					flags.TxTypes,
+					flags.MinPriorityFee,
+					flags.ContractCreation,
+					flags.Interval,
+					flags.NumIntervals,
+					flags.LeadTime,
+					flags.TxTrailTime,
+					flags.Dump,
+					flags.TxIgnoreDelta,
+					flags.VerifyBkContents,
+					flags.HistogramBuckets,
+					flags.Verbose,
+					flags.CommandConfigFile,
+					flags.ReportFormat,
+					flags.ReportFile,
+				},
+				Action: cmpfeeds.NewReplayService().Run,
+			},
+			{
+				Name: "race",
+				Usage: "compares sending tx speed by submitting conflicting txs with the same nonce " +
+					"to any number of nodes and gateways, so only one tx will land on chain",
+				Flags: []cli.Flag{
+					flags.RaceEndpoint,
+					flags.StatusEndpoint,
+					flags.BXAuthHeader,
+					flags.NetworkName,
+					flags.SenderPrivateKey,
+					flags.SenderKeystore,
+					flags.SenderMnemonicFile,
+					flags.DerivationPath,
+					flags.SendersKeystoreDir,
+					flags.SendersMnemonicFile,
+					flags.NumSenders,
+					flags.SenderPasswordFile,
+					flags.MnemonicPassphrase,
+					flags.ChainID,
+					flags.NumTxGroups,
+					flags.GasPrice,
+					flags.TxType,
+					flags.MaxFee,
+					flags.MaxPriorityFee,
+					flags.Confirmations,
+					flags.ConfirmationTimeout,
+					flags.Delay,
+					flags.CommandConfigFile,
+					flags.ReportFormat,
+					flags.ReportFile,
+					flags.TLSInsecureSkipVerify,
+					flags.TLSCAFile,
+					flags.TLSCertFile,
+					flags.TLSKeyFile,
+					flags.TLSServerName,
+				},
+				Before: tlsconfig.Configure,
+				Action: races.Run,
+			},
 			{
 				Name: "txspeed",
 				Usage: "compares sending tx speed by submitting conflicting txs with the same nonce " +
@@ -74,13 +198,75 @@ func main() {
 					flags.BXEndpoint,
 					flags.BXAuthHeader,
 					flags.SenderPrivateKey,
+					flags.SenderKeystore,
+					flags.SenderMnemonicFile,
+					flags.DerivationPath,
+					flags.SendersKeystoreDir,
+					flags.SendersMnemonicFile,
+					flags.NumSenders,
+					flags.SenderPasswordFile,
+					flags.MnemonicPassphrase,
 					flags.ChainID,
 					flags.NumTxGroups,
 					flags.GasPrice,
+					flags.TxType,
+					flags.MaxFee,
+					flags.MaxPriorityFee,
+					flags.Confirmations,
+					flags.ConfirmationTimeout,
 					flags.Delay,
 					flags.NetworkName,
+					flags.CommandConfigFile,
+					flags.ReportFormat,
+					flags.ReportFile,
+					flags.TLSInsecureSkipVerify,
+					flags.TLSCAFile,
+					flags.TLSCertFile,
+					flags.TLSKeyFile,
+					flags.TLSServerName,
 				},
-				Action: cmptxspeed.NewTxSpeedCompareService().Run,
+				Before: tlsconfig.Configure,
+				Action: races.RunTxSpeed,
+			},
+			{
+				Name: "privatetxspeed",
+				Usage: "compares private transaction and bundle submission paths by racing txs with the same " +
+					"nonce across them, so only one tx will land on chain",
+				Flags: []cli.Flag{
+					flags.NodeWSEndpoint,
+					flags.BXEndpoint,
+					flags.BXAuthHeader,
+					flags.RelayEndpoint,
+					flags.RelaySigningKey,
+					flags.SubmissionPaths,
+					flags.MaxBlocks,
+					flags.SenderPrivateKey,
+					flags.SenderKeystore,
+					flags.SenderMnemonicFile,
+					flags.DerivationPath,
+					flags.SenderPasswordFile,
+					flags.MnemonicPassphrase,
+					flags.ChainID,
+					flags.NumTxGroups,
+					flags.GasPrice,
+					flags.TxType,
+					flags.MaxFee,
+					flags.MaxPriorityFee,
+					flags.Confirmations,
+					flags.ConfirmationTimeout,
+					flags.Delay,
+					flags.NetworkName,
+					flags.CommandConfigFile,
+					flags.ReportFormat,
+					flags.ReportFile,
+					flags.TLSInsecureSkipVerify,
+					flags.TLSCAFile,
+					flags.TLSCertFile,
+					flags.TLSKeyFile,
+					flags.TLSServerName,
+				},
+				Before: tlsconfig.Configure,
+				Action: cmptxspeed.NewPrivateTxSpeedCompareService().Run,
 			},
 			{
 				Name: "nodetxspeed",
@@ -90,12 +276,34 @@ func main() {
 					flags.NodeWSEndpoint,
 					flags.SecondNodeWSEndpoint,
 					flags.SenderPrivateKey,
+					flags.SenderKeystore,
+					flags.SenderMnemonicFile,
+					flags.DerivationPath,
+					flags.SendersKeystoreDir,
+					flags.SendersMnemonicFile,
+					flags.NumSenders,
+					flags.SenderPasswordFile,
+					flags.MnemonicPassphrase,
 					flags.ChainID,
 					flags.NumTxGroups,
 					flags.GasPrice,
+					flags.TxType,
+					flags.MaxFee,
+					flags.MaxPriorityFee,
+					flags.Confirmations,
+					flags.ConfirmationTimeout,
 					flags.Delay,
+					flags.CommandConfigFile,
+					flags.ReportFormat,
+					flags.ReportFile,
+					flags.TLSInsecureSkipVerify,
+					flags.TLSCAFile,
+					flags.TLSCertFile,
+					flags.TLSKeyFile,
+					flags.TLSServerName,
 				},
-				Action: cmpnodestxspeed.NewTxSpeedCompareService().Run,
+				Before: tlsconfig.Configure,
+				Action: races.RunNodeTxSpeed,
 			},
 			{
 				Name: "httpnodetxspeed",
@@ -105,12 +313,34 @@ func main() {
 					flags.NodeEndpoint,
 					flags.SecondNodeEndpoint,
 					flags.SenderPrivateKey,
+					flags.SenderKeystore,
+					flags.SenderMnemonicFile,
+					flags.DerivationPath,
+					flags.SendersKeystoreDir,
+					flags.SendersMnemonicFile,
+					flags.NumSenders,
+					flags.SenderPasswordFile,
+					flags.MnemonicPassphrase,
 					flags.ChainID,
 					flags.NumTxGroups,
 					flags.GasPrice,
+					flags.TxType,
+					flags.MaxFee,
+					flags.MaxPriorityFee,
+					flags.Confirmations,
+					flags.ConfirmationTimeout,
 					flags.Delay,
+					flags.CommandConfigFile,
+					flags.ReportFormat,
+					flags.ReportFile,
+					flags.TLSInsecureSkipVerify,
+					flags.TLSCAFile,
+					flags.TLSCertFile,
+					flags.TLSKeyFile,
+					flags.TLSServerName,
 				},
-				Action: cmpnodestxspeedhttp.NewTxSpeedCompareService().Run,
+				Before: tlsconfig.Configure,
+				Action: races.RunHTTPNodeTxSpeed,
 			},
 			{
 				Name: "measuretxpropagationtime",
@@ -120,20 +350,135 @@ func main() {
 					flags.NodeEndpoint,
 					flags.FeedWSEndpoint,
 					flags.SenderPrivateKey,
+					flags.SenderKeystore,
+					flags.SenderMnemonicFile,
+					flags.DerivationPath,
+					flags.SenderPasswordFile,
+					flags.MnemonicPassphrase,
 					flags.ChainID,
 					flags.TxCount,
 					flags.GasPrice,
+					flags.TxType,
+					flags.MaxFee,
+					flags.MaxPriorityFee,
+					flags.Confirmations,
+					flags.ConfirmationTimeout,
 					flags.Delay,
+					flags.CommandConfigFile,
+					flags.ReportFormat,
+					flags.ReportFile,
+					flags.TLSInsecureSkipVerify,
+					flags.TLSCAFile,
+					flags.TLSCertFile,
+					flags.TLSKeyFile,
+					flags.TLSServerName,
 				},
+				Before: tlsconfig.Configure,
 				Action: measuretxpropagationtime.NewMeasureTxPropagationTimeService().Run,
 			},
+			{
+				Name:  "senders",
+				Usage: "moves funds between the sender account and the senders of a pool",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "fund",
+						Usage: "tops up the senders of the pool to --amount from the sender account",
+						Flags: []cli.Flag{
+							flags.NodeWSEndpoint,
+							flags.SenderPrivateKey,
+							flags.SenderKeystore,
+							flags.SenderMnemonicFile,
+							flags.DerivationPath,
+							flags.SendersKeystoreDir,
+							flags.SendersMnemonicFile,
+							flags.NumSenders,
+							flags.SenderPasswordFile,
+							flags.MnemonicPassphrase,
+							flags.FundAmount,
+							flags.ChainID,
+							flags.GasPrice,
+							flags.TxType,
+							flags.MaxFee,
+							flags.MaxPriorityFee,
+							flags.Confirmations,
+							flags.ConfirmationTimeout,
+							flags.CommandConfigFile,
+							flags.ReportFormat,
+							flags.ReportFile,
+							flags.TLSInsecureSkipVerify,
+							flags.TLSCAFile,
+							flags.TLSCertFile,
+							flags.TLSKeyFile,
+							flags.TLSServerName,
+						},
+						Before: tlsconfig.Configure,
+						Action: senderPool.Fund,
+					},
+					{
+						Name:  "sweep",
+						Usage: "transfers the balances of the senders of the pool back to the sender account",
+						Flags: []cli.Flag{
+							flags.NodeWSEndpoint,
+							flags.SenderPrivateKey,
+							flags.SenderKeystore,
+							flags.SenderMnemonicFile,
+							flags.DerivationPath,
+							flags.SendersKeystoreDir,
+							flags.SendersMnemonicFile,
+							flags.NumSenders,
+							flags.SenderPasswordFile,
+							flags.MnemonicPassphrase,
+							flags.ChainID,
+							flags.GasPrice,
+							flags.TxType,
+							flags.MaxFee,
+							flags.MaxPriorityFee,
+							flags.Confirmations,
+							flags.ConfirmationTimeout,
+							flags.CommandConfigFile,
+							flags.ReportFormat,
+							flags.ReportFile,
+							flags.TLSInsecureSkipVerify,
+							flags.TLSCAFile,
+							flags.TLSCertFile,
+							flags.TLSKeyFile,
+							flags.TLSServerName,
+						},
+						Before: tlsconfig.Configure,
+						Action: senderPool.Sweep,
+					},
+				},
+			},
+			{
+				Name:  "scenario",
+				Usage: "runs the named scenarios of a config file",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "run",
+						Usage:     "runs the command of the scenario, flags after the name override the config file",
+						ArgsUsage: "<name> [flags]",
+						Flags: []cli.Flag{
+							flags.ConfigFile,
+						},
+						Action: scenarios.Run,
+					},
+					{
+						Name:  "list",
+						Usage: "lists the scenarios of the config file",
+						Flags: []cli.Flag{
+							flags.ConfigFile,
+						},
+						Action: scenarios.List,
+					},
+				},
+			},
 		},
 	}
 
 	log, _ := zap.NewDevelopment(zap.AddStacktrace(zapcore.ErrorLevel))
 	zap.ReplaceGlobals(log)
 
-	err := app.Run(os.Args)
+	err := run(os.Args[1:])
 	if err != nil {
 		log.Fatal("fatal", zap.Error(err))
 	}
@@ -0,0 +1,367 @@
+// Package config loads the endpoint profiles, the settings of the commands and the named
+// scenarios of evmcompare from a YAML or TOML file.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"performance/internal/pkg/flags"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// authFlags maps the endpoint flags to the flags of their authorization headers.
+var authFlags = map[string]string{
+	flags.Gateway.Name:       flags.AuthHeader.Name,
+	flags.CloudAPIWSURI.Name: flags.AuthHeader.Name,
+	flags.BXEndpoint.Name:    flags.BXAuthHeader.Name,
+}
+
+// Endpoint is a named endpoint profile. Type is 'evm' or 'bx' and is required when the
+// endpoint is used as a feed source.
+type Endpoint struct {
+	URI    string `yaml:"uri" toml:"uri"`
+	Auth   string `yaml:"auth" toml:"auth"`
+	Type   string `yaml:"type" toml:"type"`
+	Region string `yaml:"region" toml:"region"`
+}
+
+// Settings are the endpoints and the flags of a command. Endpoints maps the endpoint flags
+// of the command to the profiles, Sources lists the profiles compared by the feed commands,
+// and Flags holds the values of the other flags of the command.
+type Settings struct {
+	Endpoints map[string]string      `yaml:"endpoints" toml:"endpoints"`
+	Sources   []string               `yaml:"sources" toml:"sources"`
+	Flags     map[string]interface{} `yaml:"flags" toml:"flags"`
+}
+
+// Scenario is a named run of a command with its settings.
+type Scenario struct {
+	Command     string `yaml:"command" toml:"command"`
+	Description string `yaml:"description" toml:"description"`
+	Settings    `yaml:",inline"`
+}
+
+// Config holds the endpoint profiles, the settings of the commands keyed by the command
+// name, e.g. 'transactions' or 'senders fund', and the scenarios keyed by their names.
+type Config struct {
+	Endpoints map[string]*Endpoint `yaml:"endpoints" toml:"endpoints"`
+	Commands  map[string]*Settings `yaml:"commands" toml:"commands"`
+	Scenarios map[string]*Scenario `yaml:"scenarios" toml:"scenarios"`
+}
+
+// Load reads the config file, which format is chosen by its extension. References to
+// environment variables in the values, e.g. ${BLXR_AUTH_HEADER}, are expanded, so that
+// secrets are not stored in the file.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file %s: %v", path, err)
+	}
+
+	cfg := &Config{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return nil, fmt.Errorf("unsupported format %q of config file %s, possible values: "+
+			"'.yaml', '.yml', '.toml'", ext, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse config file %s: %v", path, err)
+	}
+
+	if err = cfg.expand(); err != nil {
+		return nil, fmt.Errorf("cannot expand config file %s: %v", path, err)
+	}
+
+	return cfg, nil
+}
+
+// ScenarioNames returns the sorted names of the scenarios.
+func (c *Config) ScenarioNames() []string {
+	names := make([]string, 0, len(c.Scenarios))
+	for name := range c.Scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Args returns the command line of the scenario: the command, which may be a subcommand
+// path such as 'senders fund', followed by its flags. The flags of the scenario are followed
+// by the settings of the command which the scenario does not set. The flags which are set in
+// overrides are omitted, and overrides are appended, so the command line takes precedence
+// over the scenario and the scenario over the command.
+func (c *Config) Args(name string, overrides []string) ([]string, error) {
+	s, ok := c.Scenarios[name]
+	if !ok || s == nil {
+		return nil, fmt.Errorf("unknown scenario %q, possible values: %s",
+			name, strings.Join(c.ScenarioNames(), ", "))
+	}
+
+	command := strings.Fields(s.Command)
+	if len(command) == 0 {
+		return nil, fmt.Errorf("command of scenario %q is not specified", name)
+	}
+
+	args, err := c.settingsArgs(&s.Settings, overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	if settings := c.Commands[strings.Join(command, " ")]; settings != nil {
+		defaults, err := c.settingsArgs(settings, append(args, overrides...))
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, defaults...)
+	}
+
+	return append(append(command, args...), overrides...), nil
+}
+
+// CommandArgs returns the command line of the command with the settings of the command from
+// the file: the flags of the settings which are not set in overrides followed by overrides.
+func (c *Config) CommandArgs(command string, overrides []string) ([]string, error) {
+	settings := c.Commands[command]
+	if settings == nil {
+		return overrides, nil
+	}
+
+	args, err := c.settingsArgs(settings, overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(args, overrides...), nil
+}
+
+// ExpandArgs inserts the settings of the command from the config file which is passed with
+// --config into the command line arguments, which start with the command name. The flags
+// of the command line take precedence over the file. The arguments are returned unchanged
+// if no config file is passed.
+func ExpandArgs(args []string) ([]string, error) {
+	path, ok := flagValue(args, flags.CommandConfigFile.Name)
+	if !ok {
+		return args, nil
+	}
+
+	var command []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			break
+		}
+		command = append(command, arg)
+	}
+
+	if len(command) == 0 {
+		return args, nil
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	expanded, err := cfg.CommandArgs(strings.Join(command, " "), args[len(command):])
+	if err != nil {
+		return nil, err
+	}
+
+	return append(command, expanded...), nil
+}
+
+// settingsArgs returns the flags of the settings which are not set in overrides.
+func (c *Config) settingsArgs(s *Settings, overrides []string) ([]string, error) {
+	var (
+		args       []string
+		overridden = flagNames(overrides)
+		set        = func(flag string, values ...string) {
+			if _, ok := overridden[flag]; ok {
+				return
+			}
+
+			for _, value := range values {
+				args = append(args, fmt.Sprintf("--%s=%s", flag, value))
+			}
+		}
+	)
+
+	for _, flag := range sortedKeys(s.Endpoints) {
+		e, err := c.endpoint(s.Endpoints[flag])
+		if err != nil {
+			return nil, err
+		}
+
+		set(flag, e.URI)
+		if auth, ok := authFlags[flag]; ok && e.Auth != "" {
+			set(auth, e.Auth)
+		}
+	}
+
+	var sources []string
+	for _, profile := range s.Sources {
+		e, err := c.endpoint(profile)
+		if err != nil {
+			return nil, err
+		}
+
+		source := fmt.Sprintf("%s=%s,type=%s", profile, e.URI, e.Type)
+		if e.Auth != "" {
+			source += ",auth=" + e.Auth
+		}
+		sources = append(sources, source)
+	}
+	set(flags.Source.Name, sources...)
+
+	keys := make([]string, 0, len(s.Flags))
+	for flag := range s.Flags {
+		keys = append(keys, flag)
+	}
+	sort.Strings(keys)
+
+	for _, flag := range keys {
+		switch value := s.Flags[flag].(type) {
+		case []interface{}:
+			for _, item := range value {
+				set(flag, fmt.Sprint(item))
+			}
+		default:
+			set(flag, fmt.Sprint(value))
+		}
+	}
+
+	return args, nil
+}
+
+func (c *Config) endpoint(name string) (*Endpoint, error) {
+	e, ok := c.Endpoints[name]
+	if !ok || e == nil {
+		return nil, fmt.Errorf("unknown endpoint profile %q", name)
+	}
+
+	if e.URI == "" {
+		return nil, fmt.Errorf("uri of endpoint profile %q is not specified", name)
+	}
+
+	return e, nil
+}
+
+func (c *Config) expand() error {
+	var missing []string
+	expand := func(s string) string {
+		return os.Expand(s, func(name string) string {
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				missing = append(missing, name)
+			}
+
+			return value
+		})
+	}
+
+	for _, e := range c.Endpoints {
+		if e == nil {
+			continue
+		}
+
+		e.URI, e.Auth, e.Type, e.Region = expand(e.URI), expand(e.Auth), expand(e.Type), expand(e.Region)
+	}
+
+	settings := make([]*Settings, 0, len(c.Commands)+len(c.Scenarios))
+	for _, s := range c.Commands {
+		settings = append(settings, s)
+	}
+	for _, s := range c.Scenarios {
+		if s != nil {
+			settings = append(settings, &s.Settings)
+		}
+	}
+
+	for _, s := range settings {
+		if s == nil {
+			continue
+		}
+
+		for flag, value := range s.Flags {
+			switch value := value.(type) {
+			case string:
+				s.Flags[flag] = expand(value)
+			case []interface{}:
+				for i, item := range value {
+					if str, ok := item.(string); ok {
+						value[i] = expand(str)
+					}
+				}
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("environment variables are not set: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// flagNames returns the names of the flags in the command line arguments.
+func flagNames(args []string) map[string]struct{} {
+	names := make(map[string]struct{})
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		if i := strings.Index(name, "="); i >= 0 {
+			name = name[:i]
+		}
+		names[name] = struct{}{}
+	}
+
+	return names
+}
+
+// flagValue returns the value of the flag in the command line arguments, which is passed
+// either as --flag=value or as --flag value.
+func flagValue(args []string, name string) (string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		flag := strings.TrimLeft(arg, "-")
+		if flag == name && i+1 < len(args) {
+			return args[i+1], true
+		}
+
+		if strings.HasPrefix(flag, name+"=") {
+			return flag[len(name)+1:], true
+		}
+	}
+
+	return "", false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testYAML = `
+endpoints:
+  gateway:
+    uri: wss://gateway.example.com/ws
+    auth: ${TEST_AUTH}
+    type: bx
+    region: eu
+  node:
+    uri: ws://127.0.0.1:8546
+    type: evm
+commands:
+  transactions:
+    endpoints:
+      gateway: gateway
+    flags:
+      interval: 60
+      exclude-tx-contents: false
+scenarios:
+  speed:
+    command: txspeed
+    endpoints:
+      blxr-endpoint: gateway
+      node-ws-endpoint: node
+    flags:
+      sender-private-key: $TEST_KEY
+      num-tx-groups: 5
+  feeds:
+    command: transactions
+    sources: [gateway, node]
+    flags:
+      exclude-tx-contents: true
+`
+
+const testTOML = `
+[endpoints.gateway]
+uri = "wss://gateway.example.com/ws"
+auth = "${TEST_AUTH}"
+type = "bx"
+
+[endpoints.node]
+uri = "ws://127.0.0.1:8546"
+type = "evm"
+
+[scenarios.speed]
+command = "txspeed"
+endpoints = { blxr-endpoint = "gateway", node-ws-endpoint = "node" }
+flags = { sender-private-key = "$TEST_KEY", num-tx-groups = 5 }
+`
+
+func writeConfig(t *testing.T, name, data string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestArgs(t *testing.T) {
+	t.Setenv("TEST_AUTH", "secret")
+	t.Setenv("TEST_KEY", "0xkey")
+
+	for _, name := range []string{"config.yaml", "config.toml"} {
+		data := testYAML
+		if filepath.Ext(name) == ".toml" {
+			data = testTOML
+		}
+
+		cfg, err := Load(writeConfig(t, name, data))
+		if err != nil {
+			t.Fatalf("cannot load %s: %v", name, err)
+		}
+
+		args, err := cfg.Args("speed", []string{"--num-tx-groups", "2"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []string{
+			"txspeed",
+			"--blxr-endpoint=wss://gateway.example.com/ws",
+			"--blxr-auth-header=secret",
+			"--node-ws-endpoint=ws://127.0.0.1:8546",
+			"--sender-private-key=0xkey",
+			"--num-tx-groups", "2",
+		}
+		if !reflect.DeepEqual(args, expected) {
+			t.Errorf("unexpected arguments from %s:\n%q\nexpected:\n%q", name, args, expected)
+		}
+	}
+}
+
+func TestArgsSources(t *testing.T) {
+	t.Setenv("TEST_AUTH", "secret")
+	t.Setenv("TEST_KEY", "0xkey")
+
+	cfg, err := Load(writeConfig(t, "config.yml", testYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := cfg.Args("feeds", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"transactions",
+		"--source=gateway=wss://gateway.example.com/ws,type=bx,auth=secret",
+		"--source=node=ws://127.0.0.1:8546,type=evm",
+		"--exclude-tx-contents=true",
+		"--gateway=wss://gateway.example.com/ws",
+		"--auth-header=secret",
+		"--interval=60",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("unexpected arguments:\n%q\nexpected:\n%q", args, expected)
+	}
+
+	if _, err := cfg.Args("unknown", nil); err == nil {
+		t.Error("expected an error for an unknown scenario")
+	}
+}
+
+func TestLoadMissingVariable(t *testing.T) {
+	t.Setenv("TEST_KEY", "0xkey")
+
+	if _, err := Load(writeConfig(t, "config.yaml", testYAML)); err == nil {
+		t.Error("expected an error for a missing environment variable")
+	}
+}
+
+func TestExpandArgs(t *testing.T) {
+	t.Setenv("TEST_AUTH", "secret")
+	t.Setenv("TEST_KEY", "0xkey")
+
+	path := writeConfig(t, "config.yaml", testYAML)
+
+	args, err := ExpandArgs([]string{"transactions", "--config", path, "--interval=10", "--dump", "ALL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"transactions",
+		"--gateway=wss://gateway.example.com/ws",
+		"--auth-header=secret",
+		"--exclude-tx-contents=false",
+		"--config", path, "--interval=10", "--dump", "ALL",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("unexpected arguments:\n%q\nexpected:\n%q", args, expected)
+	}
+
+	// The arguments are unchanged without a config file or settings of the command.
+	for _, unchanged := range [][]string{
+		{"transactions", "--interval=10"},
+		{"blocks", "--config=" + path, "--interval=10"},
+	} {
+		args, err := ExpandArgs(unchanged)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(args, unchanged) {
+			t.Errorf("expected arguments %q to be unchanged, got %q", unchanged, args)
+		}
+	}
+
+	if _, err := ExpandArgs([]string{"transactions", "--config", path + ".missing"}); err == nil {
+		t.Error("expected an error for a missing config file")
+	}
+}
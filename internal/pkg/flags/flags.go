@@ -11,9 +11,15 @@ var (
 	}
 	FeedWSEndpoint = &cli.StringFlag{
 		Name:  "feed-ws-endpoint",
-		Usage: "evm node websocket connection string",
+		Usage: "evm node websocket or IPC connection string",
 		Value: "ws://127.0.0.1:8546",
 	}
+	Source = &cli.StringSliceFlag{
+		Name: "source",
+		Usage: "feed source to compare, can be repeated to compare more than two feeds. " +
+			"Overrides --gateway and --feed-ws-endpoint. " +
+			"Sample Input: node1=ws://127.0.0.1:8546,type=evm[,auth=header]",
+	}
 	TxFeedName = &cli.StringFlag{
 		Name:  "feed-name",
 		Usage: "specify feed name, possible values: 'newTxs', 'pendingTxs', 'transactionStatus'",
@@ -21,7 +27,7 @@ var (
 	}
 	BkFeedName = &cli.StringFlag{
 		Name:  "feed-name",
-		Usage: "specify feed name, possible values: 'newBlocks', 'bdnBlocks'",
+		Usage: "specify feed name, possible values: 'newBlocks', 'bdnBlocks', 'txReceipts', 'ethOnBlock'",
 		Value: "bdnBlocks",
 	}
 	MinGasPrice = &cli.Float64Flag{
@@ -32,6 +38,40 @@ var (
 		Name:  "addresses",
 		Usage: "comma separated list of evm addresses",
 	}
+	FromAddresses = &cli.StringFlag{
+		Name:  "from-addresses",
+		Usage: "comma separated list of evm addresses of the transaction senders",
+	}
+	MethodIDs = &cli.StringFlag{
+		Name:  "method-ids",
+		Usage: "comma separated list of 4-byte selectors of the called methods. Sample Input: 0xa9059cbb,0x095ea7b3",
+	}
+	MinValue = &cli.Float64Flag{
+		Name:  "min-value",
+		Usage: "minimum transaction value in ether",
+	}
+	MaxValue = &cli.Float64Flag{
+		Name:  "max-value",
+		Usage: "maximum transaction value in ether",
+	}
+	TxTypes = &cli.StringFlag{
+		Name:  "tx-types",
+		Usage: "comma separated list of transaction types, possible values: 'legacy', 'access-list', 'dynamic'",
+	}
+	MinPriorityFee = &cli.Float64Flag{
+		Name:  "min-priority-fee",
+		Usage: "minimum max priority fee per gas in gigawei, the gas price of legacy and access list transactions",
+	}
+	ContractCreation = &cli.BoolFlag{
+		Name:  "contract-creation",
+		Usage: "compare only contract creation transactions",
+		Value: false,
+	}
+	Filters = &cli.StringFlag{
+		Name: "filters",
+		Usage: "filter expression of the gateway transaction feed, also applied to the transactions of the node. " +
+			"Sample Input: ({to} == '0x...') AND ({gas_price} > 30000000000)",
+	}
 	ExcludeTxContents = &cli.BoolFlag{
 		Name:  "exclude-tx-contents",
 		Usage: "optionally exclude tx contents",
@@ -42,6 +82,17 @@ var (
 		Usage: "optionally exclude block contents",
 		Value: false,
 	}
+	EthCall = &cli.StringSliceFlag{
+		Name: "call",
+		Usage: "call executed on every block by the ethOnBlock feed and by the node, can be repeated. " +
+			"Supported methods: eth_blockNumber, eth_getBalance, eth_getTransactionCount, eth_getCode, " +
+			"eth_getStorageAt, eth_call. Sample Input: balance=eth_getBalance,address=0x... (default: height=eth_blockNumber)",
+	}
+	VerifyBkContents = &cli.BoolFlag{
+		Name:  "verify-block-contents",
+		Usage: "compare the header fields, transactions and uncles of the blocks from gateway with the blocks of the node",
+		Value: false,
+	}
 	Interval = &cli.IntFlag{
 		Name:  "interval",
 		Usage: "length of feed sample interval in seconds",
@@ -86,6 +137,11 @@ var (
 		Usage: "ignore blocks with delta above this amount (seconds)",
 		Value: 5,
 	}
+	HistogramBuckets = &cli.StringFlag{
+		Name:  "histogram-buckets",
+		Usage: "comma separated bounds (ms) of the time difference histogram buckets",
+		Value: "-100,-50,-20,-10,-5,0,5,10,20,50,100",
+	}
 	UseCloudAPI = &cli.BoolFlag{
 		Name:  "use-cloud-api",
 		Usage: "use cloud API",
@@ -117,22 +173,22 @@ var (
 	}
 	NodeWSEndpoint = &cli.StringFlag{
 		Name:     "node-ws-endpoint",
-		Usage:    "evm node ws endpoint. Sample Input: ws://127.0.0.1:8546",
+		Usage:    "evm node endpoint over websocket, HTTP or IPC. Sample Input: ws://127.0.0.1:8546",
 		Required: true,
 	}
 	SecondNodeWSEndpoint = &cli.StringFlag{
 		Name:     "second-node-ws-endpoint",
-		Usage:    "evm ws endpoint. Sample Input: ws://127.0.0.1:8546",
+		Usage:    "evm endpoint over websocket, HTTP or IPC. Sample Input: ws://127.0.0.1:8546",
 		Required: false,
 	}
 	NodeEndpoint = &cli.StringFlag{
 		Name:     "node-endpoint",
-		Usage:    "evm node ws endpoint. Sample Input: http://127.0.0.1:8546",
+		Usage:    "evm node endpoint over HTTP, websocket or IPC. Sample Input: http://127.0.0.1:8546",
 		Required: true,
 	}
 	SecondNodeEndpoint = &cli.StringFlag{
 		Name:     "second-node-endpoint",
-		Usage:    "evm ws endpoint. Sample Input: http://127.0.0.1:8546",
+		Usage:    "evm endpoint over HTTP, websocket or IPC. Sample Input: http://127.0.0.1:8546",
 		Required: false,
 	}
 	BXEndpoint = &cli.StringFlag{
@@ -147,9 +203,76 @@ var (
 			"https://bloxroute.com/docs/bloxroute-documentation/cloud-api/overview/",
 	}
 	SenderPrivateKey = &cli.StringFlag{
-		Name:     "sender-private-key",
-		Usage:    "Sender's private key, which starts with 0x.",
-		Required: true,
+		Name:  "sender-private-key",
+		Usage: "Sender's private key, which starts with 0x.",
+	}
+	SenderKeystore = &cli.StringFlag{
+		Name:  "sender-keystore",
+		Usage: "JSON keystore file with the sender's key, decrypted with --sender-password-file or SENDER_PASSWORD.",
+	}
+	SenderMnemonicFile = &cli.StringFlag{
+		Name:  "sender-mnemonic-file",
+		Usage: "File with the BIP-39 mnemonic the sender's key is derived from, SENDER_MNEMONIC is used if no sender key is specified.",
+	}
+	DerivationPath = &cli.StringFlag{
+		Name:  "derivation-path",
+		Usage: "BIP-32 derivation path of the sender's key in the mnemonic.",
+		Value: "m/44'/60'/0'/0/0",
+	}
+	SendersKeystoreDir = &cli.StringFlag{
+		Name:  "senders-keystore-dir",
+		Usage: "Directory of JSON keystore files of a pool of senders which send groups concurrently, decrypted with --sender-password-file or SENDER_PASSWORD.",
+	}
+	SendersMnemonicFile = &cli.StringFlag{
+		Name:  "senders-mnemonic-file",
+		Usage: "File with the BIP-39 mnemonic a pool of --num-senders senders is derived from, at consecutive indices of --derivation-path.",
+	}
+	NumSenders = &cli.IntFlag{
+		Name:  "num-senders",
+		Usage: "Number of senders derived from --senders-mnemonic-file.",
+		Value: 1,
+	}
+	FundAmount = &cli.Float64Flag{
+		Name:  "amount",
+		Usage: "Balance in Coins each sender of the pool is topped up to.",
+	}
+	SenderPasswordFile = &cli.StringFlag{
+		Name:  "sender-password-file",
+		Usage: "File with the password of the keystores, SENDER_PASSWORD is used if not specified.",
+	}
+	MnemonicPassphrase = &cli.StringFlag{
+		Name:  "mnemonic-passphrase",
+		Usage: "BIP-39 passphrase of the sender's and the senders' mnemonic.",
+	}
+	RaceEndpoint = &cli.StringSliceFlag{
+		Name: "endpoint",
+		Usage: "submission endpoint of the race, repeated for every endpoint. The bloxroute endpoints use " +
+			"--network-name and --blxr-auth-header unless specified. " +
+			"Sample Input: gw=wss://api.blxrbdn.com/ws,type=evm-ws|evm-http|bloxroute[,network=Mainnet][,auth=header]",
+	}
+	StatusEndpoint = &cli.StringFlag{
+		Name:  "status-endpoint",
+		Usage: "evm node endpoint the nonces, fees and confirmations are checked with, the first evm endpoint if not specified.",
+	}
+	RelayEndpoint = &cli.StringFlag{
+		Name:  "relay-endpoint",
+		Usage: "Flashbots compatible relay endpoint for eth_sendBundle and eth_sendPrivateTransaction.",
+		Value: "https://relay.flashbots.net",
+	}
+	RelaySigningKey = &cli.StringFlag{
+		Name:  "relay-signing-key",
+		Usage: "Private key, which starts with 0x, used to sign relay requests. The sender's key is used if not specified.",
+	}
+	SubmissionPaths = &cli.StringSliceFlag{
+		Name: "paths",
+		Usage: "submission paths to race, possible values: 'blxr_tx', 'blxr_private_tx', 'blxr_submit_bundle', " +
+			"'eth_sendBundle', 'eth_sendPrivateTransaction', 'eth_sendRawTransaction'",
+		Value: cli.NewStringSlice("blxr_private_tx", "blxr_submit_bundle", "eth_sendBundle", "eth_sendPrivateTransaction"),
+	}
+	MaxBlocks = &cli.IntFlag{
+		Name:  "max-blocks",
+		Usage: "Number of blocks after submission bundles are targeted at and private transactions are valid for.",
+		Value: 3,
 	}
 	ChainID = &cli.IntFlag{
 		Name:  "chain-id",
@@ -172,13 +295,91 @@ var (
 		Value: 1,
 	}
 	GasPrice = &cli.Int64Flag{
-		Name:     "gas-price",
-		Usage:    "Transaction gas price in Gwei.",
-		Required: true,
+		Name:  "gas-price",
+		Usage: "Transaction gas price in Gwei, required for legacy transactions.",
+	}
+	TxType = &cli.StringFlag{
+		Name:  "tx-type",
+		Usage: "Type of the sent transactions, possible values: 'legacy', 'dynamic'.",
+		Value: "legacy",
+	}
+	MaxFee = &cli.Float64Flag{
+		Name:  "max-fee",
+		Usage: "Max fee per gas of dynamic fee transactions in Gwei, derived from eth_feeHistory if not specified.",
+	}
+	MaxPriorityFee = &cli.Float64Flag{
+		Name:  "max-priority-fee",
+		Usage: "Max priority fee per gas of dynamic fee transactions in Gwei, derived from eth_feeHistory or eth_maxPriorityFeePerGas if not specified.",
+	}
+	Confirmations = &cli.IntFlag{
+		Name:  "confirmations",
+		Usage: "Number of blocks, including the one with the transaction, after which a transaction is confirmed.",
+		Value: 1,
+	}
+	ConfirmationTimeout = &cli.IntFlag{
+		Name:  "confirmation-timeout",
+		Usage: "Time (sec) to wait for the transactions to be confirmed after the last one was sent.",
+		Value: 300,
 	}
 	Delay = &cli.IntFlag{
 		Name:  "delay",
 		Usage: "Time (sec) to sleep between two consecutive groups.",
 		Value: 30,
 	}
+	ReportFormat = &cli.StringFlag{
+		Name:  "report-format",
+		Usage: "format of the results report, possible values: 'text', 'json'",
+		Value: "text",
+	}
+	ReportFile = &cli.StringFlag{
+		Name:  "report-file",
+		Usage: "file to write the results report to, stdout is used if not specified",
+	}
+	TLSInsecureSkipVerify = &cli.BoolFlag{
+		Name:  "tls-insecure-skip-verify",
+		Usage: "do not verify the certificates of wss and https endpoints",
+	}
+	TLSCAFile = &cli.StringFlag{
+		Name:  "tls-ca-file",
+		Usage: "PEM bundle of the certificate authorities to trust instead of the system ones",
+	}
+	TLSCertFile = &cli.StringFlag{
+		Name:  "tls-cert-file",
+		Usage: "PEM client certificate for mutual TLS, requires --tls-key-file",
+	}
+	TLSKeyFile = &cli.StringFlag{
+		Name:  "tls-key-file",
+		Usage: "PEM private key of the client certificate for mutual TLS",
+	}
+	TLSServerName = &cli.StringSliceFlag{
+		Name: "tls-server-name",
+		Usage: "server name to send with SNI and verify in the certificate instead of the host of an endpoint, " +
+			"can be repeated for every endpoint. Sample Input: 10.0.0.5:443=gateway.example.com",
+	}
+	ConfigFile = &cli.StringFlag{
+		Name:  "config",
+		Usage: "YAML or TOML file with the endpoint profiles and the scenarios",
+		Value: "evmcompare.yaml",
+	}
+	CommandConfigFile = &cli.StringFlag{
+		Name:  "config",
+		Usage: "YAML or TOML file with the endpoint profiles and the settings of the commands, the flags of the command line override the file",
+	}
+	MetricsAddr = &cli.StringFlag{
+		Name:  "metrics-addr",
+		Usage: "address to serve Prometheus metrics at /metrics, disabled if not specified. Sample Input: :9090",
+	}
+	Record = &cli.StringFlag{
+		Name:  "record",
+		Usage: "directory to record the received messages to, so the comparison can be replayed with the replay command",
+	}
+	Recording = &cli.StringFlag{
+		Name:     "recording",
+		Usage:    "recording of the transactions or blocks command to replay",
+		Required: true,
+	}
+	ReplaySpeed = &cli.Float64Flag{
+		Name:  "replay-speed",
+		Usage: "speed of the replay relative to the recorded timing, 0 replays the messages as fast as possible",
+	}
 )
@@ -0,0 +1,92 @@
+// Package inclusion tracks how fast transactions sent by the tx speed commands are
+// included in a block and confirmed.
+package inclusion
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Inclusion describes the transaction of a group of conflicting transactions which was
+// included in a block. Reorgs counts how many times an earlier inclusion of the group was
+// dropped by a reorg.
+type Inclusion struct {
+	Endpoint          string    `json:"endpoint"`
+	TxHash            string    `json:"txHash"`
+	SubmissionBlock   uint64    `json:"submissionBlock"`
+	BlockNumber       uint64    `json:"blockNumber"`
+	BlockHash         string    `json:"blockHash"`
+	BlockTime         time.Time `json:"blockTime"`
+	BlocksToInclusion uint64    `json:"blocksToInclusion"`
+	TimeToReceiptMs   int64     `json:"timeToReceiptMs"`
+	TxIndex           uint64    `json:"txIndex"`
+	Reorgs            int       `json:"reorgs,omitempty"`
+}
+
+// Stats holds the inclusion latency of the transactions sent to a single endpoint.
+type Stats struct {
+	Included             int     `json:"included"`
+	AvgBlocksToInclusion float64 `json:"avgBlocksToInclusion"`
+	AvgTimeToReceiptMs   float64 `json:"avgTimeToReceiptMs"`
+	AvgTxIndex           float64 `json:"avgTxIndex"`
+}
+
+// block is a block with the transaction objects.
+type block struct {
+	Number       hexutil.Uint64 `json:"number"`
+	Hash         string         `json:"hash"`
+	ParentHash   string         `json:"parentHash"`
+	Timestamp    hexutil.Uint64 `json:"timestamp"`
+	Transactions []struct {
+		Hash  string         `json:"hash"`
+		From  common.Address `json:"from"`
+		Nonce hexutil.Uint64 `json:"nonce"`
+	} `json:"transactions"`
+}
+
+// Aggregate computes the inclusion stats per endpoint.
+func Aggregate(results map[int]*Inclusion) map[string]*Stats {
+	stats := make(map[string]*Stats)
+	for _, inc := range results {
+		s, ok := stats[inc.Endpoint]
+		if !ok {
+			s = &Stats{}
+			stats[inc.Endpoint] = s
+		}
+
+		s.Included++
+		s.AvgBlocksToInclusion += float64(inc.BlocksToInclusion)
+		s.AvgTimeToReceiptMs += float64(inc.TimeToReceiptMs)
+		s.AvgTxIndex += float64(inc.TxIndex)
+	}
+
+	for _, s := range stats {
+		s.AvgBlocksToInclusion /= float64(s.Included)
+		s.AvgTimeToReceiptMs /= float64(s.Included)
+		s.AvgTxIndex /= float64(s.Included)
+	}
+
+	return stats
+}
+
+// Format returns the human readable inclusion stats of the endpoints.
+func Format(stats map[string]*Stats) string {
+	endpoints := make([]string, 0, len(stats))
+	for endpoint := range stats {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	var res string
+	for _, endpoint := range endpoints {
+		s := stats[endpoint]
+		res += fmt.Sprintf("Inclusion for %s: %d txs, avg %.2f blocks, avg %.0f ms to receipt, avg tx index %.1f\n",
+			endpoint, s.Included, s.AvgBlocksToInclusion, s.AvgTimeToReceiptMs, s.AvgTxIndex)
+	}
+
+	return res
+}
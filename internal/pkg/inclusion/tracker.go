@@ -0,0 +1,499 @@
+package inclusion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"performance/internal/pkg/rpc"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxReorgDepth bounds how far back the tracker follows the parents of a new block to find
+// the blocks replaced by a reorg, and how many recent blocks it remembers.
+const maxReorgDepth = 64
+
+type headNotification struct {
+	Params struct {
+		Result struct {
+			Hash string `json:"hash"`
+		} `json:"result"`
+	} `json:"params"`
+}
+
+// account identifies a nonce of a sender.
+type account struct {
+	sender common.Address
+	nonce  uint64
+}
+
+// observation is a transaction of a sender seen in a canonical block.
+type observation struct {
+	txHash string
+	block  *block
+	index  int
+	seen   time.Time
+}
+
+// group is a group of conflicting transactions with the same sender and nonce.
+type group struct {
+	account
+	// txs holds the endpoints keyed by the lower case transaction hash.
+	txs       map[string]string
+	head      uint64
+	submitted time.Time
+	inclusion *Inclusion
+	reorgs    int
+	resolved  bool
+}
+
+// Tracker follows the chain head and resolves a group of conflicting transactions as soon
+// as its nonce is consumed by a block which reached the confirmation depth. Inclusions
+// dropped by a reorg are discarded until the group is included again.
+type Tracker struct {
+	client  rpc.Client
+	senders map[common.Address]struct{}
+	depth   uint64
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+	changed chan struct{}
+
+	mu        sync.Mutex
+	groups    map[int]*group
+	canonical map[uint64]string
+	observed  map[account]*observation
+	head      uint64
+}
+
+// NewTracker creates a tracker of the transactions of the senders. A transaction is
+// confirmed when the head is depth - 1 blocks past the block including it.
+func NewTracker(client rpc.Client, senders []string, depth int) *Tracker {
+	if depth < 1 {
+		depth = 1
+	}
+
+	set := make(map[common.Address]struct{}, len(senders))
+	for _, sender := range senders {
+		set[common.HexToAddress(sender)] = struct{}{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Tracker{
+		client:    client,
+		senders:   set,
+		depth:     uint64(depth),
+		ctx:       ctx,
+		cancel:    cancel,
+		changed:   make(chan struct{}, 1),
+		groups:    make(map[int]*group),
+		canonical: make(map[uint64]string),
+		observed:  make(map[account]*observation),
+	}
+}
+
+// Follow starts following the head with the newHeads subscription if the client supports
+// subscriptions, or by polling the latest block with the interval otherwise or if the
+// subscription fails.
+func (t *Tracker) Follow(interval time.Duration) error {
+	if sub, ok := t.client.(rpc.Subscriber); ok {
+		err := t.Subscribe(sub, interval)
+		if err == nil {
+			return nil
+		}
+
+		log.Errorf("%v, polling the latest block every %s", err, interval)
+	}
+
+	t.Poll(interval)
+
+	return nil
+}
+
+// Subscribe starts following the head with the newHeads subscription of the client. If the
+// subscription fails later on, the head is followed by polling the latest block with the
+// interval, so the tracked groups are still resolved.
+func (t *Tracker) Subscribe(client rpc.Subscriber, interval time.Duration) error {
+	sub, err := client.SubscribeBkFeedEvm()
+	if err != nil {
+		return fmt.Errorf("cannot subscribe to new heads: %v", err)
+	}
+
+	heads := make(chan string)
+	go func() {
+		defer close(heads)
+
+		for {
+			data, err := sub.NextMessage()
+			if err != nil {
+				if t.ctx.Err() == nil {
+					log.Errorf("cannot read new heads: %v, polling the latest block every %s", err, interval)
+					t.poll(heads, interval)
+				}
+				return
+			}
+
+			var msg headNotification
+			if err := json.Unmarshal(data, &msg); err != nil {
+				log.Errorf("cannot parse new head: %v", err)
+				continue
+			}
+
+			select {
+			case heads <- msg.Params.Result.Hash:
+			case <-t.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		<-t.ctx.Done()
+		if err := sub.Unsubscribe(); err != nil {
+			log.Debugf("cannot unsubscribe from new heads: %v", err)
+		}
+	}()
+
+	t.start(heads)
+
+	return nil
+}
+
+// Poll starts following the head by requesting the latest block with the interval, for
+// clients which do not support subscriptions.
+func (t *Tracker) Poll(interval time.Duration) {
+	heads := make(chan string)
+	go func() {
+		defer close(heads)
+		t.poll(heads, interval)
+	}()
+
+	t.start(heads)
+}
+
+// poll sends the hash of the latest block to heads whenever it changes, until the tracker
+// is stopped.
+func (t *Tracker) poll(heads chan<- string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last string
+	for {
+		hash, err := t.latestHash()
+		if err != nil {
+			log.Errorf("cannot get latest block: %v", err)
+		} else if hash != last {
+			last = hash
+
+			select {
+			case heads <- hash:
+			case <-t.ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Track starts tracking the group of transactions of the sender with the nonce, keyed by
+// the endpoint they were sent to. Head is the latest block when the transactions were
+// submitted.
+func (t *Tracker) Track(id int, sender string, nonce uint64, txs map[string]string, head uint64, submitted time.Time) {
+	g := &group{
+		account:   account{sender: common.HexToAddress(sender), nonce: nonce},
+		txs:       make(map[string]string, len(txs)),
+		head:      head,
+		submitted: submitted,
+	}
+	for endpoint, txHash := range txs {
+		g.txs[strings.ToLower(txHash)] = endpoint
+	}
+
+	t.mu.Lock()
+	t.groups[id] = g
+	// The nonce may be consumed before the group is tracked.
+	if o, ok := t.observed[g.account]; ok {
+		t.include(id, g, o)
+		t.confirm()
+	}
+	t.mu.Unlock()
+
+	t.notify()
+}
+
+// Pending returns the number of tracked groups which were not resolved yet.
+func (t *Tracker) Pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var pending int
+	for _, g := range t.groups {
+		if !g.resolved {
+			pending++
+		}
+	}
+
+	return pending
+}
+
+// Wait waits until all tracked groups are resolved or the timeout passes, and reports if
+// all of them were resolved.
+func (t *Tracker) Wait(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for t.Pending() > 0 {
+		select {
+		case <-timer.C:
+			return false
+		case <-t.changed:
+		}
+	}
+
+	return true
+}
+
+// Stop stops following the head and returns the confirmed inclusions by group.
+func (t *Tracker) Stop() map[int]*Inclusion {
+	t.cancel()
+	if t.done != nil {
+		<-t.done
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res := make(map[int]*Inclusion)
+	for id, g := range t.groups {
+		if g.resolved && g.inclusion != nil {
+			res[id] = g.inclusion
+		}
+	}
+
+	return res
+}
+
+func (t *Tracker) start(heads <-chan string) {
+	t.done = make(chan struct{})
+
+	go func() {
+		defer close(t.done)
+
+		for {
+			select {
+			case <-t.ctx.Done():
+				return
+			case hash, ok := <-heads:
+				if !ok {
+					return
+				}
+
+				b, err := t.blockByHash(hash)
+				if err != nil {
+					log.Errorf("cannot get block %s: %v", hash, err)
+					continue
+				}
+
+				t.mu.Lock()
+				t.process(b, 0)
+				t.confirm()
+				t.mu.Unlock()
+
+				t.notify()
+			}
+		}
+	}()
+}
+
+// process makes the block the head. The blocks which were skipped since the previous head
+// are processed first, and so are the parents of the block which replace known blocks.
+func (t *Tracker) process(b *block, depth int) {
+	number := uint64(b.Number)
+	if t.canonical[number] == b.Hash {
+		return
+	}
+
+	if from := t.next(); depth == 0 && from != 0 && number > from {
+		if number-from > maxReorgDepth {
+			from = number - maxReorgDepth
+		}
+
+		for n := from; n < number; n++ {
+			skipped, err := t.blockByNumber(n)
+			if err != nil {
+				log.Errorf("cannot get block %d: %v", n, err)
+				continue
+			}
+
+			t.process(skipped, depth+1)
+		}
+	}
+
+	if parent, ok := t.canonical[number-1]; ok && number > 0 && parent != b.ParentHash && depth < maxReorgDepth {
+		p, err := t.blockByHash(b.ParentHash)
+		if err != nil {
+			log.Errorf("cannot get block %s: %v", b.ParentHash, err)
+		} else {
+			t.process(p, depth+1)
+		}
+	}
+
+	t.rollback(number)
+	t.canonical[number] = b.Hash
+	t.head = number
+
+	// Heads may skip numbers, so every block too deep to be replaced is dropped.
+	for n := range t.canonical {
+		if n+maxReorgDepth <= number {
+			delete(t.canonical, n)
+		}
+	}
+
+	for i, tx := range b.Transactions {
+		if _, ok := t.senders[tx.From]; !ok {
+			continue
+		}
+
+		var (
+			key = account{sender: tx.From, nonce: uint64(tx.Nonce)}
+			o   = &observation{txHash: tx.Hash, block: b, index: i, seen: time.Now()}
+		)
+		t.observed[key] = o
+
+		for id, g := range t.groups {
+			if g.account == key && g.inclusion == nil && !g.resolved {
+				t.include(id, g, o)
+			}
+		}
+	}
+}
+
+// next returns the first block which was not processed yet. Before the first head, it is
+// the block after the earliest submission, or 0 if nothing is tracked.
+func (t *Tracker) next() uint64 {
+	if t.head != 0 {
+		return t.head + 1
+	}
+
+	var from uint64
+	for _, g := range t.groups {
+		if !g.resolved && (from == 0 || g.head+1 < from) {
+			from = g.head + 1
+		}
+	}
+
+	return from
+}
+
+// rollback discards the known blocks from the number on, which were replaced by a reorg.
+func (t *Tracker) rollback(number uint64) {
+	if _, ok := t.canonical[number]; !ok {
+		return
+	}
+
+	for n := range t.canonical {
+		if n >= number {
+			delete(t.canonical, n)
+		}
+	}
+
+	for key, o := range t.observed {
+		if uint64(o.block.Number) >= number {
+			delete(t.observed, key)
+		}
+	}
+
+	for id, g := range t.groups {
+		if g.inclusion != nil && !g.resolved && g.inclusion.BlockNumber >= number {
+			log.Warnf("inclusion of group %d in block %s was dropped by a reorg", id, g.inclusion.BlockHash)
+
+			g.inclusion = nil
+			g.reorgs++
+		}
+	}
+}
+
+// include records the inclusion of the group. A group whose nonce was consumed by a
+// transaction which was not sent by the benchmark is resolved without an inclusion.
+func (t *Tracker) include(id int, g *group, o *observation) {
+	endpoint, ok := g.txs[strings.ToLower(o.txHash)]
+	if !ok {
+		log.Warnf("nonce %d of group %d was consumed by transaction %s which was not sent by the benchmark",
+			g.nonce, id, o.txHash)
+
+		g.resolved = true
+		return
+	}
+
+	g.inclusion = &Inclusion{
+		Endpoint:        endpoint,
+		TxHash:          o.txHash,
+		SubmissionBlock: g.head,
+		BlockNumber:     uint64(o.block.Number),
+		BlockHash:       o.block.Hash,
+		BlockTime:       time.Unix(int64(o.block.Timestamp), 0).UTC(),
+		TimeToReceiptMs: o.seen.Sub(g.submitted).Milliseconds(),
+		TxIndex:         uint64(o.index),
+		Reorgs:          g.reorgs,
+	}
+
+	if g.inclusion.BlockNumber > g.head {
+		g.inclusion.BlocksToInclusion = g.inclusion.BlockNumber - g.head
+	}
+}
+
+// confirm resolves the included groups which reached the confirmation depth.
+func (t *Tracker) confirm() {
+	for _, g := range t.groups {
+		if g.inclusion != nil && !g.resolved && t.head+1 >= g.inclusion.BlockNumber+t.depth {
+			g.resolved = true
+		}
+	}
+}
+
+func (t *Tracker) notify() {
+	select {
+	case t.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (t *Tracker) blockByHash(hash string) (*block, error) {
+	var b block
+	if err := rpc.Result(t.client, &b, "eth_getBlockByHash", hash, true); err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+func (t *Tracker) blockByNumber(number uint64) (*block, error) {
+	var b block
+	if err := rpc.Result(t.client, &b, "eth_getBlockByNumber", hexutil.EncodeUint64(number), true); err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+func (t *Tracker) latestHash() (string, error) {
+	var head struct {
+		Hash string `json:"hash"`
+	}
+	if err := rpc.Result(t.client, &head, "eth_getBlockByNumber", "latest", false); err != nil {
+		return "", err
+	}
+
+	return head.Hash, nil
+}
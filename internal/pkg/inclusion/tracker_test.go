@@ -0,0 +1,209 @@
+package inclusion
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"performance/internal/pkg/mock"
+	"performance/internal/pkg/rpc"
+	"performance/internal/pkg/ws"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const testPrivateKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+func hash(i int) string {
+	return fmt.Sprintf("0x%064x", i)
+}
+
+func signedTx(t *testing.T, nonce uint64, data byte) (*types.Transaction, string) {
+	key, err := crypto.HexToECDSA(testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		chain = big.NewInt(1)
+		to    = common.Address{}
+	)
+
+	tx, err := types.SignTx(types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: big.NewInt(1),
+		Gas:      21000,
+		To:       &to,
+		Value:    big.NewInt(0),
+		Data:     []byte{data},
+	}), types.NewLondonSigner(chain), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tx, crypto.PubkeyToAddress(key.PublicKey).Hex()
+}
+
+func subscribe(t *testing.T, node *mock.Node, tracker *Tracker) {
+	conn, err := ws.NewConnection(node.WSURL(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	tracker.client = conn
+	if err := tracker.Follow(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := node.WaitSubscriptions(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTrackerReorg(t *testing.T) {
+	node := mock.NewNode()
+	defer node.Close()
+
+	tx, sender := signedTx(t, 0, 1)
+	tracker := NewTracker(nil, []string{sender}, 2)
+	subscribe(t, node, tracker)
+
+	tracker.Track(1, sender, 0, map[string]string{"a": tx.Hash().Hex()}, 0, time.Now())
+
+	node.AnnounceBlock(mock.Block{Hash: hash(1), Number: 1, Txs: []*types.Transaction{tx}})
+	// The block including the transaction is replaced before it is confirmed.
+	node.AnnounceBlock(mock.Block{Hash: hash(2), Number: 1, Txs: []*types.Transaction{}})
+	node.AnnounceBlock(mock.Block{Hash: hash(3), Number: 2, ParentHash: hash(2), Txs: []*types.Transaction{tx}})
+	node.AnnounceBlock(mock.Block{Hash: hash(4), Number: 3, ParentHash: hash(3), Txs: []*types.Transaction{}})
+
+	if !tracker.Wait(5 * time.Second) {
+		t.Fatalf("expected the group to be resolved, %d pending", tracker.Pending())
+	}
+
+	inc := tracker.Stop()[1]
+	if inc == nil {
+		t.Fatal("expected the group to be confirmed")
+	}
+
+	if inc.Endpoint != "a" || inc.BlockHash != hash(3) || inc.BlocksToInclusion != 2 || inc.Reorgs != 1 {
+		t.Errorf("unexpected inclusion: %+v", inc)
+	}
+}
+
+func TestTrackerForeignNonce(t *testing.T) {
+	node := mock.NewNode()
+	defer node.Close()
+
+	var (
+		tx, sender = signedTx(t, 0, 1)
+		foreign, _ = signedTx(t, 0, 2)
+		tracker    = NewTracker(nil, []string{sender}, 1)
+	)
+	subscribe(t, node, tracker)
+
+	tracker.Track(1, sender, 0, map[string]string{"a": tx.Hash().Hex()}, 0, time.Now())
+	node.AnnounceBlock(mock.Block{Hash: hash(1), Number: 1, Txs: []*types.Transaction{foreign}})
+
+	if !tracker.Wait(5 * time.Second) {
+		t.Fatalf("expected the group to be resolved, %d pending", tracker.Pending())
+	}
+
+	if res := tracker.Stop(); len(res) != 0 {
+		t.Errorf("expected no inclusions, got %+v", res[1])
+	}
+}
+
+func TestTrackerPoll(t *testing.T) {
+	node := mock.NewNode()
+	defer node.Close()
+
+	tx, sender := signedTx(t, 0, 1)
+	node.NewHead()
+
+	tracker := NewTracker(rpc.NewHTTPClient(node.HTTPURL(), ""), []string{sender}, 1)
+	if err := tracker.Follow(10 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	defer tracker.Stop()
+
+	tracker.Track(1, sender, 0, map[string]string{"a": tx.Hash().Hex()}, 1, time.Now())
+
+	// The block with the transaction may be skipped by the polls.
+	node.Mine(tx, 2)
+	for i := 0; i < 3; i++ {
+		node.NewHead()
+	}
+
+	if !tracker.Wait(5 * time.Second) {
+		t.Fatalf("expected the group to be resolved, %d pending", tracker.Pending())
+	}
+
+	if inc := tracker.Stop()[1]; inc == nil || inc.BlockNumber != 2 || inc.BlocksToInclusion != 1 {
+		t.Errorf("unexpected inclusion: %+v", inc)
+	}
+}
+
+func TestTrackerSubscriptionFallback(t *testing.T) {
+	node := mock.NewNode()
+	defer node.Close()
+
+	tx, sender := signedTx(t, 0, 1)
+	node.NewHead()
+
+	conn, err := ws.NewConnection(node.WSURL(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := NewTracker(rpc.NewHTTPClient(node.HTTPURL(), ""), []string{sender}, 1)
+	if err := tracker.Subscribe(conn, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	defer tracker.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := node.WaitSubscriptions(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The subscription fails, the head is polled from then on.
+	_ = conn.Close()
+
+	tracker.Track(1, sender, 0, map[string]string{"a": tx.Hash().Hex()}, 1, time.Now())
+
+	node.Mine(tx, 2)
+	for i := 0; i < 3; i++ {
+		node.NewHead()
+	}
+
+	if !tracker.Wait(5 * time.Second) {
+		t.Fatalf("expected the group to be resolved, %d pending", tracker.Pending())
+	}
+
+	if inc := tracker.Stop()[1]; inc == nil || inc.BlockNumber != 2 {
+		t.Errorf("unexpected inclusion: %+v", inc)
+	}
+}
+
+func TestTrackerPrunesSkippedHeights(t *testing.T) {
+	tracker := NewTracker(nil, nil, 1)
+	for n := uint64(1); n <= 10; n++ {
+		tracker.canonical[n] = hash(int(n))
+	}
+
+	// The head jumps far ahead, none of the known blocks can be replaced anymore.
+	tracker.process(&block{Number: 100, Hash: hash(100), ParentHash: hash(99)}, 0)
+
+	if len(tracker.canonical) != 1 || tracker.canonical[100] != hash(100) {
+		t.Errorf("expected only the head to be kept, got %v", tracker.canonical)
+	}
+}
@@ -0,0 +1,203 @@
+// Package keys loads the private key of the sender of the benchmark transactions from a
+// raw hex key, a JSON keystore or a BIP-39 mnemonic. The key is never printed or logged.
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"performance/internal/pkg/flags"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+	"github.com/urfave/cli/v2"
+)
+
+// Environment variables which are used when the corresponding files are not specified.
+const (
+	PasswordEnv = "SENDER_PASSWORD"
+	MnemonicEnv = "SENDER_MNEMONIC"
+)
+
+// FromFlags loads the sender key from exactly one of the raw key, the keystore and the
+// mnemonic. The SENDER_MNEMONIC environment variable is used only if none of the key flags
+// is specified. The keystore password is read from the password file or the SENDER_PASSWORD
+// environment variable, the mnemonic is derived with --mnemonic-passphrase.
+func FromFlags(c *cli.Context) (*ecdsa.PrivateKey, error) {
+	var (
+		rawKey       = c.String(flags.SenderPrivateKey.Name)
+		keystoreFile = c.String(flags.SenderKeystore.Name)
+		mnemonic     string
+		sources      []string
+	)
+
+	if rawKey != "" {
+		sources = append(sources, "--"+flags.SenderPrivateKey.Name)
+	}
+
+	if keystoreFile != "" {
+		sources = append(sources, "--"+flags.SenderKeystore.Name)
+	}
+
+	if file := c.String(flags.SenderMnemonicFile.Name); file != "" {
+		data, err := readSecret(file)
+		if err != nil {
+			return nil, err
+		}
+		mnemonic = data
+		sources = append(sources, "--"+flags.SenderMnemonicFile.Name)
+	}
+
+	if len(sources) == 0 {
+		if mnemonic = os.Getenv(MnemonicEnv); mnemonic == "" {
+			return nil, fmt.Errorf("error: one of --%s, --%s, --%s or %s is required",
+				flags.SenderPrivateKey.Name, flags.SenderKeystore.Name, flags.SenderMnemonicFile.Name, MnemonicEnv)
+		}
+	}
+
+	if len(sources) > 1 {
+		return nil, fmt.Errorf("error: only one sender key can be specified, got %s", strings.Join(sources, ", "))
+	}
+
+	switch {
+	case rawKey != "":
+		return ParsePrivateKey(rawKey)
+	case keystoreFile != "":
+		password, err := Password(c)
+		if err != nil {
+			return nil, err
+		}
+
+		return FromKeystore(keystoreFile, password)
+	default:
+		return FromMnemonic(mnemonic, c.String(flags.MnemonicPassphrase.Name), c.String(flags.DerivationPath.Name))
+	}
+}
+
+// Password reads the password from the password file or the SENDER_PASSWORD environment
+// variable.
+func Password(c *cli.Context) (string, error) {
+	if file := c.String(flags.SenderPasswordFile.Name); file != "" {
+		return readSecret(file)
+	}
+
+	return os.Getenv(PasswordEnv), nil
+}
+
+// ParsePrivateKey parses a hex private key, which starts with 0x. The error does not
+// contain the key.
+func ParsePrivateKey(key string) (*ecdsa.PrivateKey, error) {
+	if !strings.HasPrefix(key, "0x") && !strings.HasPrefix(key, "0X") {
+		return nil, fmt.Errorf("error: private key must start with 0x")
+	}
+
+	if len(key) != 66 {
+		return nil, fmt.Errorf("error: private key must be 32 bytes long, got %d hex characters", len(key)-2)
+	}
+
+	res, err := crypto.HexToECDSA(key[2:])
+	if err != nil {
+		return nil, fmt.Errorf("error: private key is not a valid secp256k1 hex key")
+	}
+
+	return res, nil
+}
+
+// FromKeystore decrypts the key of the go-ethereum JSON keystore file.
+func FromKeystore(file, password string) (*ecdsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read keystore %s: %v", file, err)
+	}
+
+	key, err := keystore.DecryptKey(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt keystore %s: %v", file, err)
+	}
+
+	return key.PrivateKey, nil
+}
+
+// FromMnemonic derives the key at the BIP-32 path from the BIP-39 mnemonic.
+func FromMnemonic(mnemonic, passphrase, path string) (*ecdsa.PrivateKey, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, fmt.Errorf("error: mnemonic is not a valid BIP-39 mnemonic")
+	}
+
+	derivationPath, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse derivation path %s: %v", path, err)
+	}
+
+	seed := bip39.NewSeed(mnemonic, passphrase)
+
+	return derive(seed, derivationPath)
+}
+
+// derive derives the private key of the BIP-32 path from the seed.
+func derive(seed []byte, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	var (
+		n              = crypto.S256().Params().N
+		sum            = hmacSHA512([]byte("Bitcoin seed"), seed)
+		key, chainCode = new(big.Int).SetBytes(sum[:32]), sum[32:]
+	)
+
+	for _, index := range path {
+		var data []byte
+		if index >= 0x80000000 {
+			data = append([]byte{0}, math.PaddedBigBytes(key, 32)...)
+		} else {
+			parent, err := crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+			if err != nil {
+				return nil, fmt.Errorf("cannot derive key: %v", err)
+			}
+			data = crypto.CompressPubkey(&parent.PublicKey)
+		}
+		var buf [4]byte
+		binary.BigEndian.PutUint32(buf[:], index)
+		data = append(data, buf[:]...)
+
+		sum = hmacSHA512(chainCode, data)
+		tweak := new(big.Int).SetBytes(sum[:32])
+		if tweak.Cmp(n) >= 0 {
+			return nil, fmt.Errorf("cannot derive key: invalid child at index %d", index)
+		}
+
+		key = tweak.Add(tweak, key).Mod(tweak, n)
+		chainCode = sum[32:]
+	}
+
+	res, err := crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+	if err != nil {
+		return nil, fmt.Errorf("cannot derive key: %v", err)
+	}
+
+	return res, nil
+}
+
+func hmacSHA512(key, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
+
+// readSecret reads a secret from the file without the trailing line break.
+func readSecret(file string) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("cannot read %s: %v", file, err)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,163 @@
+package keys
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"performance/internal/pkg/flags"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	testKey      = "0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+	testAddress  = "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"
+	testMnemonic = "test test test test test test test test test test test junk"
+)
+
+func TestParsePrivateKey(t *testing.T) {
+	key, err := ParsePrivateKey(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if addr := crypto.PubkeyToAddress(key.PublicKey).Hex(); addr != testAddress {
+		t.Errorf("expected address %s, got %s", testAddress, addr)
+	}
+
+	for _, invalid := range []string{testKey[2:], testKey[:64], "0x" + strings.Repeat("zz", 32)} {
+		_, err := ParsePrivateKey(invalid)
+		if err == nil {
+			t.Errorf("expected an error for %q", invalid)
+			continue
+		}
+
+		if strings.Contains(err.Error(), invalid[2:10]) {
+			t.Errorf("error contains the key: %v", err)
+		}
+	}
+}
+
+func TestFromMnemonic(t *testing.T) {
+	key, err := FromMnemonic(testMnemonic, "", "m/44'/60'/0'/0/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if addr := crypto.PubkeyToAddress(key.PublicKey).Hex(); addr != testAddress {
+		t.Errorf("expected address %s, got %s", testAddress, addr)
+	}
+
+	if _, err := FromMnemonic("test test test", "", "m/44'/60'/0'/0/0"); err == nil {
+		t.Error("expected an error for an invalid mnemonic")
+	}
+}
+
+func TestFromKeystore(t *testing.T) {
+	key, err := ParsePrivateKey(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ks := keystore.NewKeyStore(t.TempDir(), keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.ImportECDSA(key, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := account.URL.Path
+
+	decrypted, err := FromKeystore(file, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !decrypted.Equal(key) {
+		t.Error("decrypted key does not match")
+	}
+
+	if _, err = FromKeystore(file, "wrong"); err == nil {
+		t.Error("expected an error for a wrong password")
+	}
+}
+
+func newContext(t *testing.T, args ...string) *cli.Context {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range []cli.Flag{
+		flags.SenderPrivateKey,
+		flags.SenderKeystore,
+		flags.SenderMnemonicFile,
+		flags.DerivationPath,
+		flags.SenderPasswordFile,
+		flags.MnemonicPassphrase,
+	} {
+		if err := f.Apply(set); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := set.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	return cli.NewContext(nil, set, nil)
+}
+
+func TestFromFlagsMnemonicEnv(t *testing.T) {
+	const (
+		otherKey     = "0x59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d"
+		otherAddress = "0x70997970C51812dc3A010C7d01b50e0d17dc79C8"
+	)
+
+	t.Setenv(MnemonicEnv, testMnemonic)
+	t.Setenv(PasswordEnv, "keystore password")
+
+	// The environment variable is used when no key flag is specified, the keystore
+	// password is not the passphrase of the mnemonic.
+	key, err := FromFlags(newContext(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if addr := crypto.PubkeyToAddress(key.PublicKey).Hex(); addr != testAddress {
+		t.Errorf("expected address %s from %s, got %s", testAddress, MnemonicEnv, addr)
+	}
+
+	key, err = FromFlags(newContext(t, "--mnemonic-passphrase", "passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if addr := crypto.PubkeyToAddress(key.PublicKey).Hex(); addr == testAddress {
+		t.Errorf("expected another address with --mnemonic-passphrase, got %s", addr)
+	}
+
+	// A key flag takes precedence over the environment variable.
+	key, err = FromFlags(newContext(t, "--sender-private-key", otherKey))
+	if err != nil {
+		t.Fatalf("unexpected error with %s set: %v", MnemonicEnv, err)
+	}
+
+	if addr := crypto.PubkeyToAddress(key.PublicKey).Hex(); addr != otherAddress {
+		t.Errorf("expected address %s from the flag, got %s", otherAddress, addr)
+	}
+
+	// Conflicting flags are reported by the names of the flags which were passed.
+	mnemonicFile := filepath.Join(t.TempDir(), "mnemonic")
+	if err = ioutil.WriteFile(mnemonicFile, []byte(testMnemonic), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = FromFlags(newContext(t, "--sender-private-key", otherKey, "--sender-mnemonic-file", mnemonicFile))
+	if err == nil || !strings.Contains(err.Error(), "--sender-private-key, --sender-mnemonic-file") {
+		t.Errorf("expected an error naming both flags, got %v", err)
+	}
+
+	_, err = FromFlags(newContext(t, "--sender-private-key", otherKey, "--sender-keystore", mnemonicFile))
+	if err == nil || strings.Contains(err.Error(), "mnemonic") {
+		t.Errorf("expected an error naming only the passed flags, got %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+const namespace = "bdn_feed"
+
+var (
+	registry = prometheus.NewRegistry()
+
+	seen = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "hashes_seen_total",
+		Help:      "Number of hashes received from a source.",
+	}, []string{"feed", "source"})
+	firstSeen = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "first_seen_total",
+		Help:      "Number of hashes received from a source before any other source.",
+	}, []string{"feed", "source"})
+	missing = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "missing_total",
+		Help:      "Number of hashes received from other sources but never from this source.",
+	}, []string{"feed", "source"})
+	lowFeeIgnored = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "low_fee_ignored_total",
+		Help:      "Number of transactions ignored due to a low gas price.",
+	}, []string{"feed"})
+	highDeltaIgnored = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "high_delta_ignored_total",
+		Help:      "Number of hashes received by a pair of sources too far apart to be compared.",
+	}, []string{"feed"})
+	delta = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "delta_seconds",
+		Help:      "Time a hash was received from source a minus the time it was received from source b.",
+		Buckets:   []float64{-0.1, -0.05, -0.02, -0.01, -0.005, 0, 0.005, 0.01, 0.02, 0.05, 0.1},
+	}, []string{"feed", "a", "b"})
+	connected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "connected",
+		Help:      "Whether the subscription to a source is currently established.",
+	}, []string{"feed", "source"})
+	reconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconnects_total",
+		Help:      "Number of times the subscription to a source was re-established after an outage.",
+	}, []string{"feed", "source"})
+	queueDelay = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "queue_delay_seconds",
+		Help:      "Time a message waited for the handler after it was read from the socket.",
+		Buckets:   prometheus.ExponentialBuckets(0.00001, 4, 10),
+	}, []string{"feed"})
+	queueLength = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_length",
+		Help:      "Number of messages waiting for the handler.",
+	}, []string{"feed"})
+	blocked = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reader_blocked_seconds_total",
+		Help:      "Time the reader of a source waited for room in the full queue of the handler.",
+	}, []string{"feed", "source"})
+	contentsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "contents_dropped_total",
+		Help:      "Number of hashes whose contents were not requested from a source because its contents queue was full.",
+	}, []string{"feed", "source"})
+	reorgDepth = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "reorg_depth_blocks",
+		Help:      "Number of blocks replaced by a reorg of the chain announced by a source.",
+		Buckets:   []float64{1, 2, 3, 5, 10, 20, 50},
+	}, []string{"feed", "source"})
+)
+
+func init() {
+	registry.MustRegister(
+		seen,
+		firstSeen,
+		missing,
+		lowFeeIgnored,
+		highDeltaIgnored,
+		delta,
+		connected,
+		reconnects,
+		queueDelay,
+		queueLength,
+		blocked,
+		contentsDropped,
+		reorgDepth,
+	)
+}
+
+// Serve exposes the metrics at the /metrics path of addr until ctx is done.
+func Serve(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Errorf("cannot shut down metrics server: %v", err)
+		}
+	}()
+
+	go func() {
+		log.Infof("Serving metrics at http://%s/metrics", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("cannot serve metrics at %s: %v", addr, err)
+		}
+	}()
+}
+
+// Feed records the metrics of a single feed comparison, e.g. "transactions" or "blocks".
+type Feed struct {
+	name string
+}
+
+// NewFeed creates metrics of the feed comparison with the given name.
+func NewFeed(name string) *Feed {
+	return &Feed{name: name}
+}
+
+// Seen records a hash received from the source.
+func (f *Feed) Seen(source string) {
+	seen.WithLabelValues(f.name, source).Inc()
+}
+
+// FirstSeen records a hash the source delivered before any other source.
+func (f *Feed) FirstSeen(source string) {
+	firstSeen.WithLabelValues(f.name, source).Inc()
+}
+
+// Missing records a hash which was never received from the source.
+func (f *Feed) Missing(source string) {
+	missing.WithLabelValues(f.name, source).Inc()
+}
+
+// LowFeeIgnored records a transaction ignored due to a low gas price.
+func (f *Feed) LowFeeIgnored() {
+	lowFeeIgnored.WithLabelValues(f.name).Inc()
+}
+
+// HighDeltaIgnored records a hash received by a pair of sources too far apart.
+func (f *Feed) HighDeltaIgnored() {
+	highDeltaIgnored.WithLabelValues(f.name).Inc()
+}
+
+// Delta records the time difference between a hash received from sources a and b.
+func (f *Feed) Delta(a, b string, d time.Duration) {
+	delta.WithLabelValues(f.name, a, b).Observe(d.Seconds())
+}
+
+// Connected records the state of the subscription to the source.
+func (f *Feed) Connected(source string, ok bool) {
+	value := 0.0
+	if ok {
+		value = 1
+	}
+
+	connected.WithLabelValues(f.name, source).Set(value)
+}
+
+// Reconnected records the subscription to the source being re-established after an outage.
+func (f *Feed) Reconnected(source string) {
+	reconnects.WithLabelValues(f.name, source).Inc()
+}
+
+// QueueDelay records the time a message waited for the handler and the number of messages
+// left in the queue.
+func (f *Feed) QueueDelay(d time.Duration, length int) {
+	queueDelay.WithLabelValues(f.name).Observe(d.Seconds())
+	queueLength.WithLabelValues(f.name).Set(float64(length))
+}
+
+// Blocked records the time the reader of the source waited for room in the queue.
+func (f *Feed) Blocked(source string, d time.Duration) {
+	blocked.WithLabelValues(f.name, source).Add(d.Seconds())
+}
+
+// ContentsDropped records a hash whose contents were not requested from the source because
+// its contents queue was full.
+func (f *Feed) ContentsDropped(source string) {
+	contentsDropped.WithLabelValues(f.name, source).Inc()
+}
+
+// Reorg records a reorg of the chain announced by the source which replaced depth blocks.
+func (f *Feed) Reorg(source string, depth int) {
+	reorgDepth.WithLabelValues(f.name, source).Observe(float64(depth))
+}
@@ -0,0 +1,52 @@
+package mock
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"performance/internal/pkg/flags"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+// RunCommand runs the action in the background as a command with the given flags. Once the
+// command completes, the aggregate of its JSON report is decoded into aggregate and the
+// result is sent to the returned channel.
+func RunCommand(
+	t testing.TB,
+	action cli.ActionFunc,
+	cmdFlags []cli.Flag,
+	aggregate interface{},
+	args ...string,
+) <-chan error {
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	app := &cli.App{
+		Commands: []*cli.Command{{
+			Name:   "test",
+			Flags:  append(cmdFlags, flags.ReportFormat, flags.ReportFile),
+			Action: action,
+		}},
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		args = append([]string{"evmcompare", "test", "--report-format", "json", "--report-file", reportFile}, args...)
+		if err := app.Run(args); err != nil {
+			done <- err
+			return
+		}
+
+		data, err := ioutil.ReadFile(reportFile)
+		if err != nil {
+			done <- err
+			return
+		}
+
+		done <- json.Unmarshal(data, &struct {
+			Aggregate interface{} `json:"aggregate"`
+		}{aggregate})
+	}()
+
+	return done
+}
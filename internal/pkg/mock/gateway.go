@@ -0,0 +1,242 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Gateway is a mock bloXroute gateway serving the websocket API.
+type Gateway struct {
+	srv *server
+
+	mu               sync.Mutex
+	responseDelay    time.Duration
+	onRawTransaction func(tx *types.Transaction)
+	sent             []*types.Transaction
+	options          map[string]map[string]interface{}
+}
+
+// NewGateway starts a mock bloXroute gateway.
+func NewGateway() *Gateway {
+	g := &Gateway{options: make(map[string]map[string]interface{})}
+	g.srv = newServer("subscribe", g.handle, g.delay)
+
+	return g
+}
+
+// URL returns the websocket endpoint of the gateway.
+func (g *Gateway) URL() string {
+	return g.srv.wsURL()
+}
+
+// Close stops the gateway.
+func (g *Gateway) Close() {
+	g.srv.close()
+}
+
+// DropConnections closes all websocket connections to the gateway.
+func (g *Gateway) DropConnections() {
+	g.srv.dropConnections()
+}
+
+// WaitSubscriptions waits until count subscriptions were made to the gateway.
+func (g *Gateway) WaitSubscriptions(ctx context.Context, count int) error {
+	return g.srv.waitSubscriptions(ctx, count)
+}
+
+// SetResponseDelay delays every response of the gateway, e.g. to script an outage which
+// lasts until the subscription is re-established.
+func (g *Gateway) SetResponseDelay(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.responseDelay = d
+}
+
+func (g *Gateway) delay() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.responseDelay
+}
+
+// OnRawTransaction sets a callback which is called for every transaction sent to the
+// gateway with blxr_tx, blxr_private_tx or blxr_submit_bundle.
+func (g *Gateway) OnRawTransaction(fn func(tx *types.Transaction)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.onRawTransaction = fn
+}
+
+// AnnounceTx notifies the newTxs and pendingTxs subscribers.
+func (g *Gateway) AnnounceTx(tx Tx) {
+	g.srv.notify(map[string]interface{}{
+		"txHash":     tx.Hash,
+		"txContents": tx.contents(),
+	}, "newTxs", "pendingTxs")
+}
+
+// AnnounceBlock notifies the newBlocks and bdnBlocks subscribers. The block is sent with
+// its transactions and uncles.
+func (g *Gateway) AnnounceBlock(b Block) {
+	uncles := make([]interface{}, 0, len(b.Uncles))
+	for _, hash := range b.Uncles {
+		uncles = append(uncles, map[string]interface{}{"hash": hash})
+	}
+
+	g.srv.notify(map[string]interface{}{
+		"hash":         b.Hash,
+		"header":       b.header(),
+		"transactions": b.contents(true)["transactions"],
+		"uncles":       uncles,
+	}, "newBlocks", "bdnBlocks")
+}
+
+// AnnounceTxStatus notifies the transactionStatus subscribers.
+func (g *Gateway) AnnounceTxStatus(hash, status string) {
+	g.srv.notify(map[string]interface{}{
+		"txHash": hash,
+		"status": status,
+	}, "transactionStatus")
+}
+
+// AnnounceReceipts notifies the txReceipts subscribers of the receipts of the transactions
+// of the block, one message per receipt.
+func (g *Gateway) AnnounceReceipts(b Block) {
+	for _, receipt := range b.receipts() {
+		g.srv.notify(map[string]interface{}{"receipt": receipt}, "txReceipts")
+	}
+}
+
+// AnnounceCall notifies the ethOnBlock subscribers of the response of the named call
+// executed on the block with the given number.
+func (g *Gateway) AnnounceCall(name string, number uint64, response string) {
+	g.srv.notify(map[string]interface{}{
+		"name":        name,
+		"response":    response,
+		"blockHeight": hexutil.EncodeUint64(number),
+		"tag":         hexutil.EncodeUint64(number),
+	}, "ethOnBlock")
+}
+
+// SubscribeOptions returns the options of the last subscription to the feed.
+func (g *Gateway) SubscribeOptions(feed string) map[string]interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.options[feed]
+}
+
+// Sent returns the transactions sent to the gateway.
+func (g *Gateway) Sent() []*types.Transaction {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return append([]*types.Transaction(nil), g.sent...)
+}
+
+func (g *Gateway) handle(c *client, req *request) (interface{}, error) {
+	switch req.Method {
+	case "subscribe":
+		var feed string
+		if err := parseParam(req, 0, &feed); err != nil {
+			return nil, err
+		}
+
+		var options map[string]interface{}
+		if len(req.Params) > 1 {
+			if err := parseParam(req, 1, &options); err != nil {
+				return nil, err
+			}
+		}
+
+		switch feed {
+		case "newTxs", "pendingTxs", "newBlocks", "bdnBlocks", "transactionStatus", "txReceipts", "ethOnBlock":
+			g.mu.Lock()
+			g.options[feed] = options
+			g.mu.Unlock()
+
+			return g.srv.subscribe(c, feed)
+		}
+
+		return nil, fmt.Errorf("unsupported feed %q", feed)
+	case "unsubscribe":
+		var id string
+		if err := parseParam(req, 0, &id); err != nil {
+			return nil, err
+		}
+
+		return g.srv.unsubscribe(c, id), nil
+	case "blxr_tx":
+		var params struct {
+			Transaction string `json:"transaction"`
+		}
+		if err := parseParam(req, 0, &params); err != nil {
+			return nil, err
+		}
+
+		tx, err := decodeTx(params.Transaction)
+		if err != nil {
+			return nil, err
+		}
+
+		g.receive(tx)
+
+		return map[string]string{"txHash": tx.Hash().Hex()[2:]}, nil
+	case "blxr_private_tx":
+		var params struct {
+			Transaction string `json:"transaction"`
+		}
+		if err := parseParam(req, 0, &params); err != nil {
+			return nil, err
+		}
+
+		tx, err := decodeTx(params.Transaction)
+		if err != nil {
+			return nil, err
+		}
+
+		g.receive(tx)
+
+		return map[string]string{"txHash": tx.Hash().Hex()[2:]}, nil
+	case "blxr_submit_bundle":
+		var params struct {
+			Transaction []string `json:"transaction"`
+		}
+		if err := parseParam(req, 0, &params); err != nil {
+			return nil, err
+		}
+
+		txs, err := decodeTxs(params.Transaction)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tx := range txs {
+			g.receive(tx)
+		}
+
+		return map[string]string{"bundleHash": bundleHash(txs)}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported method %s", req.Method)
+}
+
+// receive records the transaction sent to the gateway, which may be delivered more than
+// once when a bundle is submitted for several blocks.
+func (g *Gateway) receive(tx *types.Transaction) {
+	g.mu.Lock()
+	g.sent = append(g.sent, tx)
+	onRawTransaction := g.onRawTransaction
+	g.mu.Unlock()
+
+	if onRawTransaction != nil {
+		onRawTransaction(tx)
+	}
+}
@@ -0,0 +1,543 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tx describes a transaction announced by the mock servers. A transaction without To
+// creates a contract, the fee fields of the dynamic fee transactions are sent if
+// MaxPriorityFeePerGas is set.
+type Tx struct {
+	Hash                 string
+	From                 string
+	To                   string
+	Value                *big.Int
+	GasPrice             *big.Int
+	MaxPriorityFeePerGas *big.Int
+	Input                string
+}
+
+func (tx *Tx) contents() map[string]interface{} {
+	res := map[string]interface{}{
+		"hash":     tx.Hash,
+		"from":     tx.From,
+		"to":       nil,
+		"value":    encodeBig(tx.Value),
+		"gasPrice": encodeBig(tx.GasPrice),
+		"type":     "0x0",
+		"input":    "0x",
+	}
+
+	if tx.To != "" {
+		res["to"] = tx.To
+	}
+
+	if tx.MaxPriorityFeePerGas != nil {
+		res["type"] = "0x2"
+		res["maxFeePerGas"] = res["gasPrice"]
+		res["maxPriorityFeePerGas"] = encodeBig(tx.MaxPriorityFeePerGas)
+	}
+
+	if tx.Input != "" {
+		res["input"] = tx.Input
+	}
+
+	return res
+}
+
+func encodeBig(n *big.Int) string {
+	if n == nil {
+		return "0x0"
+	}
+
+	return hexutil.EncodeBig(n)
+}
+
+// receipt locates a mined transaction.
+type receipt struct {
+	blockNumber uint64
+	index       uint64
+}
+
+// Block describes a block announced by the mock servers. The node serves the transactions
+// of the block, which are the ones mined at its number if Txs is not set, and stamps the
+// block with the time it was announced if Time is not set.
+type Block struct {
+	Hash       string
+	Number     uint64
+	ParentHash string
+	Txs        []*types.Transaction
+	Uncles     []string
+	Time       time.Time
+}
+
+func (b *Block) header() map[string]interface{} {
+	return map[string]interface{}{
+		"hash":       b.Hash,
+		"number":     hexutil.EncodeUint64(b.Number),
+		"parentHash": b.ParentHash,
+		"timestamp":  hexutil.EncodeUint64(uint64(b.Time.Unix())),
+	}
+}
+
+// contents returns the block with its transactions, which are either hashes or objects.
+func (b *Block) contents(fullTxs bool) map[string]interface{} {
+	txs := make([]interface{}, 0, len(b.Txs))
+	for _, tx := range b.Txs {
+		if !fullTxs {
+			txs = append(txs, tx.Hash().Hex())
+			continue
+		}
+
+		from, _ := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+		txs = append(txs, map[string]interface{}{
+			"hash":  tx.Hash().Hex(),
+			"from":  from.Hex(),
+			"nonce": hexutil.EncodeUint64(tx.Nonce()),
+		})
+	}
+
+	uncles := b.Uncles
+	if uncles == nil {
+		uncles = []string{}
+	}
+
+	res := b.header()
+	res["transactions"] = txs
+	res["uncles"] = uncles
+
+	return res
+}
+
+// receipts returns the receipts of the transactions of the block.
+func (b *Block) receipts() []map[string]interface{} {
+	res := make([]map[string]interface{}, 0, len(b.Txs))
+	for i, tx := range b.Txs {
+		res = append(res, map[string]interface{}{
+			"blockHash":        b.Hash,
+			"blockNumber":      hexutil.EncodeUint64(b.Number),
+			"transactionHash":  tx.Hash().Hex(),
+			"transactionIndex": hexutil.EncodeUint64(uint64(i)),
+			"status":           "0x1",
+		})
+	}
+
+	return res
+}
+
+// Node is a mock EVM node. It serves the JSON-RPC API over websocket and HTTP on the same
+// address.
+type Node struct {
+	srv *server
+
+	mu               sync.Mutex
+	responseDelay    time.Duration
+	onRawTransaction func(tx *types.Transaction)
+	txs              map[string]*Tx
+	blocks           map[string]*Block
+	mined            map[string]receipt
+	minedTxs         map[uint64][]*types.Transaction
+	byNumber         map[uint64]*Block
+	head             *Block
+	nonce            uint64
+	blockNumber      uint64
+	balance          *big.Int
+	baseFees         []*big.Int
+	rewards          []*big.Int
+	sent             []*types.Transaction
+}
+
+// NewNode starts a mock EVM node.
+func NewNode() *Node {
+	n := &Node{
+		txs:      make(map[string]*Tx),
+		blocks:   make(map[string]*Block),
+		mined:    make(map[string]receipt),
+		minedTxs: make(map[uint64][]*types.Transaction),
+		byNumber: make(map[uint64]*Block),
+		balance:  big.NewInt(0),
+	}
+	n.srv = newServer("eth_subscription", n.handle, n.delay)
+
+	return n
+}
+
+// WSURL returns the websocket endpoint of the node.
+func (n *Node) WSURL() string {
+	return n.srv.wsURL()
+}
+
+// HTTPURL returns the HTTP endpoint of the node.
+func (n *Node) HTTPURL() string {
+	return n.srv.httpURL()
+}
+
+// IPCPath returns the path of the IPC socket of the node.
+func (n *Node) IPCPath() string {
+	return n.srv.ipcPath()
+}
+
+// Close stops the node.
+func (n *Node) Close() {
+	n.srv.close()
+}
+
+// DropConnections closes all websocket connections to the node.
+func (n *Node) DropConnections() {
+	n.srv.dropConnections()
+}
+
+// WaitSubscriptions waits until count subscriptions were made to the node.
+func (n *Node) WaitSubscriptions(ctx context.Context, count int) error {
+	return n.srv.waitSubscriptions(ctx, count)
+}
+
+// SetResponseDelay delays every RPC response of the node, e.g. to script slow retrieval of
+// transaction contents.
+func (n *Node) SetResponseDelay(d time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.responseDelay = d
+}
+
+// SetAccount sets the nonce and the balance returned for any account.
+func (n *Node) SetAccount(nonce uint64, balance *big.Int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.nonce, n.balance = nonce, balance
+}
+
+// SetBlockNumber sets the result of eth_blockNumber. The next head produced by NewHead
+// follows this number.
+func (n *Node) SetBlockNumber(number uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.blockNumber = number
+}
+
+// BlockNumber returns the number of the latest block.
+func (n *Node) BlockNumber() uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.blockNumber
+}
+
+// SetFeeHistory sets the result of eth_feeHistory. There is one more base fee than rewards,
+// the last one is the base fee of the next block.
+func (n *Node) SetFeeHistory(baseFees, rewards []*big.Int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.baseFees, n.rewards = baseFees, rewards
+}
+
+// OnRawTransaction sets a callback which is called for every transaction sent to the node.
+func (n *Node) OnRawTransaction(fn func(tx *types.Transaction)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.onRawTransaction = fn
+}
+
+// AnnounceTx makes the transaction available to eth_getTransactionByHash and notifies the
+// newPendingTransactions subscribers.
+func (n *Node) AnnounceTx(tx Tx) {
+	n.mu.Lock()
+	n.txs[tx.Hash] = &tx
+	n.mu.Unlock()
+
+	n.srv.notify(tx.Hash, "newPendingTransactions")
+}
+
+// AnnounceBlock makes the block available to eth_getBlockByHash, makes it the latest block
+// and notifies the newHeads subscribers. Announcing a block with the number of a previous
+// one simulates a reorg.
+func (n *Node) AnnounceBlock(b Block) {
+	n.mu.Lock()
+	if b.Time.IsZero() {
+		b.Time = time.Now()
+	}
+	if b.Txs == nil {
+		b.Txs = n.minedTxs[b.Number]
+	}
+	n.blocks[b.Hash] = &b
+	n.byNumber[b.Number] = &b
+	n.head = &b
+	n.blockNumber = b.Number
+	n.mu.Unlock()
+
+	n.srv.notify(b.header(), "newHeads")
+}
+
+// NewHead announces the block following the latest one with the transactions mined at its
+// number.
+func (n *Node) NewHead() Block {
+	n.mu.Lock()
+	b := Block{Number: n.blockNumber + 1}
+	if n.head != nil {
+		b.ParentHash = n.head.Hash
+	}
+	b.Hash = crypto.Keccak256Hash([]byte(b.ParentHash), []byte(hexutil.EncodeUint64(b.Number))).Hex()
+	n.mu.Unlock()
+
+	n.AnnounceBlock(b)
+
+	return b
+}
+
+// Mine makes the receipt of the transaction available and includes the transaction in the
+// block with the given number. Transactions mined in the same block get consecutive
+// indexes.
+func (n *Node) Mine(tx *types.Transaction, blockNumber uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.mined[strings.ToLower(tx.Hash().Hex())] = receipt{
+		blockNumber: blockNumber,
+		index:       uint64(len(n.minedTxs[blockNumber])),
+	}
+	n.minedTxs[blockNumber] = append(n.minedTxs[blockNumber], tx)
+}
+
+// Sent returns the transactions sent to the node.
+func (n *Node) Sent() []*types.Transaction {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return append([]*types.Transaction(nil), n.sent...)
+}
+
+func (n *Node) delay() time.Duration {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.responseDelay
+}
+
+func (n *Node) handle(c *client, req *request) (interface{}, error) {
+	switch req.Method {
+	case "eth_subscribe":
+		var feed string
+		if err := parseParam(req, 0, &feed); err != nil {
+			return nil, err
+		}
+
+		if feed != "newPendingTransactions" && feed != "newHeads" {
+			return nil, fmt.Errorf("unsupported subscription %q", feed)
+		}
+
+		return n.srv.subscribe(c, feed)
+	case "eth_unsubscribe":
+		var id string
+		if err := parseParam(req, 0, &id); err != nil {
+			return nil, err
+		}
+
+		return n.srv.unsubscribe(c, id), nil
+	case "eth_getTransactionByHash":
+		var hash string
+		if err := parseParam(req, 0, &hash); err != nil {
+			return nil, err
+		}
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		if tx, ok := n.txs[hash]; ok {
+			return tx.contents(), nil
+		}
+
+		return nil, nil
+	case "eth_getBlockByHash":
+		var (
+			hash    string
+			fullTxs bool
+		)
+		if err := parseParam(req, 0, &hash); err != nil {
+			return nil, err
+		}
+		if len(req.Params) > 1 {
+			if err := parseParam(req, 1, &fullTxs); err != nil {
+				return nil, err
+			}
+		}
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		if b, ok := n.blocks[hash]; ok {
+			return b.contents(fullTxs), nil
+		}
+
+		return nil, nil
+	case "eth_getBlockReceipts":
+		var hash string
+		if err := parseParam(req, 0, &hash); err != nil {
+			return nil, err
+		}
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		if b, ok := n.blocks[hash]; ok {
+			return b.receipts(), nil
+		}
+
+		return nil, nil
+	case "eth_getTransactionCount":
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		return hexutil.EncodeUint64(n.nonce), nil
+	case "eth_blockNumber":
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		return hexutil.EncodeUint64(n.blockNumber), nil
+	case "eth_getBalance":
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		return hexutil.EncodeBig(n.balance), nil
+	case "eth_feeHistory":
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		var (
+			baseFees = make([]string, 0, len(n.baseFees))
+			rewards  = make([][]string, 0, len(n.rewards))
+		)
+		for _, fee := range n.baseFees {
+			baseFees = append(baseFees, hexutil.EncodeBig(fee))
+		}
+		for _, reward := range n.rewards {
+			rewards = append(rewards, []string{hexutil.EncodeBig(reward)})
+		}
+
+		return map[string]interface{}{
+			"oldestBlock":   "0x1",
+			"baseFeePerGas": baseFees,
+			"reward":        rewards,
+		}, nil
+	case "eth_sendRawTransaction":
+		var raw string
+		if err := parseParam(req, 0, &raw); err != nil {
+			return nil, err
+		}
+
+		tx, err := decodeTx(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		n.mu.Lock()
+		n.sent = append(n.sent, tx)
+		onRawTransaction := n.onRawTransaction
+		n.mu.Unlock()
+
+		if onRawTransaction != nil {
+			onRawTransaction(tx)
+		}
+
+		return tx.Hash().Hex(), nil
+	case "eth_getTransactionReceipt":
+		var hash string
+		if err := parseParam(req, 0, &hash); err != nil {
+			return nil, err
+		}
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		if r, ok := n.mined[strings.ToLower(hash)]; ok {
+			return map[string]interface{}{
+				"transactionHash":  hash,
+				"blockNumber":      hexutil.EncodeUint64(r.blockNumber),
+				"transactionIndex": hexutil.EncodeUint64(r.index),
+				"status":           "0x1",
+			}, nil
+		}
+
+		return nil, nil
+	case "eth_getBlockByNumber":
+		var (
+			number  string
+			fullTxs bool
+		)
+		if err := parseParam(req, 0, &number); err != nil {
+			return nil, err
+		}
+		if len(req.Params) > 1 {
+			if err := parseParam(req, 1, &fullTxs); err != nil {
+				return nil, err
+			}
+		}
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		b := n.head
+		if number != "latest" {
+			num, err := hexutil.DecodeUint64(number)
+			if err != nil {
+				return nil, fmt.Errorf("invalid block number %q: %v", number, err)
+			}
+			b = n.byNumber[num]
+		}
+
+		if b != nil {
+			return b.contents(fullTxs), nil
+		}
+
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("the method %s does not exist/is not available", req.Method)
+}
+
+func decodeTx(raw string) (*types.Transaction, error) {
+	var tx types.Transaction
+	if err := tx.UnmarshalBinary(common.FromHex(raw)); err != nil {
+		return nil, fmt.Errorf("cannot decode transaction: %v", err)
+	}
+
+	return &tx, nil
+}
+
+func decodeTxs(raws []string) ([]*types.Transaction, error) {
+	txs := make([]*types.Transaction, 0, len(raws))
+	for _, raw := range raws {
+		tx, err := decodeTx(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		txs = append(txs, tx)
+	}
+
+	return txs, nil
+}
+
+// bundleHash returns the hash of the concatenated transaction hashes of the bundle.
+func bundleHash(txs []*types.Transaction) string {
+	var hashes []byte
+	for _, tx := range txs {
+		hashes = append(hashes, tx.Hash().Bytes()...)
+	}
+
+	return crypto.Keccak256Hash(hashes).Hex()
+}
@@ -0,0 +1,157 @@
+package mock
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Relay is a mock Flashbots compatible relay serving eth_sendBundle and
+// eth_sendPrivateTransaction over HTTP. Requests without a valid X-Flashbots-Signature
+// header are rejected.
+type Relay struct {
+	srv *server
+
+	mu               sync.Mutex
+	onRawTransaction func(tx *types.Transaction)
+	sent             []*types.Transaction
+	signers          map[common.Address]struct{}
+}
+
+// NewRelay starts a mock relay.
+func NewRelay() *Relay {
+	r := &Relay{signers: make(map[common.Address]struct{})}
+	r.srv = newServer("", r.handle, func() time.Duration { return 0 })
+
+	return r
+}
+
+// URL returns the HTTP endpoint of the relay.
+func (r *Relay) URL() string {
+	return r.srv.httpURL()
+}
+
+// Close stops the relay.
+func (r *Relay) Close() {
+	r.srv.close()
+}
+
+// OnRawTransaction sets a callback which is called for every transaction sent to the relay.
+func (r *Relay) OnRawTransaction(fn func(tx *types.Transaction)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.onRawTransaction = fn
+}
+
+// Sent returns the transactions sent to the relay.
+func (r *Relay) Sent() []*types.Transaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*types.Transaction(nil), r.sent...)
+}
+
+// Signed checks if a request signed by the address was received.
+func (r *Relay) Signed(address common.Address) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.signers[address]
+	return ok
+}
+
+func (r *Relay) handle(_ *client, req *request) (interface{}, error) {
+	if err := r.verify(req); err != nil {
+		return nil, err
+	}
+
+	switch req.Method {
+	case "eth_sendBundle":
+		var params struct {
+			Txs         []string `json:"txs"`
+			BlockNumber string   `json:"blockNumber"`
+		}
+		if err := parseParam(req, 0, &params); err != nil {
+			return nil, err
+		}
+
+		txs, err := decodeTxs(params.Txs)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tx := range txs {
+			r.receive(tx)
+		}
+
+		return map[string]string{"bundleHash": bundleHash(txs)}, nil
+	case "eth_sendPrivateTransaction":
+		var params struct {
+			Tx string `json:"tx"`
+		}
+		if err := parseParam(req, 0, &params); err != nil {
+			return nil, err
+		}
+
+		tx, err := decodeTx(params.Tx)
+		if err != nil {
+			return nil, err
+		}
+
+		r.receive(tx)
+
+		return tx.Hash().Hex(), nil
+	}
+
+	return nil, fmt.Errorf("the method %s does not exist/is not available", req.Method)
+}
+
+// verify checks that the signature header was made over the request body by the address
+// it names.
+func (r *Relay) verify(req *request) error {
+	parts := strings.SplitN(req.header.Get("X-Flashbots-Signature"), ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("missing X-Flashbots-Signature header")
+	}
+
+	signature, err := hexutil.Decode(parts[1])
+	if err != nil || len(signature) != crypto.SignatureLength {
+		return fmt.Errorf("invalid X-Flashbots-Signature header")
+	}
+
+	hash := accounts.TextHash([]byte(hexutil.Encode(crypto.Keccak256(req.body))))
+	key, err := crypto.SigToPub(hash, signature)
+	if err != nil {
+		return fmt.Errorf("invalid X-Flashbots-Signature header: %v", err)
+	}
+
+	address := crypto.PubkeyToAddress(*key)
+	if address != common.HexToAddress(parts[0]) {
+		return fmt.Errorf("X-Flashbots-Signature header is not signed by %s", parts[0])
+	}
+
+	r.mu.Lock()
+	r.signers[address] = struct{}{}
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *Relay) receive(tx *types.Transaction) {
+	r.mu.Lock()
+	r.sent = append(r.sent, tx)
+	onRawTransaction := r.onRawTransaction
+	r.mu.Unlock()
+
+	if onRawTransaction != nil {
+		onRawTransaction(tx)
+	}
+}
@@ -0,0 +1,379 @@
+// Package mock provides local EVM node and bloXroute gateway servers, so that the benchmarks
+// can be run and verified without live endpoints.
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type request struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+
+	// header and body are set for HTTP requests.
+	header http.Header
+	body   []byte
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type notification struct {
+	JSONRPC string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  notificationParams `json:"params"`
+}
+
+type notificationParams struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// handlerFunc answers a single RPC request. Subscription requests may use the client to
+// register the subscription.
+type handlerFunc func(c *client, req *request) (interface{}, error)
+
+// conn is a websocket or IPC connection to the server.
+type conn interface {
+	ReadJSON(v interface{}) error
+	WriteJSON(v interface{}) error
+	Close() error
+}
+
+// ipcConn exchanges a stream of JSON values over a Unix socket.
+type ipcConn struct {
+	net.Conn
+	dec *json.Decoder
+	enc *json.Encoder
+}
+
+func (c *ipcConn) ReadJSON(v interface{}) error {
+	return c.dec.Decode(v)
+}
+
+func (c *ipcConn) WriteJSON(v interface{}) error {
+	return c.enc.Encode(v)
+}
+
+// client is a websocket or IPC connection to the server. It is nil for HTTP requests.
+type client struct {
+	conn conn
+
+	mu   sync.Mutex
+	subs map[string]string
+	// pending subscriptions become active once the subscription response is written.
+	pending map[string]string
+}
+
+func (c *client) write(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.conn.WriteJSON(v)
+}
+
+// server is a JSON-RPC server which accepts both websocket connections and HTTP POST
+// requests on the same address, and IPC connections on a Unix socket.
+type server struct {
+	srv    *httptest.Server
+	ipc    net.Listener
+	ipcDir string
+	handle handlerFunc
+	// notificationMethod is the method name of the subscription notifications.
+	notificationMethod string
+	// delay returns the time to wait before each response.
+	delay func() time.Duration
+
+	mu         sync.Mutex
+	clients    map[*client]struct{}
+	lastSubID  int
+	subscribed chan struct{}
+}
+
+func newServer(notificationMethod string, handle handlerFunc, delay func() time.Duration) *server {
+	s := &server{
+		handle:             handle,
+		notificationMethod: notificationMethod,
+		delay:              delay,
+		clients:            make(map[*client]struct{}),
+		subscribed:         make(chan struct{}, 1024),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	var err error
+	if s.ipcDir, err = ioutil.TempDir("", "mock"); err != nil {
+		panic(fmt.Sprintf("cannot create IPC directory: %v", err))
+	}
+
+	if s.ipc, err = net.Listen("unix", filepath.Join(s.ipcDir, "node.ipc")); err != nil {
+		panic(fmt.Sprintf("cannot listen on IPC socket: %v", err))
+	}
+	go s.serveIPC()
+
+	return s
+}
+
+func (s *server) wsURL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http")
+}
+
+func (s *server) httpURL() string {
+	return s.srv.URL
+}
+
+func (s *server) ipcPath() string {
+	return s.ipc.Addr().String()
+}
+
+func (s *server) close() {
+	s.mu.Lock()
+	for c := range s.clients {
+		_ = c.conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.srv.Close()
+	_ = s.ipc.Close()
+	_ = os.RemoveAll(s.ipcDir)
+}
+
+// dropConnections closes all websocket and IPC connections, e.g. to simulate an outage.
+func (s *server) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.clients {
+		_ = c.conn.Close()
+		delete(s.clients, c)
+	}
+}
+
+func (s *server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveWS(w, r)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.header, req.body = r.Header, body
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.call(nil, &req))
+}
+
+func (s *server) serveWS(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	s.serveConn(conn)
+}
+
+func (s *server) serveIPC() {
+	for {
+		conn, err := s.ipc.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.serveConn(&ipcConn{Conn: conn, dec: json.NewDecoder(conn), enc: json.NewEncoder(conn)})
+	}
+}
+
+func (s *server) serveConn(conn conn) {
+	c := &client{
+		conn:    conn,
+		subs:    make(map[string]string),
+		pending: make(map[string]string),
+	}
+
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+
+		_ = conn.Close()
+	}()
+
+	for {
+		var req request
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+
+		go func() {
+			res := s.call(c, &req)
+			if err := c.write(res); err != nil {
+				_ = conn.Close()
+				return
+			}
+
+			if id, ok := res.Result.(string); ok {
+				s.activate(c, id)
+			}
+		}()
+	}
+}
+
+func (s *server) call(c *client, req *request) *response {
+	if d := s.delay(); d > 0 {
+		time.Sleep(d)
+	}
+
+	res := &response{JSONRPC: "2.0", ID: req.ID}
+
+	result, err := s.handle(c, req)
+	if err != nil {
+		res.Error = &rpcError{Code: -32000, Message: err.Error()}
+	} else {
+		res.Result = result
+	}
+
+	return res
+}
+
+// subscribe registers a subscription of the client to the feed and returns its ID.
+func (s *server) subscribe(c *client, feed string) (string, error) {
+	if c == nil {
+		return "", fmt.Errorf("subscriptions are not supported over HTTP")
+	}
+
+	s.mu.Lock()
+	s.lastSubID++
+	id := fmt.Sprintf("0x%x", s.lastSubID)
+	s.mu.Unlock()
+
+	c.mu.Lock()
+	c.pending[id] = feed
+	c.mu.Unlock()
+
+	return id, nil
+}
+
+// activate starts sending notifications of the pending subscription to the client.
+func (s *server) activate(c *client, id string) {
+	c.mu.Lock()
+	feed, ok := c.pending[id]
+	if ok {
+		delete(c.pending, id)
+		c.subs[id] = feed
+	}
+	c.mu.Unlock()
+
+	if ok {
+		s.subscribed <- struct{}{}
+	}
+}
+
+func (s *server) unsubscribe(c *client, id string) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.subs[id]; !ok {
+		return false
+	}
+
+	delete(c.subs, id)
+
+	return true
+}
+
+// notify sends the result to all subscribers of any of the feeds.
+func (s *server) notify(result interface{}, feeds ...string) {
+	s.mu.Lock()
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range clients {
+		c.mu.Lock()
+		var ids []string
+		for id, feed := range c.subs {
+			for _, f := range feeds {
+				if feed == f {
+					ids = append(ids, id)
+				}
+			}
+		}
+		c.mu.Unlock()
+
+		for _, id := range ids {
+			_ = c.write(&notification{
+				JSONRPC: "2.0",
+				Method:  s.notificationMethod,
+				Params:  notificationParams{Subscription: id, Result: result},
+			})
+		}
+	}
+}
+
+// waitSubscriptions waits until count subscriptions were made since the server was started.
+func (s *server) waitSubscriptions(ctx context.Context, count int) error {
+	for i := 0; i < count; i++ {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d of %d subscriptions were made: %v", i, count, ctx.Err())
+		case <-s.subscribed:
+		}
+	}
+
+	return nil
+}
+
+func parseParam(req *request, i int, v interface{}) error {
+	if len(req.Params) <= i {
+		return fmt.Errorf("missing parameter %d of %s", i, req.Method)
+	}
+
+	if err := json.Unmarshal(req.Params[i], v); err != nil {
+		return fmt.Errorf("invalid parameter %d of %s: %v", i, req.Method, err)
+	}
+
+	return nil
+}
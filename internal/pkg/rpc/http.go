@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"performance/internal/pkg/tlsconfig"
+	"performance/internal/pkg/ws"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HTTPClient makes JSON-RPC calls with HTTP POST requests. Secure endpoints use the
+// configuration of the tlsconfig package.
+type HTTPClient struct {
+	uri        string
+	authHeader string
+	client     *http.Client
+}
+
+// NewHTTPClient creates a client of the HTTP endpoint.
+func NewHTTPClient(uri, authHeader string) *HTTPClient {
+	return &HTTPClient{
+		uri:        uri,
+		authHeader: authHeader,
+		client:     tlsconfig.HTTPClient(uri),
+	}
+}
+
+// Call makes the call and returns the raw response.
+func (c *HTTPClient) Call(req *ws.Request) ([]byte, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, c.uri, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.authHeader != "" {
+		httpReq.Header.Set("Authorization", c.authHeader)
+	}
+
+	t := time.Now()
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("response time %v for %s from %s", time.Since(t), req.Method, c.uri)
+
+	if resp.StatusCode != http.StatusOK && len(data) == 0 {
+		return nil, fmt.Errorf("unexpected status %s from %s", resp.Status, c.uri)
+	}
+
+	return data, nil
+}
+
+// Close does nothing, the connections are shared by all HTTP clients.
+func (c *HTTPClient) Close() error {
+	return nil
+}
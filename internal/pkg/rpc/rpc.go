@@ -0,0 +1,123 @@
+// Package rpc provides a JSON-RPC client of the nodes and the gateways over websocket, HTTP
+// and IPC, with typed helpers for the common methods.
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"performance/internal/pkg/ws"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Client makes JSON-RPC calls and returns the raw responses.
+type Client interface {
+	Call(req *ws.Request) ([]byte, error)
+	Close() error
+}
+
+// Subscriber is a client which can subscribe to the feeds of the node, which is the case
+// for the websocket and IPC transports.
+type Subscriber interface {
+	Client
+	SubscribeTxFeedEvm() (*ws.Subscription, error)
+	SubscribeBkFeedEvm() (*ws.Subscription, error)
+}
+
+// Error is the error object of a JSON-RPC response.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// Dial creates a client of the endpoint. The transport is selected by the scheme of the URI:
+// http:// and https:// use HTTP, ws:// and wss:// use websocket, and ipc:// or a path without a
+// scheme use the IPC socket.
+func Dial(uri, authHeader string) (Client, error) {
+	if strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://") {
+		return NewHTTPClient(uri, authHeader), nil
+	}
+
+	return ws.Dial(uri, authHeader)
+}
+
+// Result makes the call and decodes the result of the response into result.
+func Result(c Client, result interface{}, method string, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	data, err := c.Call(ws.NewRequest(1, method, params))
+	if err != nil {
+		return err
+	}
+
+	var res response
+	if err = json.Unmarshal(data, &res); err != nil {
+		return err
+	}
+
+	if res.Error != nil {
+		return fmt.Errorf("error from RPC: %s", res.Error.Message)
+	}
+
+	if len(res.Result) == 0 || string(res.Result) == "null" {
+		return fmt.Errorf("empty response result")
+	}
+
+	return json.Unmarshal(res.Result, result)
+}
+
+// BlockNumber returns the number of the latest block.
+func BlockNumber(c Client) (uint64, error) {
+	var number hexutil.Uint64
+	if err := Result(c, &number, "eth_blockNumber"); err != nil {
+		return 0, fmt.Errorf("cannot get block number: %v", err)
+	}
+
+	return uint64(number), nil
+}
+
+// Nonce returns the nonce of the next transaction of the address.
+func Nonce(c Client, address string) (uint64, error) {
+	var nonce hexutil.Uint64
+	if err := Result(c, &nonce, "eth_getTransactionCount", address, "latest"); err != nil {
+		return 0, fmt.Errorf("cannot get nonce of %s: %v", address, err)
+	}
+
+	return uint64(nonce), nil
+}
+
+// Balance returns the balance of the address in wei.
+func Balance(c Client, address string) (*big.Int, error) {
+	var balance hexutil.Big
+	if err := Result(c, &balance, "eth_getBalance", address, "latest"); err != nil {
+		return nil, fmt.Errorf("cannot get balance of %s: %v", address, err)
+	}
+
+	return balance.ToInt(), nil
+}
+
+// SendRawTransaction sends the encoded transaction and returns the raw response.
+func SendRawTransaction(c Client, rawTx string) ([]byte, error) {
+	return c.Call(ws.NewRequest(1, "eth_sendRawTransaction", []interface{}{rawTx}))
+}
+
+// EncodeTx returns the hex encoding of the signed transaction.
+func EncodeTx(tx *types.Transaction) (string, error) {
+	// Typed transactions are encoded as an envelope rather than an RLP list.
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+
+	return hexutil.Encode(data), nil
+}
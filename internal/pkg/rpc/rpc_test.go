@@ -0,0 +1,58 @@
+package rpc
+
+import (
+	"math/big"
+	"performance/internal/pkg/mock"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestDial(t *testing.T) {
+	node := mock.NewNode()
+	defer node.Close()
+
+	node.SetAccount(7, big.NewInt(params.Ether))
+	node.SetBlockNumber(42)
+
+	for _, tc := range []struct {
+		uri        string
+		subscriber bool
+	}{
+		{node.WSURL(), true},
+		{node.HTTPURL(), false},
+		{node.IPCPath(), true},
+		{"ipc://" + node.IPCPath(), true},
+	} {
+		client, err := Dial(tc.uri, "")
+		if err != nil {
+			t.Fatalf("cannot dial %s: %v", tc.uri, err)
+		}
+
+		if _, ok := client.(Subscriber); ok != tc.subscriber {
+			t.Errorf("expected %s to support subscriptions: %v", tc.uri, tc.subscriber)
+		}
+
+		if nonce, err := Nonce(client, "0x0000000000000000000000000000000000000001"); err != nil || nonce != 7 {
+			t.Errorf("unexpected nonce from %s: %d, %v", tc.uri, nonce, err)
+		}
+
+		if balance, err := Balance(client, "0x0000000000000000000000000000000000000001"); err != nil ||
+			balance.Cmp(big.NewInt(params.Ether)) != 0 {
+			t.Errorf("unexpected balance from %s: %s, %v", tc.uri, balance, err)
+		}
+
+		if number, err := BlockNumber(client); err != nil || number != 42 {
+			t.Errorf("unexpected block number from %s: %d, %v", tc.uri, number, err)
+		}
+
+		var res string
+		if err := Result(client, &res, "eth_unknownMethod"); err == nil {
+			t.Errorf("expected an error from %s", tc.uri)
+		}
+
+		if err := client.Close(); err != nil {
+			t.Errorf("cannot close %s: %v", tc.uri, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package senders
+
+import (
+	"fmt"
+	"io"
+	"performance/internal/pkg/rpc"
+	"performance/internal/pkg/txfee"
+)
+
+// Refresh gets the nonces and the balances of the accounts from the node.
+func Refresh(client rpc.Client, pool []*Account) error {
+	for _, acc := range pool {
+		var err error
+		if acc.Nonce, err = rpc.Nonce(client, acc.Address); err != nil {
+			return err
+		}
+
+		if acc.Balance, err = rpc.Balance(client, acc.Address); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckBalances checks if every account has enough balance for its share of the groups of
+// transactions, and prints the accounts which do not.
+func CheckBalances(out io.Writer, pool []*Account, fees *txfee.Fees, numGroups int, gasLimit uint64) bool {
+	ok := true
+	for k, acc := range pool {
+		share := Share(k, len(pool), numGroups)
+
+		expense := fees.MaxCost(share, gasLimit)
+		if acc.Balance.Cmp(expense) >= 0 {
+			continue
+		}
+
+		fmt.Fprintf(out, "Sender %s does not have enough balance for %d groups of transactions.\n"+
+			"Sender's balance is %f Coins,\n"+
+			"while at least %f Coins is required\n",
+			acc.Address,
+			share,
+			txfee.ToEther(acc.Balance),
+			txfee.ToEther(expense))
+		ok = false
+	}
+
+	return ok
+}
@@ -0,0 +1,203 @@
+// Package senders manages a pool of sender accounts, so that the groups of transactions of
+// the tx speed commands can be in flight concurrently.
+package senders
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"performance/internal/pkg/flags"
+	"performance/internal/pkg/keys"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/urfave/cli/v2"
+)
+
+// Account is a sender of the pool. Nonce is the nonce of its next transaction.
+type Account struct {
+	Key     *ecdsa.PrivateKey
+	Address string
+	Nonce   uint64
+	Balance *big.Int
+}
+
+// NewAccount creates the account of the key.
+func NewAccount(key *ecdsa.PrivateKey) *Account {
+	return &Account{
+		Key:     key,
+		Address: crypto.PubkeyToAddress(key.PublicKey).Hex(),
+		Balance: big.NewInt(0),
+	}
+}
+
+// Addresses returns the addresses of the accounts.
+func Addresses(pool []*Account) []string {
+	res := make([]string, 0, len(pool))
+	for _, acc := range pool {
+		res = append(res, acc.Address)
+	}
+
+	return res
+}
+
+// IsPool checks if a pool of senders is specified instead of a single sender.
+func IsPool(c *cli.Context) bool {
+	return c.String(flags.SendersKeystoreDir.Name) != "" || c.String(flags.SendersMnemonicFile.Name) != ""
+}
+
+// FromFlags loads the pool of senders. The keys are decrypted from all keystore files of
+// the directory, or derived from the mnemonic at --num-senders consecutive indices of the
+// derivation path. Without a pool, the pool consists of the single sender.
+func FromFlags(c *cli.Context) ([]*Account, error) {
+	var (
+		dir          = c.String(flags.SendersKeystoreDir.Name)
+		mnemonicFile = c.String(flags.SendersMnemonicFile.Name)
+	)
+
+	switch {
+	case dir != "" && mnemonicFile != "":
+		return nil, fmt.Errorf("error: only one of --%s and --%s can be specified",
+			flags.SendersKeystoreDir.Name, flags.SendersMnemonicFile.Name)
+	case dir != "":
+		password, err := keys.Password(c)
+		if err != nil {
+			return nil, err
+		}
+
+		return fromKeystoreDir(dir, password)
+	case mnemonicFile != "":
+		return fromMnemonicFile(mnemonicFile, c.String(flags.MnemonicPassphrase.Name),
+			c.String(flags.DerivationPath.Name), c.Int(flags.NumSenders.Name))
+	default:
+		key, err := keys.FromFlags(c)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*Account{NewAccount(key)}, nil
+	}
+}
+
+func fromKeystoreDir(dir, password string) ([]*Account, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read keystore directory %s: %v", dir, err)
+	}
+
+	var pool []*Account
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		key, err := keys.FromKeystore(filepath.Join(dir, entry.Name()), password)
+		if err != nil {
+			return nil, err
+		}
+		pool = append(pool, NewAccount(key))
+	}
+
+	if len(pool) == 0 {
+		return nil, fmt.Errorf("error: keystore directory %s is empty", dir)
+	}
+
+	// The order of the accounts does not depend on the file names.
+	sort.Slice(pool, func(i, j int) bool { return pool[i].Address < pool[j].Address })
+
+	return pool, nil
+}
+
+func fromMnemonicFile(file, passphrase, path string, count int) ([]*Account, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("error: --%s must be positive", flags.NumSenders.Name)
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %v", file, err)
+	}
+
+	base, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse derivation path %s: %v", path, err)
+	}
+
+	var (
+		pool = make([]*Account, 0, count)
+		next = accounts.DefaultIterator(base)
+	)
+
+	for i := 0; i < count; i++ {
+		key, err := keys.FromMnemonic(string(data), passphrase, next().String())
+		if err != nil {
+			return nil, err
+		}
+		pool = append(pool, NewAccount(key))
+	}
+
+	return pool, nil
+}
+
+// Run sends the groups numbered from 1 to numGroups. Every account sends its share of the
+// groups one after another, pausing for delay between them, while the accounts send
+// concurrently. The nonce of the account is incremented after each of its groups. Run stops
+// sending on the first error and returns it.
+func Run(pool []*Account, numGroups int, delay time.Duration, send func(i int, acc *Account) error) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		err  error
+		stop = make(chan struct{})
+	)
+
+	for k, acc := range pool {
+		wg.Add(1)
+		go func(first int, acc *Account) {
+			defer wg.Done()
+
+			for i := first; i <= numGroups; i += len(pool) {
+				if sendErr := send(i, acc); sendErr != nil {
+					mu.Lock()
+					if err == nil {
+						err = sendErr
+						close(stop)
+					}
+					mu.Unlock()
+
+					return
+				}
+				acc.Nonce++
+
+				if i+len(pool) > numGroups {
+					return
+				}
+
+				select {
+				case <-stop:
+					return
+				case <-time.After(delay):
+				}
+			}
+		}(k+1, acc)
+	}
+	wg.Wait()
+
+	return err
+}
+
+// Share returns the number of groups which are sent by the account with the index in the
+// pool.
+func Share(index, poolSize, numGroups int) int {
+	if index >= numGroups {
+		return 0
+	}
+
+	return (numGroups-index-1)/poolSize + 1
+}
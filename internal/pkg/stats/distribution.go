@@ -0,0 +1,225 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Distribution collects sample values and computes summary statistics over them.
+type Distribution struct {
+	values []float64
+	sorted bool
+}
+
+// NewDistribution creates an empty Distribution.
+func NewDistribution() *Distribution {
+	return &Distribution{}
+}
+
+// Add inserts a sample value.
+func (d *Distribution) Add(v float64) {
+	d.values = append(d.values, v)
+	d.sorted = false
+}
+
+// Merge inserts all sample values of other distribution.
+func (d *Distribution) Merge(other *Distribution) {
+	d.values = append(d.values, other.values...)
+	d.sorted = false
+}
+
+// Len returns the number of samples.
+func (d *Distribution) Len() int {
+	return len(d.values)
+}
+
+// Mean returns the arithmetic mean of the samples or 0 if there are none.
+func (d *Distribution) Mean() float64 {
+	if len(d.values) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, v := range d.values {
+		sum += v
+	}
+
+	return sum / float64(len(d.values))
+}
+
+// StdDev returns the population standard deviation of the samples.
+func (d *Distribution) StdDev() float64 {
+	if len(d.values) == 0 {
+		return 0
+	}
+
+	var (
+		mean = d.Mean()
+		sum  float64
+	)
+
+	for _, v := range d.values {
+		sum += (v - mean) * (v - mean)
+	}
+
+	return math.Sqrt(sum / float64(len(d.values)))
+}
+
+// Percentile returns the p-th (0 < p <= 100) percentile using the nearest-rank method.
+func (d *Distribution) Percentile(p float64) float64 {
+	if len(d.values) == 0 {
+		return 0
+	}
+
+	d.sort()
+
+	rank := int(math.Ceil(p / 100 * float64(len(d.values))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(d.values) {
+		rank = len(d.values)
+	}
+
+	return d.values[rank-1]
+}
+
+// Min returns the smallest sample.
+func (d *Distribution) Min() float64 {
+	if len(d.values) == 0 {
+		return 0
+	}
+
+	d.sort()
+
+	return d.values[0]
+}
+
+// Max returns the largest sample.
+func (d *Distribution) Max() float64 {
+	if len(d.values) == 0 {
+		return 0
+	}
+
+	d.sort()
+
+	return d.values[len(d.values)-1]
+}
+
+// Bucket is a histogram bucket which counts samples in the [Lower, Upper) range.
+type Bucket struct {
+	Lower float64
+	Upper float64
+	Count int
+}
+
+// String returns a human readable range of the bucket.
+func (b Bucket) String() string {
+	switch {
+	case math.IsInf(b.Lower, -1):
+		return fmt.Sprintf("< %g", b.Upper)
+	case math.IsInf(b.Upper, 1):
+		return fmt.Sprintf(">= %g", b.Lower)
+	default:
+		return fmt.Sprintf("[%g, %g)", b.Lower, b.Upper)
+	}
+}
+
+// Histogram counts the samples into len(bounds)+1 buckets delimited by the ascending bounds.
+// The first and the last buckets are open ended.
+func (d *Distribution) Histogram(bounds []float64) []Bucket {
+	buckets := make([]Bucket, len(bounds)+1)
+	for i := range buckets {
+		buckets[i].Lower = math.Inf(-1)
+		buckets[i].Upper = math.Inf(1)
+		if i > 0 {
+			buckets[i].Lower = bounds[i-1]
+		}
+		if i < len(bounds) {
+			buckets[i].Upper = bounds[i]
+		}
+	}
+
+	for _, v := range d.values {
+		i := sort.Search(len(bounds), func(i int) bool { return v < bounds[i] })
+		buckets[i].Count++
+	}
+
+	return buckets
+}
+
+// ParseBounds parses a comma separated list of ascending histogram bucket bounds.
+func ParseBounds(str string) ([]float64, error) {
+	if strings.TrimSpace(str) == "" {
+		return nil, nil
+	}
+
+	var bounds []float64
+	for _, field := range strings.Split(str, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse bucket bound %q: %v", field, err)
+		}
+
+		if len(bounds) > 0 && v <= bounds[len(bounds)-1] {
+			return nil, fmt.Errorf("bucket bounds must be in ascending order: %q", str)
+		}
+
+		bounds = append(bounds, v)
+	}
+
+	return bounds, nil
+}
+
+// Summary contains the commonly reported statistics of a distribution.
+type Summary struct {
+	Count  int     `json:"count"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stdDev"`
+	P50    float64 `json:"p50"`
+	P90    float64 `json:"p90"`
+	P95    float64 `json:"p95"`
+	P99    float64 `json:"p99"`
+	Max    float64 `json:"max"`
+}
+
+// Summary computes the commonly reported statistics of the distribution.
+func (d *Distribution) Summary() Summary {
+	return Summary{
+		Count:  d.Len(),
+		Mean:   d.Mean(),
+		StdDev: d.StdDev(),
+		P50:    d.Percentile(50),
+		P90:    d.Percentile(90),
+		P95:    d.Percentile(95),
+		P99:    d.Percentile(99),
+		Max:    d.Max(),
+	}
+}
+
+// MarshalJSON encodes the bucket with null in place of the infinite bounds.
+func (b Bucket) MarshalJSON() ([]byte, error) {
+	bound := func(v float64) *float64 {
+		if math.IsInf(v, 0) {
+			return nil
+		}
+		return &v
+	}
+
+	return json.Marshal(struct {
+		Lower *float64 `json:"lower"`
+		Upper *float64 `json:"upper"`
+		Count int      `json:"count"`
+	}{bound(b.Lower), bound(b.Upper), b.Count})
+}
+
+func (d *Distribution) sort() {
+	if !d.sorted {
+		sort.Float64s(d.values)
+		d.sorted = true
+	}
+}
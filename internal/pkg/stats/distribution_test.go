@@ -0,0 +1,54 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistribution(t *testing.T) {
+	d := NewDistribution()
+	for i := 100; i >= 1; i-- {
+		d.Add(float64(i))
+	}
+
+	for p, expected := range map[float64]float64{50: 50, 90: 90, 95: 95, 99: 99, 100: 100} {
+		if v := d.Percentile(p); v != expected {
+			t.Fatalf("p%g should be %g, got %g", p, expected, v)
+		}
+	}
+
+	if d.Min() != 1 || d.Max() != 100 {
+		t.Fatalf("unexpected min/max: %g/%g", d.Min(), d.Max())
+	}
+
+	if d.Mean() != 50.5 {
+		t.Fatalf("mean should be 50.5, got %g", d.Mean())
+	}
+
+	if sd := d.StdDev(); math.Abs(sd-28.866) > 0.001 {
+		t.Fatalf("standard deviation should be 28.866, got %g", sd)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	d := NewDistribution()
+	for _, v := range []float64{-30, -10, -1, 0, 5, 10, 100} {
+		d.Add(v)
+	}
+
+	bounds, err := ParseBounds("-10, 0, 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buckets := d.Histogram(bounds)
+	for i, expected := range []int{1, 2, 2, 2} {
+		if buckets[i].Count != expected {
+			t.Fatalf("bucket %s should contain %d samples, got %d", buckets[i], expected, buckets[i].Count)
+		}
+	}
+
+	if _, err := ParseBounds("10,0"); err == nil {
+		t.Fatal("descending bounds should not be accepted")
+	}
+}
@@ -0,0 +1,188 @@
+// Package tlsconfig holds the TLS settings which are shared by the websocket and HTTP
+// connections of all commands.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"performance/internal/pkg/flags"
+	"strings"
+	"sync"
+
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	mu          sync.RWMutex
+	config      = &tls.Config{}
+	serverNames map[string]string
+	clients     = newHTTPClients(config, nil)
+)
+
+// Options describes the TLS settings of the connections.
+type Options struct {
+	// InsecureSkipVerify disables the verification of the server certificate.
+	InsecureSkipVerify bool
+	// CAFile is a PEM bundle of the certificate authorities which are trusted instead of
+	// the system ones.
+	CAFile string
+	// CertFile and KeyFile are the PEM client certificate and key for mutual TLS.
+	CertFile string
+	KeyFile  string
+}
+
+// New creates the TLS configuration from the options.
+func New(opts Options) (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	if opts.CAFile != "" {
+		data, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA file %s: %v", opts.CAFile, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("cannot parse CA file %s: no PEM certificates found", opts.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, fmt.Errorf("error: --%s and --%s must be specified together",
+			flags.TLSCertFile.Name, flags.TLSKeyFile.Name)
+	}
+
+	if opts.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate %s: %v", opts.CertFile, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// ParseServerNames parses values of the server name flag. Each value has a form of
+// host=name, where host is the host, optionally with the port, of the endpoint whose
+// connections send the name with SNI and verify it in the certificate instead of the host.
+func ParseServerNames(values []string) (map[string]string, error) {
+	names := make(map[string]string, len(values))
+	for _, value := range values {
+		kv := strings.SplitN(strings.TrimSpace(value), "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid server name %q, expected host=name", value)
+		}
+
+		names[kv[0]] = kv[1]
+	}
+
+	return names, nil
+}
+
+// Configure applies the TLS flags of the command to all connections made afterwards.
+func Configure(c *cli.Context) error {
+	cfg, err := New(Options{
+		InsecureSkipVerify: c.Bool(flags.TLSInsecureSkipVerify.Name),
+		CAFile:             c.String(flags.TLSCAFile.Name),
+		CertFile:           c.String(flags.TLSCertFile.Name),
+		KeyFile:            c.String(flags.TLSKeyFile.Name),
+	})
+	if err != nil {
+		return err
+	}
+
+	names, err := ParseServerNames(c.StringSlice(flags.TLSServerName.Name))
+	if err != nil {
+		return fmt.Errorf("error: invalid --%s value: %v", flags.TLSServerName.Name, err)
+	}
+
+	Set(cfg, names)
+
+	return nil
+}
+
+// Set replaces the TLS configuration of the connections and the server names of the
+// endpoint hosts.
+func Set(cfg *tls.Config, names map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	config, serverNames = cfg, names
+	clients = newHTTPClients(cfg, names)
+}
+
+// Config returns a copy of the TLS configuration of the websocket connections to the
+// endpoint.
+func Config(uri string) *tls.Config {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	cfg := config.Clone()
+	if name, ok := serverName(serverNames, uri); ok {
+		cfg.ServerName = name
+	}
+
+	return cfg
+}
+
+// HTTPClient returns the client of the HTTP requests to the endpoint.
+func HTTPClient(uri string) *http.Client {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if name, ok := serverName(serverNames, uri); ok {
+		return clients[name]
+	}
+
+	return clients[""]
+}
+
+// serverName returns the server name of the host of the endpoint, if it is overridden.
+func serverName(names map[string]string, uri string) (string, bool) {
+	if len(names) == 0 {
+		return "", false
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", false
+	}
+
+	if name, ok := names[u.Host]; ok {
+		return name, true
+	}
+
+	name, ok := names[u.Hostname()]
+
+	return name, ok
+}
+
+// newHTTPClients creates the HTTP clients keyed by the overridden server names, the client
+// of the other endpoints has an empty key.
+func newHTTPClients(cfg *tls.Config, names map[string]string) map[string]*http.Client {
+	res := map[string]*http.Client{"": newHTTPClient(cfg)}
+	for _, name := range names {
+		if _, ok := res[name]; !ok {
+			named := cfg.Clone()
+			named.ServerName = name
+			res[name] = newHTTPClient(named)
+		}
+	}
+
+	return res
+}
+
+func newHTTPClient(cfg *tls.Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg.Clone()
+
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,176 @@
+package tlsconfig_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"performance/internal/pkg/tlsconfig"
+	"performance/internal/pkg/ws"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func writePEM(t *testing.T, name, typ string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: data}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// clientCert creates a self-signed client certificate and returns it with the paths of its
+// certificate and key files.
+func clientCert(t *testing.T) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, writePEM(t, "client.pem", "CERTIFICATE", der), writePEM(t, "client.key", "EC PRIVATE KEY", keyDER)
+}
+
+// newServer starts a TLS server which answers HTTP requests and upgrades websocket ones.
+func newServer(t *testing.T, clientCA *x509.Certificate) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err == nil {
+				_ = conn.Close()
+			}
+			return
+		}
+
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	if clientCA != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(clientCA)
+		srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	}
+
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func connect(srv *httptest.Server) error {
+	resp, err := tlsconfig.HTTPClient(srv.URL).Get(srv.URL)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	conn, err := ws.NewConnection(strings.Replace(srv.URL, "https", "wss", 1), "")
+	if err != nil {
+		return err
+	}
+	_ = conn.Close()
+
+	return nil
+}
+
+func configure(t *testing.T, opts tlsconfig.Options, names ...string) {
+	cfg, err := tlsconfig.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverNames, err := tlsconfig.ParseServerNames(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tlsconfig.Set(cfg, serverNames)
+	t.Cleanup(func() { tlsconfig.Set(&tls.Config{}, nil) })
+}
+
+func TestVerification(t *testing.T) {
+	srv := newServer(t, nil)
+	caFile := writePEM(t, "ca.pem", "CERTIFICATE", srv.Certificate().Raw)
+	host := srv.Listener.Addr().String()
+
+	tests := []struct {
+		name  string
+		opts  tlsconfig.Options
+		names []string
+		ok    bool
+	}{
+		{"system CAs", tlsconfig.Options{}, nil, false},
+		{"insecure", tlsconfig.Options{InsecureSkipVerify: true}, nil, true},
+		{"custom CA", tlsconfig.Options{CAFile: caFile}, nil, true},
+		{"server name", tlsconfig.Options{CAFile: caFile}, []string{host + "=example.com"}, true},
+		{"wrong server name", tlsconfig.Options{CAFile: caFile}, []string{host + "=example.org"}, false},
+		// The server name of another endpoint does not apply to the connections of this one.
+		{"server name of another host", tlsconfig.Options{CAFile: caFile}, []string{"gw.example.com=example.org"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configure(t, tt.opts, tt.names...)
+
+			if err := connect(srv); (err == nil) != tt.ok {
+				t.Errorf("expected success %v, got error %v", tt.ok, err)
+			}
+		})
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	cert, certFile, keyFile := clientCert(t)
+	srv := newServer(t, cert)
+
+	configure(t, tlsconfig.Options{InsecureSkipVerify: true})
+	if err := connect(srv); err == nil {
+		t.Error("expected the connection without a client certificate to fail")
+	}
+
+	configure(t, tlsconfig.Options{InsecureSkipVerify: true, CertFile: certFile, KeyFile: keyFile})
+	if err := connect(srv); err != nil {
+		t.Errorf("cannot connect with the client certificate: %v", err)
+	}
+
+	if _, err := tlsconfig.New(tlsconfig.Options{CertFile: certFile}); err == nil {
+		t.Error("expected an error for a certificate without a key")
+	}
+}
@@ -0,0 +1,229 @@
+package txfee
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"performance/internal/pkg/flags"
+	"performance/internal/pkg/rpc"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/urfave/cli/v2"
+)
+
+// Types of the benchmark transactions.
+const (
+	TypeLegacy  = "legacy"
+	TypeDynamic = "dynamic"
+)
+
+// feeHistoryBlocks is the number of recent blocks the automatic fees are derived from.
+const feeHistoryBlocks = 20
+
+// Fees holds the fee parameters of the benchmark transactions. GasPrice is set for legacy
+// transactions, MaxFee and MaxPriorityFee are set for dynamic fee transactions.
+type Fees struct {
+	Type           string
+	GasPrice       *big.Int
+	MaxFee         *big.Int
+	MaxPriorityFee *big.Int
+}
+
+type feeHistory struct {
+	BaseFeePerGas []*hexutil.Big   `json:"baseFeePerGas"`
+	Reward        [][]*hexutil.Big `json:"reward"`
+}
+
+// FromFlags creates the fees from the transaction type and fee flags. Dynamic fees which
+// are not specified are derived from eth_feeHistory of the client.
+func FromFlags(c *cli.Context, client rpc.Client) (*Fees, error) {
+	switch typ := c.String(flags.TxType.Name); typ {
+	case TypeLegacy:
+		gasPrice := c.Int64(flags.GasPrice.Name)
+		if gasPrice <= 0 {
+			return nil, fmt.Errorf("error: --%s is required for %s transactions",
+				flags.GasPrice.Name, TypeLegacy)
+		}
+
+		return &Fees{
+			Type:     TypeLegacy,
+			GasPrice: new(big.Int).Mul(big.NewInt(gasPrice), big.NewInt(params.GWei)),
+		}, nil
+	case TypeDynamic:
+		fees := &Fees{
+			Type:           TypeDynamic,
+			MaxFee:         FromGwei(c.Float64(flags.MaxFee.Name)),
+			MaxPriorityFee: FromGwei(c.Float64(flags.MaxPriorityFee.Name)),
+		}
+
+		if fees.MaxFee.Sign() < 0 || fees.MaxPriorityFee.Sign() < 0 {
+			return nil, fmt.Errorf("error: --%s and --%s cannot be negative",
+				flags.MaxFee.Name, flags.MaxPriorityFee.Name)
+		}
+
+		if fees.MaxFee.Sign() == 0 || fees.MaxPriorityFee.Sign() == 0 {
+			if err := fees.suggest(client); err != nil {
+				return nil, err
+			}
+		}
+
+		if fees.MaxPriorityFee.Cmp(fees.MaxFee) > 0 {
+			return nil, fmt.Errorf("error: max priority fee %s is higher than max fee %s",
+				formatGwei(fees.MaxPriorityFee), formatGwei(fees.MaxFee))
+		}
+
+		return fees, nil
+	default:
+		return nil, fmt.Errorf("error: possible values for --%s are %q, %q",
+			flags.TxType.Name, TypeLegacy, TypeDynamic)
+	}
+}
+
+// suggest fills the fees which were not specified. The priority fee is the median of the
+// median rewards paid in the recent blocks, or eth_maxPriorityFeePerGas of the client if the
+// fee history has no rewards, and the max fee allows the base fee to double.
+func (f *Fees) suggest(client rpc.Client) error {
+	var res feeHistory
+	if err := rpc.Result(client, &res, "eth_feeHistory",
+		hexutil.EncodeUint64(feeHistoryBlocks), "latest", []float64{50}); err != nil {
+		return fmt.Errorf("cannot get fee history: %v", err)
+	}
+
+	if len(res.BaseFeePerGas) == 0 {
+		return fmt.Errorf("cannot get fee history: empty response result")
+	}
+
+	if f.MaxPriorityFee.Sign() == 0 {
+		var rewards []*big.Int
+		for _, reward := range res.Reward {
+			if len(reward) > 0 && reward[0] != nil {
+				rewards = append(rewards, reward[0].ToInt())
+			}
+		}
+
+		if len(rewards) > 0 {
+			sort.Slice(rewards, func(i, j int) bool { return rewards[i].Cmp(rewards[j]) < 0 })
+			f.MaxPriorityFee = rewards[len(rewards)/2]
+		} else {
+			var tip hexutil.Big
+			if err := rpc.Result(client, &tip, "eth_maxPriorityFeePerGas"); err != nil {
+				return fmt.Errorf("cannot get max priority fee, the fee history has no rewards: %v", err)
+			}
+			f.MaxPriorityFee = tip.ToInt()
+		}
+
+		if f.MaxPriorityFee.Sign() == 0 {
+			return fmt.Errorf("suggested max priority fee is zero, specify --%s",
+				flags.MaxPriorityFee.Name)
+		}
+	}
+
+	if f.MaxFee.Sign() == 0 {
+		// The last item is the base fee of the next block.
+		baseFee := res.BaseFeePerGas[len(res.BaseFeePerGas)-1].ToInt()
+		f.MaxFee = new(big.Int).Add(new(big.Int).Mul(baseFee, big.NewInt(2)), f.MaxPriorityFee)
+	}
+
+	return nil
+}
+
+// NewTx creates a transaction of the configured type.
+func (f *Fees) NewTx(chainID *big.Int, nonce uint64, to *common.Address, gas uint64, data []byte) *types.Transaction {
+	return f.newTx(chainID, nonce, to, gas, big.NewInt(0), data)
+}
+
+// GroupData returns the calldata of the i-th transaction of a group of conflicting
+// transactions: 4 bytes of i+1, so every transaction of the group has a different hash and
+// the landed one identifies where it was sent.
+func GroupData(i int) []byte {
+	return bytes.Repeat([]byte{byte(i + 1)}, 4)
+}
+
+// NewTransfer creates a transaction of the configured type which transfers the value.
+func (f *Fees) NewTransfer(chainID *big.Int, nonce uint64, to *common.Address, value *big.Int) *types.Transaction {
+	return f.newTx(chainID, nonce, to, params.TxGas, value, nil)
+}
+
+func (f *Fees) newTx(
+	chainID *big.Int,
+	nonce uint64,
+	to *common.Address,
+	gas uint64,
+	value *big.Int,
+	data []byte,
+) *types.Transaction {
+	if f.Type == TypeDynamic {
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     nonce,
+			GasTipCap: f.MaxPriorityFee,
+			GasFeeCap: f.MaxFee,
+			Gas:       gas,
+			To:        to,
+			Value:     value,
+			Data:      data,
+		})
+	}
+
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: f.GasPrice,
+		Gas:      gas,
+		To:       to,
+		Value:    value,
+		Data:     data,
+	})
+}
+
+// Signer returns a signer which supports both legacy and dynamic fee transactions.
+func (f *Fees) Signer(chainID *big.Int) types.Signer {
+	return types.NewLondonSigner(chainID)
+}
+
+// MaxCost returns the highest amount of wei count transactions with the gas limit may cost.
+func (f *Fees) MaxCost(count int, gas uint64) *big.Int {
+	price := f.GasPrice
+	if f.Type == TypeDynamic {
+		price = f.MaxFee
+	}
+
+	cost := new(big.Int).Mul(price, new(big.Int).SetUint64(gas))
+
+	return cost.Mul(cost, big.NewInt(int64(count)))
+}
+
+func (f *Fees) String() string {
+	if f.Type == TypeDynamic {
+		return fmt.Sprintf("max fee %s, max priority fee %s",
+			formatGwei(f.MaxFee), formatGwei(f.MaxPriorityFee))
+	}
+
+	return fmt.Sprintf("gas price %s", formatGwei(f.GasPrice))
+}
+
+// ToEther converts an amount of wei to ether.
+func ToEther(wei *big.Int) float64 {
+	ether, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.Ether)).Float64()
+	return ether
+}
+
+// FromEther converts an amount of ether to wei.
+func FromEther(ether float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(ether), big.NewFloat(params.Ether)).Int(nil)
+	return wei
+}
+
+// FromGwei converts an amount of gwei to wei.
+func FromGwei(gwei float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(gwei), big.NewFloat(params.GWei)).Int(nil)
+	return wei
+}
+
+func formatGwei(wei *big.Int) string {
+	gwei, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.GWei)).Float64()
+	return fmt.Sprintf("%g Gwei", gwei)
+}
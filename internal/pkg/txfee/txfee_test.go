@@ -0,0 +1,239 @@
+package txfee
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"performance/internal/pkg/flags"
+	"performance/internal/pkg/ws"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/urfave/cli/v2"
+)
+
+// stubClient answers the calls with the results of the methods, a method without a result
+// returns an RPC error.
+type stubClient map[string]interface{}
+
+func (s stubClient) Call(req *ws.Request) ([]byte, error) {
+	res := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	if result, ok := s[req.Method]; ok {
+		res["result"] = result
+	} else {
+		res["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+	}
+
+	return json.Marshal(res)
+}
+
+func (s stubClient) Close() error { return nil }
+
+func historyResult(baseFees []int64, rewards ...int64) map[string]interface{} {
+	var (
+		base   []string
+		reward [][]string
+	)
+	for _, fee := range baseFees {
+		base = append(base, fmt.Sprintf("%#x", fee*params.GWei))
+	}
+	for _, r := range rewards {
+		reward = append(reward, []string{fmt.Sprintf("%#x", r*params.GWei)})
+	}
+
+	return map[string]interface{}{"oldestBlock": "0x1", "baseFeePerGas": base, "reward": reward}
+}
+
+func gwei(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(params.GWei))
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		name        string
+		client      stubClient
+		maxFee      *big.Int
+		priorityFee *big.Int
+		expMaxFee   *big.Int
+		expPriority *big.Int
+		expErr      bool
+	}{
+		{
+			name:        "median reward",
+			client:      stubClient{"eth_feeHistory": historyResult([]int64{10, 11, 12, 12}, 3, 1, 2)},
+			maxFee:      big.NewInt(0),
+			priorityFee: big.NewInt(0),
+			expMaxFee:   gwei(26),
+			expPriority: gwei(2),
+		},
+		{
+			name:        "specified priority fee",
+			client:      stubClient{"eth_feeHistory": historyResult([]int64{10, 12}, 2)},
+			maxFee:      big.NewInt(0),
+			priorityFee: gwei(5),
+			expMaxFee:   gwei(29),
+			expPriority: gwei(5),
+		},
+		{
+			name:        "specified max fee",
+			client:      stubClient{"eth_feeHistory": historyResult([]int64{10, 12}, 2)},
+			maxFee:      gwei(40),
+			priorityFee: big.NewInt(0),
+			expMaxFee:   gwei(40),
+			expPriority: gwei(2),
+		},
+		{
+			name: "no rewards",
+			client: stubClient{
+				"eth_feeHistory":           historyResult([]int64{10, 12}),
+				"eth_maxPriorityFeePerGas": fmt.Sprintf("%#x", int64(3*params.GWei)),
+			},
+			maxFee:      big.NewInt(0),
+			priorityFee: big.NewInt(0),
+			expMaxFee:   gwei(27),
+			expPriority: gwei(3),
+		},
+		{
+			name:        "no rewards and no max priority fee",
+			client:      stubClient{"eth_feeHistory": historyResult([]int64{10, 12})},
+			maxFee:      big.NewInt(0),
+			priorityFee: big.NewInt(0),
+			expErr:      true,
+		},
+		{
+			name: "zero max priority fee",
+			client: stubClient{
+				"eth_feeHistory":           historyResult([]int64{10, 12}),
+				"eth_maxPriorityFeePerGas": "0x0",
+			},
+			maxFee:      big.NewInt(0),
+			priorityFee: big.NewInt(0),
+			expErr:      true,
+		},
+		{
+			name:        "no base fees",
+			client:      stubClient{"eth_feeHistory": historyResult(nil)},
+			maxFee:      big.NewInt(0),
+			priorityFee: big.NewInt(0),
+			expErr:      true,
+		},
+		{
+			name:        "no fee history",
+			client:      stubClient{},
+			maxFee:      big.NewInt(0),
+			priorityFee: big.NewInt(0),
+			expErr:      true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			fees := &Fees{Type: TypeDynamic, MaxFee: tc.maxFee, MaxPriorityFee: tc.priorityFee}
+
+			err := fees.suggest(tc.client)
+			if tc.expErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", fees)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if fees.MaxFee.Cmp(tc.expMaxFee) != 0 || fees.MaxPriorityFee.Cmp(tc.expPriority) != 0 {
+				t.Errorf("expected max fee %s and max priority fee %s, got %s",
+					formatGwei(tc.expMaxFee), formatGwei(tc.expPriority), fees)
+			}
+		})
+	}
+}
+
+func newContext(t *testing.T, args ...string) *cli.Context {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range []cli.Flag{flags.TxType, flags.GasPrice, flags.MaxFee, flags.MaxPriorityFee} {
+		if err := f.Apply(set); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := set.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	return cli.NewContext(nil, set, nil)
+}
+
+func TestFromFlags(t *testing.T) {
+	client := stubClient{"eth_feeHistory": historyResult([]int64{10, 12}, 2)}
+
+	tests := []struct {
+		name string
+		args []string
+		exp  *Fees
+	}{
+		{
+			name: "legacy",
+			args: []string{"--gas-price", "10"},
+			exp:  &Fees{Type: TypeLegacy, GasPrice: gwei(10)},
+		},
+		{
+			name: "legacy without gas price",
+			args: nil,
+		},
+		{
+			name: "legacy with negative gas price",
+			args: []string{"--gas-price=-10"},
+		},
+		{
+			name: "dynamic",
+			args: []string{"--tx-type", "dynamic", "--max-fee", "30", "--max-priority-fee", "3"},
+			exp:  &Fees{Type: TypeDynamic, MaxFee: gwei(30), MaxPriorityFee: gwei(3)},
+		},
+		{
+			name: "dynamic suggested",
+			args: []string{"--tx-type", "dynamic"},
+			exp:  &Fees{Type: TypeDynamic, MaxFee: gwei(26), MaxPriorityFee: gwei(2)},
+		},
+		{
+			name: "dynamic with negative max fee",
+			args: []string{"--tx-type", "dynamic", "--max-fee=-30", "--max-priority-fee", "3"},
+		},
+		{
+			name: "dynamic with negative max priority fee",
+			args: []string{"--tx-type", "dynamic", "--max-fee", "30", "--max-priority-fee=-3"},
+		},
+		{
+			name: "dynamic with priority fee above max fee",
+			args: []string{"--tx-type", "dynamic", "--max-fee", "3", "--max-priority-fee", "30"},
+		},
+		{
+			name: "unknown type",
+			args: []string{"--tx-type", "blob", "--gas-price", "10"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			fees, err := FromFlags(newContext(t, tc.args...), client)
+			if tc.exp == nil {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", fees)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if fees.Type != tc.exp.Type || fees.String() != tc.exp.String() {
+				t.Errorf("expected %s fees %s, got %s fees %s", tc.exp.Type, tc.exp, fees.Type, fees)
+			}
+		})
+	}
+}
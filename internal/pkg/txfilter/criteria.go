@@ -0,0 +1,149 @@
+package txfilter
+
+import (
+	"fmt"
+	"math/big"
+	"performance/internal/pkg/utils"
+	"regexp"
+	"strings"
+)
+
+// TxTypes are the names of the transaction types, by type number.
+var TxTypes = map[string]uint64{
+	"legacy":      0,
+	"access-list": 1,
+	"dynamic":     2,
+}
+
+var methodIDPattern = regexp.MustCompile(`^0x[0-9a-f]{8}$`)
+
+// Criteria filter transactions by their contents. Unlike a Filter, which the gateway
+// applies to its feed, the criteria are applied to the transactions of every source. Unset
+// criteria match every transaction.
+type Criteria struct {
+	// From are the lowercase addresses of the senders.
+	From utils.HashSet
+	// MethodIDs are the lowercase 4-byte selectors of the called methods.
+	MethodIDs utils.HashSet
+	MinValue  *big.Int
+	MaxValue  *big.Int
+	Types     map[uint64]struct{}
+	// MinPriorityFee is the minimum max priority fee per gas of dynamic fee transactions
+	// and the minimum gas price of the other transactions.
+	MinPriorityFee *big.Int
+	// ContractCreation matches only the transactions without a recipient.
+	ContractCreation bool
+}
+
+// ParseAddresses parses a comma separated list of addresses.
+func ParseAddresses(str string) utils.HashSet {
+	res := utils.NewHashSet()
+	for _, addr := range strings.Split(strings.ToLower(str), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			res.Add(addr)
+		}
+	}
+
+	return res
+}
+
+// ParseMethodIDs parses a comma separated list of 4-byte method selectors.
+func ParseMethodIDs(str string) (utils.HashSet, error) {
+	res := utils.NewHashSet()
+	for _, id := range strings.Split(strings.ToLower(str), ",") {
+		id = strings.TrimSpace(id)
+		if !methodIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("invalid method selector %q, expected 0x followed by 8 hex digits", id)
+		}
+
+		res.Add(id)
+	}
+
+	return res, nil
+}
+
+// ParseTypes parses a comma separated list of the names of transaction types.
+func ParseTypes(str string) (map[uint64]struct{}, error) {
+	res := make(map[uint64]struct{})
+	for _, name := range strings.Split(strings.ToLower(str), ",") {
+		typ, ok := TxTypes[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("invalid transaction type %q, possible values: 'legacy', 'access-list', 'dynamic'", name)
+		}
+
+		res[typ] = struct{}{}
+	}
+
+	return res, nil
+}
+
+// Empty checks if no criteria are set.
+func (c *Criteria) Empty() bool {
+	return c.From.Empty() && c.MethodIDs.Empty() && c.MinValue == nil && c.MaxValue == nil &&
+		len(c.Types) == 0 && c.MinPriorityFee == nil && !c.ContractCreation
+}
+
+// Match checks if the transaction meets all criteria. A criterion on a field the transaction
+// does not have is not met, except the missing type which is legacy.
+func (c *Criteria) Match(tx *Tx) bool {
+	if !c.From.Empty() && (tx.From == nil || !c.From.Contains(strings.ToLower(*tx.From))) {
+		return false
+	}
+
+	if !c.MethodIDs.Empty() {
+		id := methodID(tx)
+		if id == nil || !c.MethodIDs.Contains(strings.ToLower(*id)) {
+			return false
+		}
+	}
+
+	if c.MinValue != nil || c.MaxValue != nil {
+		value, ok := quantity(tx.Value)
+		if !ok || (c.MinValue != nil && value.Cmp(c.MinValue) < 0) ||
+			(c.MaxValue != nil && value.Cmp(c.MaxValue) > 0) {
+			return false
+		}
+	}
+
+	if len(c.Types) > 0 {
+		typ := uint64(0)
+		if tx.Type != nil {
+			n, ok := quantity(tx.Type)
+			if !ok || !n.IsUint64() {
+				return false
+			}
+			typ = n.Uint64()
+		}
+
+		if _, ok := c.Types[typ]; !ok {
+			return false
+		}
+	}
+
+	if c.MinPriorityFee != nil {
+		fee := tx.MaxPriorityFeePerGas
+		if fee == nil {
+			fee = tx.GasPrice
+		}
+
+		n, ok := quantity(fee)
+		if !ok || n.Cmp(c.MinPriorityFee) < 0 {
+			return false
+		}
+	}
+
+	if c.ContractCreation && tx.To != nil && *tx.To != "" && *tx.To != "0x" {
+		return false
+	}
+
+	return true
+}
+
+// quantity parses a hex number of the transaction.
+func quantity(str *string) (*big.Int, bool) {
+	if str == nil || !strings.HasPrefix(strings.ToLower(*str), "0x") {
+		return nil, false
+	}
+
+	return new(big.Int).SetString((*str)[2:], 16)
+}
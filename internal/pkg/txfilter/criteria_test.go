@@ -0,0 +1,67 @@
+package txfilter
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestCriteria(t *testing.T) {
+	types, err := ParseTypes("legacy, access-list")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	methodIDs, err := ParseMethodIDs("0xA9059CBB")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tx := &Tx{
+		From:     str("0x00000000000000000000000000000000000000AA"),
+		Value:    str("0xde0b6b3a7640000"),
+		GasPrice: str("0x77359400"),
+		Input:    str("0xa9059cbb"),
+	}
+
+	for name, test := range map[string]struct {
+		criteria Criteria
+		expected bool
+	}{
+		"empty":             {Criteria{}, true},
+		"from":              {Criteria{From: ParseAddresses("0xcc,0x00000000000000000000000000000000000000aa")}, true},
+		"other from":        {Criteria{From: ParseAddresses("0xcc")}, false},
+		"method":            {Criteria{MethodIDs: methodIDs}, true},
+		"value":             {Criteria{MinValue: big.NewInt(1e18), MaxValue: big.NewInt(1e18)}, true},
+		"high value":        {Criteria{MaxValue: big.NewInt(1e17)}, false},
+		"missing type":      {Criteria{Types: types}, true},
+		"priority fee":      {Criteria{MinPriorityFee: big.NewInt(2e9)}, true},
+		"high priority fee": {Criteria{MinPriorityFee: big.NewInt(3e9)}, false},
+		"contract creation": {Criteria{ContractCreation: true}, true},
+		"combined":          {Criteria{From: ParseAddresses("0xcc"), ContractCreation: true}, false},
+	} {
+		if res := test.criteria.Match(tx); res != test.expected {
+			t.Errorf("expected %s criteria to be %t, got %t", name, test.expected, res)
+		}
+	}
+
+	dynamic := &Tx{Type: str("0x2"), To: str("0x00000000000000000000000000000000000000bb"), MaxPriorityFeePerGas: str("0x1")}
+	for name, criteria := range map[string]Criteria{
+		"type":              {Types: types},
+		"priority fee":      {MinPriorityFee: big.NewInt(2)},
+		"contract creation": {ContractCreation: true},
+		"missing value":     {MinValue: big.NewInt(0)},
+		"missing method":    {MethodIDs: methodIDs},
+	} {
+		if criteria.Match(dynamic) {
+			t.Errorf("expected %s criteria not to match", name)
+		}
+	}
+
+	if _, err := ParseTypes("legacy,blob"); err == nil {
+		t.Error("expected an error parsing an unknown type")
+	}
+
+	if _, err := ParseMethodIDs("0xa9059cbb,a9059cbb"); err == nil {
+		t.Error("expected an error parsing a selector without 0x")
+	}
+}
@@ -0,0 +1,452 @@
+// Package txfilter evaluates the filter expressions of the bloXroute transaction feeds, e.g.
+// "({to} == '0x...') AND ({gas_price} > 30000000000)", so the transactions of other sources
+// can be filtered the same way as the gateway filters its feed.
+package txfilter
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+)
+
+// Tx holds the fields of a transaction the filters are evaluated on. It is decoded from the
+// transaction contents of the gateway feeds and from eth_getTransactionByHash responses.
+// The numbers are hex encoded.
+type Tx struct {
+	From                 *string `json:"from"`
+	To                   *string `json:"to"`
+	Value                *string `json:"value"`
+	Gas                  *string `json:"gas"`
+	GasPrice             *string `json:"gasPrice"`
+	MaxFeePerGas         *string `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *string `json:"maxPriorityFeePerGas"`
+	Type                 *string `json:"type"`
+	ChainID              *string `json:"chainId"`
+	Input                *string `json:"input"`
+}
+
+// Kinds of the fields of a transaction.
+const (
+	stringField = iota
+	numberField
+)
+
+// fields are the fields of a transaction which can be used in a filter, by name.
+var fields = map[string]struct {
+	kind  int
+	value func(tx *Tx) *string
+}{
+	"from":                     {stringField, func(tx *Tx) *string { return tx.From }},
+	"to":                       {stringField, func(tx *Tx) *string { return tx.To }},
+	"method_id":                {stringField, methodID},
+	"value":                    {numberField, func(tx *Tx) *string { return tx.Value }},
+	"gas":                      {numberField, func(tx *Tx) *string { return tx.Gas }},
+	"gas_price":                {numberField, func(tx *Tx) *string { return tx.GasPrice }},
+	"max_fee_per_gas":          {numberField, func(tx *Tx) *string { return tx.MaxFeePerGas }},
+	"max_priority_fee_per_gas": {numberField, func(tx *Tx) *string { return tx.MaxPriorityFeePerGas }},
+	"type":                     {numberField, func(tx *Tx) *string { return tx.Type }},
+	"chain_id":                 {numberField, func(tx *Tx) *string { return tx.ChainID }},
+}
+
+// methodID returns the 4-byte selector of the called method.
+func methodID(tx *Tx) *string {
+	if tx.Input == nil || len(*tx.Input) < 10 {
+		return nil
+	}
+
+	id := (*tx.Input)[:10]
+	return &id
+}
+
+// Filter is a parsed filter expression.
+type Filter struct {
+	expr string
+	root node
+}
+
+// Parse parses a filter expression. The expression compares fields of a transaction in
+// braces with quoted strings or decimal numbers using ==, !=, <, <=, > and >=, or checks
+// if a field is IN a list of values in brackets, and combines the comparisons with AND, OR
+// and parentheses.
+func Parse(expr string) (*Filter, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+	}
+
+	return &Filter{expr: expr, root: root}, nil
+}
+
+// String returns the filter expression, as it is passed to the gateway.
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// Match checks if the transaction passes the filter. Comparisons of the fields the
+// transaction does not have are false.
+func (f *Filter) Match(tx *Tx) bool {
+	return f.root.match(tx)
+}
+
+type node interface {
+	match(tx *Tx) bool
+}
+
+type orNode []node
+
+func (n orNode) match(tx *Tx) bool {
+	for _, child := range n {
+		if child.match(tx) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type andNode []node
+
+func (n andNode) match(tx *Tx) bool {
+	for _, child := range n {
+		if !child.match(tx) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// comparison compares a field with the values, the field equals any of the values with the
+// IN operator.
+type comparison struct {
+	field  string
+	op     string
+	kind   int
+	value  func(tx *Tx) *string
+	values []literal
+}
+
+type literal struct {
+	str    string
+	number *big.Float
+}
+
+func (c *comparison) match(tx *Tx) bool {
+	value := c.value(tx)
+	if value == nil {
+		return false
+	}
+
+	var cmp func(l literal) int
+	if c.kind == numberField {
+		n, ok := parseNumber(*value)
+		if !ok {
+			return false
+		}
+		cmp = func(l literal) int { return n.Cmp(l.number) }
+	} else {
+		str := strings.ToLower(*value)
+		cmp = func(l literal) int { return strings.Compare(str, l.str) }
+	}
+
+	if c.op == "in" {
+		for _, l := range c.values {
+			if cmp(l) == 0 {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	res := cmp(c.values[0])
+	switch c.op {
+	case "==":
+		return res == 0
+	case "!=":
+		return res != 0
+	case "<":
+		return res < 0
+	case "<=":
+		return res <= 0
+	case ">":
+		return res > 0
+	default:
+		return res >= 0
+	}
+}
+
+// parseNumber parses a hex number of the transaction, or a decimal number.
+func parseNumber(str string) (*big.Float, bool) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	if strings.HasPrefix(str, "0x") {
+		n, ok := new(big.Int).SetString(str[2:], 16)
+		if !ok {
+			return nil, false
+		}
+
+		return new(big.Float).SetInt(n), true
+	}
+
+	n, ok := new(big.Float).SetString(str)
+	return n, ok
+}
+
+// Kinds of the tokens of a filter expression.
+const (
+	tokenEOF = iota
+	tokenField
+	tokenString
+	tokenNumber
+	tokenOp
+	tokenKeyword
+	tokenPunct
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+func tokenize(expr string) ([]token, error) {
+	var (
+		tokens []token
+		runes  = []rune(expr)
+	)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == '[' || r == ']' || r == ',':
+			tokens = append(tokens, token{tokenPunct, string(r), i})
+			i++
+		case r == '{':
+			end := indexRune(runes, i, '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated field at %d", i)
+			}
+			tokens = append(tokens, token{tokenField, strings.ToLower(strings.TrimSpace(string(runes[i+1 : end]))), i})
+			i = end + 1
+		case r == '\'' || r == '"':
+			end := indexRune(runes, i, r)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, token{tokenString, string(runes[i+1 : end]), i})
+			i = end + 1
+		case strings.ContainsRune("=!<>", r):
+			op, n := string(r), 1
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				op, n = op+"=", 2
+			}
+			switch op {
+			case "!":
+				return nil, fmt.Errorf("unexpected %q at %d", op, i)
+			case "=":
+				op = "=="
+			}
+			tokens = append(tokens, token{tokenOp, op, i})
+			i += n
+		case unicode.IsDigit(r) || r == '-' || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) ||
+				runes[i] == '.' || runes[i] == '-' || runes[i] == '+') {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[start:i]), start})
+		case unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && unicode.IsLetter(runes[i]) {
+				i++
+			}
+			word := strings.ToLower(string(runes[start:i]))
+			if word != "and" && word != "or" && word != "in" {
+				return nil, fmt.Errorf("unexpected %q at %d", word, start)
+			}
+			tokens = append(tokens, token{tokenKeyword, word, start})
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", string(r), i)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+func indexRune(runes []rune, start int, r rune) int {
+	for i := start + 1; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+
+	return -1
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+
+	return tok
+}
+
+func (p *parser) expect(kind int, text string) error {
+	if tok := p.next(); tok.kind != kind || tok.text != text {
+		return fmt.Errorf("expected %q at %d, got %q", text, tok.pos, tok.text)
+	}
+
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	return p.parseList("or", p.parseAnd, func(nodes []node) node { return orNode(nodes) })
+}
+
+func (p *parser) parseAnd() (node, error) {
+	return p.parseList("and", p.parseTerm, func(nodes []node) node { return andNode(nodes) })
+}
+
+func (p *parser) parseList(keyword string, parse func() (node, error), join func([]node) node) (node, error) {
+	first, err := parse()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []node{first}
+	for tok := p.peek(); tok.kind == tokenKeyword && tok.text == keyword; tok = p.peek() {
+		p.next()
+
+		n, err := parse()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+
+	if len(nodes) == 1 {
+		return first, nil
+	}
+
+	return join(nodes), nil
+}
+
+func (p *parser) parseTerm() (node, error) {
+	if tok := p.peek(); tok.kind == tokenPunct && tok.text == "(" {
+		p.next()
+
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		return n, p.expect(tokenPunct, ")")
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	tok := p.next()
+	if tok.kind != tokenField {
+		return nil, fmt.Errorf("expected a field in braces at %d, got %q", tok.pos, tok.text)
+	}
+
+	field, ok := fields[tok.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q at %d", tok.text, tok.pos)
+	}
+
+	c := &comparison{field: tok.text, kind: field.kind, value: field.value}
+
+	op := p.next()
+	switch {
+	case op.kind == tokenOp:
+		if field.kind == stringField && op.text != "==" && op.text != "!=" {
+			return nil, fmt.Errorf("operator %q cannot be applied to field %q at %d", op.text, c.field, op.pos)
+		}
+		c.op = op.text
+
+		l, err := p.parseLiteral(c)
+		if err != nil {
+			return nil, err
+		}
+		c.values = []literal{l}
+	case op.kind == tokenKeyword && op.text == "in":
+		c.op = "in"
+
+		if err := p.expect(tokenPunct, "["); err != nil {
+			return nil, err
+		}
+
+		for {
+			l, err := p.parseLiteral(c)
+			if err != nil {
+				return nil, err
+			}
+			c.values = append(c.values, l)
+
+			if tok := p.peek(); tok.kind == tokenPunct && tok.text == "," {
+				p.next()
+				continue
+			}
+
+			break
+		}
+
+		if err := p.expect(tokenPunct, "]"); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("expected an operator at %d, got %q", op.pos, op.text)
+	}
+
+	return c, nil
+}
+
+func (p *parser) parseLiteral(c *comparison) (literal, error) {
+	tok := p.next()
+	if tok.kind != tokenString && tok.kind != tokenNumber {
+		return literal{}, fmt.Errorf("expected a value at %d, got %q", tok.pos, tok.text)
+	}
+
+	if c.kind == stringField {
+		if tok.kind != tokenString {
+			return literal{}, fmt.Errorf("expected a quoted value of field %q at %d, got %q", c.field, tok.pos, tok.text)
+		}
+
+		return literal{str: strings.ToLower(tok.text)}, nil
+	}
+
+	n, ok := parseNumber(tok.text)
+	if !ok {
+		return literal{}, fmt.Errorf("invalid number %q of field %q at %d", tok.text, c.field, tok.pos)
+	}
+
+	return literal{number: n}, nil
+}
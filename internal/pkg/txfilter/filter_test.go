@@ -0,0 +1,69 @@
+package txfilter
+
+import "testing"
+
+func str(s string) *string {
+	return &s
+}
+
+func TestFilter(t *testing.T) {
+	tx := &Tx{
+		From:                 str("0x00000000000000000000000000000000000000AA"),
+		To:                   str("0x00000000000000000000000000000000000000bb"),
+		Value:                str("0xde0b6b3a7640000"),
+		GasPrice:             str("0x77359400"),
+		MaxPriorityFeePerGas: str("0x3b9aca00"),
+		Type:                 str("0x2"),
+		Input:                str("0xa9059cbb0000000000000000000000000000000000000000000000000000000000000001"),
+	}
+
+	for expr, expected := range map[string]bool{
+		"{from} == '0x00000000000000000000000000000000000000aa'": true,
+		"{to} = '0x00000000000000000000000000000000000000cc'":    false,
+		"{to} != '0x00000000000000000000000000000000000000cc'":   true,
+		"{gas_price} > 1e9":                                                           true,
+		"{gas_price} > 2000000000":                                                    false,
+		"{gas_price} >= 2000000000 and {value} <= 1e18":                               true,
+		"{type} IN [0, 1]":                                                            false,
+		"{type} in [2] AND {max_priority_fee_per_gas} >= 1000000000":                  true,
+		"{method_id} == '0xA9059CBB'":                                                 true,
+		"({gas} > 0) OR {method_id} IN ['0x095ea7b3', '0xa9059cbb']":                  true,
+		"({gas} > 0) OR ({to} == '0x0') OR ({chain_id} == 1)":                         false,
+		"{value} < 1e18 OR ({gas_price} < 1e10 AND ({type} == 2 OR {type} == 0))":     true,
+		"({value} < 1e18 OR {gas_price} < 1e10) AND ({type} == 0 OR {type} == 1)":     false,
+		"{max_fee_per_gas} != 0":                                                      false,
+		"{from} IN ['0x00000000000000000000000000000000000000aa', \"0xcc\"]":          true,
+		" ( ( {to} == '0x00000000000000000000000000000000000000bb' ) ) AND {gas} < 1": false,
+	} {
+		f, err := Parse(expr)
+		if err != nil {
+			t.Errorf("cannot parse %q: %v", expr, err)
+			continue
+		}
+
+		if res := f.Match(tx); res != expected {
+			t.Errorf("expected %q to be %t, got %t", expr, expected, res)
+		}
+	}
+
+	for _, expr := range []string{
+		"",
+		"{to}",
+		"{to} == ",
+		"{to} == 0x1",
+		"{to} > '0x1'",
+		"{nonce} == 1",
+		"{gas} == 'x'",
+		"{gas} IN [1, 2",
+		"({gas} == 1",
+		"{gas} == 1 {gas} == 2",
+		"{gas} == 1 XOR {gas} == 2",
+		"{gas} ! 1",
+		"{to == '0x1'",
+		"{to} == '0x1",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("expected an error parsing %q", expr)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMinBackoff = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// ErrSubscriptionClosed is returned by ResilientSubscription.NextMessage after Close was called.
+var ErrSubscriptionClosed = errors.New("subscription closed")
+
+// SubscribeFunc issues a subscription request on a freshly established connection.
+type SubscribeFunc func(conn *Connection) (*Subscription, error)
+
+// Gap describes a period of time during which a feed was not delivering messages.
+type Gap struct {
+	Start  time.Time
+	End    time.Time
+	Reason error
+}
+
+// Contains checks if t falls into the gap. A gap which has not ended yet is treated as open.
+func (g Gap) Contains(t time.Time) bool {
+	if t.Before(g.Start) {
+		return false
+	}
+
+	return g.End.IsZero() || !t.After(g.End)
+}
+
+// ReconnectOptions configures ResilientSubscription behaviour.
+type ReconnectOptions struct {
+	// MinBackoff is the delay before the first redial attempt. It doubles after every
+	// failed attempt up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// OnGap is called when an outage begins, with zero End, and once again with the same
+	// Start when the connection is re-established. The notifications dropped because the
+	// consumer fell behind are reported once, with End and ErrNotificationsDropped Reason.
+	OnGap func(gap Gap)
+
+	// OnConnect is called every time the subscription is established.
+	OnConnect func()
+}
+
+// ResilientSubscription is a subscription which redials the endpoint with backoff and
+// re-issues the original subscription request whenever the underlying socket fails.
+type ResilientSubscription struct {
+	uri       string
+	authToken string
+	subscribe SubscribeFunc
+	opts      ReconnectOptions
+
+	mu     sync.Mutex
+	conn   *Connection
+	sub    *Subscription
+	closed bool
+}
+
+// NewResilientSubscription creates a subscription to uri which is kept alive across
+// connection failures. The connection is established lazily by the first NextMessage call.
+func NewResilientSubscription(
+	uri string,
+	authToken string,
+	subscribe SubscribeFunc,
+	opts ReconnectOptions,
+) *ResilientSubscription {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultMinBackoff
+	}
+
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = defaultMaxBackoff
+	}
+
+	return &ResilientSubscription{
+		uri:       uri,
+		authToken: authToken,
+		subscribe: subscribe,
+		opts:      opts,
+	}
+}
+
+// NextMessage returns the next message from the feed. Read failures are not returned to the
+// caller, instead the connection is re-established and the outage is reported via OnGap.
+// An error is returned only when ctx is done or the subscription was closed.
+func (r *ResilientSubscription) NextMessage(ctx context.Context) (Message, error) {
+	var gap *Gap
+
+	for {
+		sub, err := r.current(ctx)
+		if err != nil {
+			return Message{}, err
+		}
+
+		if gap != nil {
+			gap.End = time.Now()
+			log.Infof("connection to %s restored after %s", r.uri, gap.End.Sub(gap.Start))
+			if r.opts.OnGap != nil {
+				r.opts.OnGap(*gap)
+			}
+			gap = nil
+		}
+
+		msg, err := sub.Next()
+		if err == nil {
+			if msg.Overflow != nil && r.opts.OnGap != nil {
+				r.opts.OnGap(*msg.Overflow)
+			}
+			return msg, nil
+		}
+
+		if r.isClosed() {
+			return Message{}, ErrSubscriptionClosed
+		}
+
+		log.Errorf("lost connection to %s: %v", r.uri, err)
+		gap = &Gap{Start: time.Now(), Reason: err}
+		if r.opts.OnGap != nil {
+			r.opts.OnGap(*gap)
+		}
+		r.reset()
+	}
+}
+
+// Close unsubscribes from the feed and closes the underlying connection.
+func (r *ResilientSubscription) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	if r.conn == nil {
+		return nil
+	}
+
+	if err := r.sub.Unsubscribe(); err != nil {
+		log.Errorf("cannot unsubscribe from %s: %v", r.uri, err)
+	}
+
+	err := r.conn.Close()
+	r.conn, r.sub = nil, nil
+
+	return err
+}
+
+func (r *ResilientSubscription) current(ctx context.Context) (*Subscription, error) {
+	r.mu.Lock()
+	sub := r.sub
+	r.mu.Unlock()
+
+	if sub != nil {
+		return sub, nil
+	}
+
+	return r.dial(ctx)
+}
+
+func (r *ResilientSubscription) dial(ctx context.Context) (*Subscription, error) {
+	backoff := r.opts.MinBackoff
+
+	for {
+		if r.isClosed() {
+			return nil, ErrSubscriptionClosed
+		}
+
+		log.Infof("Initiating connection to %s", r.uri)
+		conn, err := Dial(r.uri, r.authToken)
+		if err == nil {
+			sub, err := r.subscribe(conn)
+			if err == nil {
+				log.Infof("Connection to %s established", r.uri)
+				if r.opts.OnConnect != nil {
+					r.opts.OnConnect()
+				}
+				return r.set(conn, sub)
+			}
+
+			_ = conn.Close()
+			log.Errorf("cannot subscribe to %s: %v", r.uri, err)
+		} else {
+			log.Errorf("cannot establish connection to %s: %v", r.uri, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > r.opts.MaxBackoff {
+			backoff = r.opts.MaxBackoff
+		}
+	}
+}
+
+func (r *ResilientSubscription) set(conn *Connection, sub *Subscription) (*Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		_ = conn.Close()
+		return nil, ErrSubscriptionClosed
+	}
+
+	r.conn, r.sub = conn, sub
+
+	return sub, nil
+}
+
+func (r *ResilientSubscription) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn != nil {
+		_ = r.conn.conn.abort()
+	}
+
+	r.conn, r.sub = nil, nil
+}
+
+func (r *ResilientSubscription) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.closed
+}
@@ -0,0 +1,113 @@
+package ws
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// ipcScheme is the optional scheme of the IPC endpoints.
+const ipcScheme = "ipc://"
+
+// transport carries the JSON-RPC messages of a Connection. Writes are serialized by the
+// Connection.
+type transport interface {
+	read() ([]byte, error)
+	write(data []byte) error
+	// close closes the transport gracefully, abort closes it without notifying the peer.
+	close() error
+	abort() error
+	remoteAddr() net.Addr
+}
+
+// wsTransport sends every message as a websocket text message.
+type wsTransport struct {
+	conn *websocket.Conn
+}
+
+func (t *wsTransport) read() ([]byte, error) {
+	_, data, err := t.conn.ReadMessage()
+	return data, err
+}
+
+func (t *wsTransport) write(data []byte) error {
+	return t.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (t *wsTransport) close() error {
+	if err := t.conn.WriteMessage(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+	); err != nil {
+		_ = t.conn.Close()
+		return err
+	}
+
+	return t.conn.Close()
+}
+
+func (t *wsTransport) abort() error {
+	return t.conn.Close()
+}
+
+func (t *wsTransport) remoteAddr() net.Addr {
+	return t.conn.RemoteAddr()
+}
+
+// ipcTransport sends the messages as a stream of JSON values over a Unix socket, the way
+// the IPC endpoints of the nodes expect.
+type ipcTransport struct {
+	conn net.Conn
+	dec  *json.Decoder
+}
+
+func (t *ipcTransport) read() ([]byte, error) {
+	var msg json.RawMessage
+	if err := t.dec.Decode(&msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func (t *ipcTransport) write(data []byte) error {
+	_, err := t.conn.Write(data)
+	return err
+}
+
+func (t *ipcTransport) close() error {
+	return t.conn.Close()
+}
+
+func (t *ipcTransport) abort() error {
+	return t.conn.Close()
+}
+
+func (t *ipcTransport) remoteAddr() net.Addr {
+	return t.conn.RemoteAddr()
+}
+
+// IPCPath returns the path of the socket if the URI is an IPC endpoint.
+func IPCPath(uri string) (string, bool) {
+	if strings.HasPrefix(uri, ipcScheme) {
+		return strings.TrimPrefix(uri, ipcScheme), true
+	}
+
+	if strings.Contains(uri, "://") {
+		return "", false
+	}
+
+	return uri, uri != ""
+}
+
+// NewIPCConnection creates and initializes a new connection to the IPC socket of a node.
+func NewIPCConnection(path string) (*Connection, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConnection(&ipcTransport{conn: conn, dec: json.NewDecoder(conn)}), nil
+}
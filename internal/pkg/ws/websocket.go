@@ -1,14 +1,18 @@
 package ws
 
 import (
-	"crypto/tls"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"strings"
+	"performance/internal/pkg/tlsconfig"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
 )
 
 // Request represents data which is needed to send RPC requests to Evm node or BX gateway.
@@ -19,73 +23,131 @@ type Request struct {
 	Params  []interface{} `json:"params"`
 }
 
+// Names of the bloXroute feeds which are subscribed with specific options.
+const (
+	FeedTransactionStatus = "transactionStatus"
+	FeedTxReceipts        = "txReceipts"
+	FeedEthOnBlock        = "ethOnBlock"
+)
+
+const (
+	subscriptionBufSize = 8192
+	unsubscribeTimeout  = 5 * time.Second
+)
+
+// ErrConnectionClosed is returned by calls made on a connection which was already closed.
+var ErrConnectionClosed = errors.New("connection closed")
+
+// ErrNotificationsDropped is the reason of a gap of a subscription whose consumer fell
+// behind, so the notifications received during the gap were dropped.
+var ErrNotificationsDropped = errors.New("notifications dropped, consumer fell behind")
+
 type subscribeResponse struct {
 	Error  map[string]interface{} `json:"error"`
 	Result string                 `json:"result"`
 }
 
-// Connection is a thin wrapper around websocket connection which provides convenience methods
-// for subscribing a feed or making an RPC call.
+// frame contains the fields which are needed to route an incoming message.
+type frame struct {
+	ID     *int `json:"id"`
+	Params *struct {
+		Subscription string `json:"subscription"`
+	} `json:"params"`
+}
+
+// Message is a message read from the socket together with the time the read completed, so
+// the time spent by the message in the channels of the consumers does not bias latency.
+type Message struct {
+	Data     []byte
+	Received time.Time
+
+	// Overflow is the period of the notifications of the subscription which were dropped
+	// right before this one, if any.
+	Overflow *Gap
+}
+
+type pendingCall struct {
+	res chan Message
+	sub *Subscription
+}
+
+// Connection is a thin wrapper around websocket or IPC connection which provides convenience
+// methods for subscribing a feed or making an RPC call. A single background goroutine reads the
+// socket and routes responses to the callers by request ID and notifications to the
+// subscriptions by subscription ID, so a Connection is safe for concurrent use.
 type Connection struct {
-	conn *websocket.Conn
+	conn transport
+
+	writeMu sync.Mutex
+	lastID  int64
+
+	mu      sync.Mutex
+	pending map[int]*pendingCall
+	subs    map[string]*Subscription
+
+	done chan struct{}
+	err  error
 }
 
 // SubscribeTxFeedEvm subscribes to the ETH node feed.
-func (c *Connection) SubscribeTxFeedEvm(id int) (*Subscription, error) {
-	return c.subscribe(newSubTxFeedRequestEvm(id), eth)
+func (c *Connection) SubscribeTxFeedEvm() (*Subscription, error) {
+	return c.subscribe(newSubTxFeedRequestEvm(), eth)
 }
 
 // SubscribeTxFeedBX subscribes to BX gateway feed.
 func (c *Connection) SubscribeTxFeedBX(
-	id int,
 	feedName string,
 	excTxContents bool,
 	duplicates bool,
 	includeFromBlockchain bool,
 	useLightGateway bool,
+	filters string,
 ) (*Subscription, error) {
 	return c.subscribe(
 		newSubTxFeedRequestBX(
-			id,
 			feedName,
 			excTxContents,
 			duplicates,
 			includeFromBlockchain,
-			useLightGateway),
+			useLightGateway,
+			filters),
 		bx)
 }
 
 // SubscribeBkFeedEvm subscribes to the Evm node feed.
-func (c *Connection) SubscribeBkFeedEvm(id int) (*Subscription, error) {
-	return c.subscribe(newSubBkFeedRequestEvm(id), eth)
+func (c *Connection) SubscribeBkFeedEvm() (*Subscription, error) {
+	return c.subscribe(newSubBkFeedRequestEvm(), eth)
 }
 
 // SubscribeBkFeedBX subscribes to the BX gateway feed.
 func (c *Connection) SubscribeBkFeedBX(
-	id int,
 	feedName string,
 	excBkContents bool,
 ) (*Subscription, error) {
-	return c.subscribe(newSubBkFeedRequestBX(id, feedName, excBkContents), bx)
+	return c.subscribe(newSubBkFeedRequestBX(feedName, excBkContents), bx)
 }
 
-func (c *Connection) subscribe(req *Request, t subscriptionType) (*Subscription, error) {
-	body, err := json.Marshal(req)
-	if err != nil {
-		return nil, err
-	}
+// SubscribeEthOnBlockBX subscribes to the ethOnBlock feed of the BX gateway, which sends the
+// results of the calls executed on every block. Each call is described by its name, method
+// and the parameters of the method.
+func (c *Connection) SubscribeEthOnBlockBX(callParams []map[string]string) (*Subscription, error) {
+	return c.subscribe(newSubEthOnBlockRequestBX(callParams), bx)
+}
 
-	if err = c.conn.WriteMessage(websocket.TextMessage, body); err != nil {
-		return nil, err
+func (c *Connection) subscribe(req *Request, t subscriptionType) (*Subscription, error) {
+	sub := &Subscription{
+		Conn:     c,
+		Type:     t,
+		messages: make(chan Message, subscriptionBufSize),
 	}
 
-	_, data, err := c.conn.ReadMessage()
+	msg, err := c.call(context.Background(), req, sub)
 	if err != nil {
 		return nil, err
 	}
 
 	var res subscribeResponse
-	if err = json.Unmarshal(data, &res); err != nil {
+	if err = json.Unmarshal(msg.Data, &res); err != nil {
 		return nil, err
 	}
 
@@ -93,63 +155,210 @@ func (c *Connection) subscribe(req *Request, t subscriptionType) (*Subscription,
 		return nil, fmt.Errorf("error from RPC: %v", res.Error)
 	}
 
-	return &Subscription{
-		ID:   res.Result,
-		Conn: c,
-		Type: t,
-	}, nil
+	return sub, nil
 }
 
-// Call is a convenience method to make an RPC call.
+// Call is a convenience method to make an RPC call. The request ID is replaced with a
+// unique one, so the response is matched to the request regardless of other traffic.
 func (c *Connection) Call(req *Request) ([]byte, error) {
-	body, err := json.Marshal(req)
+	msg, err := c.call(context.Background(), req, nil)
+	return msg.Data, err
+}
+
+// CallContext is like Call but stops waiting for the response when ctx is done.
+func (c *Connection) CallContext(ctx context.Context, req *Request) ([]byte, error) {
+	msg, err := c.call(ctx, req, nil)
+	return msg.Data, err
+}
+
+// CallMessage is like CallContext but also returns the time the response was read.
+func (c *Connection) CallMessage(ctx context.Context, req *Request) (Message, error) {
+	return c.call(ctx, req, nil)
+}
+
+func (c *Connection) call(ctx context.Context, req *Request, sub *Subscription) (Message, error) {
+	var (
+		id   = int(atomic.AddInt64(&c.lastID, 1))
+		call = &pendingCall{res: make(chan Message, 1), sub: sub}
+	)
+
+	body, err := json.Marshal(&Request{
+		JSONRPC: req.JSONRPC,
+		ID:      id,
+		Method:  req.Method,
+		Params:  req.Params,
+	})
 	if err != nil {
-		return nil, err
+		return Message{}, err
 	}
 
-	if err = c.conn.WriteMessage(websocket.TextMessage, body); err != nil {
-		return nil, err
+	c.mu.Lock()
+	if c.pending == nil {
+		c.mu.Unlock()
+		return Message{}, c.closeErr()
+	}
+	c.pending[id] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		if c.pending != nil {
+			delete(c.pending, id)
+		}
+		c.mu.Unlock()
+	}()
+
+	if err = c.write(body); err != nil {
+		return Message{}, err
+	}
+
+	select {
+	case msg := <-call.res:
+		return msg, nil
+	case <-c.done:
+		return Message{}, c.closeErr()
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	}
+}
+
+func (c *Connection) write(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.conn.write(data)
+}
+
+func (c *Connection) readMessages() {
+	for {
+		data, err := c.conn.read()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+
+		c.dispatch(Message{Data: data, Received: time.Now()})
+	}
+}
+
+func (c *Connection) dispatch(msg Message) {
+	var f frame
+	if err := json.Unmarshal(msg.Data, &f); err != nil {
+		log.Errorf("cannot parse message from %s: %v", c.conn.remoteAddr(), err)
+		return
 	}
 
-	_, data, err := c.conn.ReadMessage()
+	if f.ID != nil {
+		c.mu.Lock()
+		call, ok := c.pending[*f.ID]
+		if ok && call.sub != nil {
+			// Register the subscription before the caller is notified, so that
+			// notifications following the response are not lost.
+			var res subscribeResponse
+			if err := json.Unmarshal(msg.Data, &res); err == nil && res.Error == nil {
+				call.sub.ID = res.Result
+				c.subs[res.Result] = call.sub
+			}
+		}
+		c.mu.Unlock()
+
+		if ok {
+			// The reader never waits for a caller: res has room for the response, so
+			// only a duplicate response for the same ID finds it full.
+			select {
+			case call.res <- msg:
+			default:
+				log.Debugf("dropping duplicate response %d from %s", *f.ID, c.conn.remoteAddr())
+			}
+			return
+		}
+	}
+
+	if f.Params != nil && f.Params.Subscription != "" {
+		c.mu.Lock()
+		sub, ok := c.subs[f.Params.Subscription]
+		c.mu.Unlock()
+
+		if ok {
+			sub.deliver(msg)
+			return
+		}
+	}
 
-	return data, err
+	log.Debugf("dropping unexpected message from %s: %s", c.conn.remoteAddr(), msg.Data)
+}
+
+func (c *Connection) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending == nil {
+		return
+	}
+
+	c.err = err
+	c.pending = nil
+	c.subs = nil
+	close(c.done)
+}
+
+func (c *Connection) closeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err == nil {
+		return ErrConnectionClosed
+	}
+
+	return c.err
 }
 
 // Close closes a connection.
 func (c *Connection) Close() error {
-	if err := c.conn.WriteMessage(
-		websocket.CloseMessage,
-		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
-	); err != nil {
-		return err
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.conn.close()
+}
+
+// Dial creates a connection to the websocket or IPC endpoint of the URI. An IPC endpoint is
+// the path of the socket, optionally with the ipc:// scheme.
+func Dial(uri, authToken string) (*Connection, error) {
+	if path, ok := IPCPath(uri); ok {
+		return NewIPCConnection(path)
 	}
 
-	return c.conn.Close()
+	return NewConnection(uri, authToken)
 }
 
-// NewConnection creates and initializes a new websocket connection.
+// NewConnection creates and initializes a new websocket connection. Secure connections use
+// the configuration of the tlsconfig package.
 func NewConnection(uri, authToken string) (*Connection, error) {
 	header := http.Header{}
 	if authToken != "" {
 		header.Add("Authorization", authToken)
 	}
 
-	tlsConfig := tls.Config{}
-	if strings.Contains(uri, "wss") {
-		tlsConfig.InsecureSkipVerify = true
-	}
-	dialer := websocket.Dialer{TLSClientConfig: &tlsConfig}
+	dialer := websocket.Dialer{TLSClientConfig: tlsconfig.Config(uri)}
 
 	conn, resp, err := dialer.Dial(uri, header)
 	if err != nil {
 		return nil, err
 	}
-	err = resp.Body.Close()
 
-	return &Connection{
-		conn: conn,
-	}, err
+	return newConnection(&wsTransport{conn: conn}), resp.Body.Close()
+}
+
+func newConnection(conn transport) *Connection {
+	c := &Connection{
+		conn:    conn,
+		pending: make(map[int]*pendingCall),
+		subs:    make(map[string]*Subscription),
+		done:    make(chan struct{}),
+	}
+	go c.readMessages()
+
+	return c
 }
 
 type subscriptionType byte
@@ -164,56 +373,121 @@ type Subscription struct {
 	ID   string
 	Conn *Connection
 	Type subscriptionType
+
+	messages chan Message
+	dropped  uint64
+	// overflow is the period of the notifications dropped since the last delivered one, it
+	// is used only from the reader goroutine.
+	overflow *Gap
+}
+
+// deliver queues a notification for the consumer of the subscription. The notification is
+// dropped when the consumer falls subscriptionBufSize messages behind, so a slow consumer
+// never stalls the reader and the calls made on the same connection. The next delivered
+// notification carries the period of the dropped ones.
+func (s *Subscription) deliver(msg Message) {
+	if s.overflow != nil {
+		gap := *s.overflow
+		msg.Overflow = &gap
+	}
+
+	select {
+	case s.messages <- msg:
+		s.overflow = nil
+		return
+	default:
+	}
+
+	if atomic.AddUint64(&s.dropped, 1) == 1 {
+		log.Warnf("subscription %s fell behind, dropping notifications", s.ID)
+	}
+
+	if s.overflow == nil {
+		s.overflow = &Gap{Start: msg.Received, Reason: ErrNotificationsDropped}
+	}
+	s.overflow.End = msg.Received
+}
+
+// Dropped returns the number of notifications which were dropped because the consumer of
+// the subscription fell behind.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
 }
 
 // Unsubscribe unsubscribes from the feed.
 func (s *Subscription) Unsubscribe() error {
 	switch s.Type {
 	case bx:
-		return s.unsubscribe(NewRequest(1, "unsubscribe", []interface{}{s.ID}))
+		return s.unsubscribe(NewRequest(0, "unsubscribe", []interface{}{s.ID}))
 	case eth:
-		return s.unsubscribe(NewRequest(1, "eth_unsubscribe", []interface{}{s.ID}))
+		return s.unsubscribe(NewRequest(0, "eth_unsubscribe", []interface{}{s.ID}))
 	}
 
 	return fmt.Errorf("unknown subscription type: %d", s.Type)
 }
 
 func (s *Subscription) unsubscribe(req *Request) error {
-	body, err := json.Marshal(req)
-	if err != nil {
-		return err
+	s.Conn.mu.Lock()
+	if s.Conn.subs != nil {
+		delete(s.Conn.subs, s.ID)
 	}
+	s.Conn.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), unsubscribeTimeout)
+	defer cancel()
+
+	_, err := s.Conn.CallContext(ctx, req)
 
-	return s.Conn.conn.WriteMessage(websocket.TextMessage, body)
+	return err
 }
 
 // NextMessage is a convenience method which reads and returns the next data item from the feed.
 func (s *Subscription) NextMessage() ([]byte, error) {
-	_, r, err := s.Conn.conn.NextReader()
+	msg, err := s.Next()
+	return msg.Data, err
+}
 
-	if err != nil {
-		return nil, err
+// Next returns the next message from the feed together with the time it was read.
+func (s *Subscription) Next() (Message, error) {
+	select {
+	case msg := <-s.messages:
+		return msg, nil
+	case <-s.Conn.done:
 	}
 
-	return ioutil.ReadAll(r)
+	// Deliver messages which were received before the connection was closed.
+	select {
+	case msg := <-s.messages:
+		return msg, nil
+	default:
+		return Message{}, s.Conn.closeErr()
+	}
 }
 
-func newSubTxFeedRequestEvm(id int) *Request {
-	return NewRequest(id, "eth_subscribe", []interface{}{
+func newSubTxFeedRequestEvm() *Request {
+	return NewRequest(0, "eth_subscribe", []interface{}{
 		"newPendingTransactions",
 	})
 }
 
 func newSubTxFeedRequestBX(
-	id int,
 	feedName string,
 	excTxContents bool,
 	duplicates bool,
 	incFromBlockchain bool,
 	useGoGateway bool,
+	filters string,
 ) *Request {
 	options := make(map[string]interface{})
 
+	if feedName == FeedTransactionStatus {
+		options["include"] = []string{"tx_hash", "status"}
+
+		return NewRequest(0, "subscribe", []interface{}{
+			feedName, options,
+		})
+	}
+
 	if !useGoGateway {
 		options["duplicates"] = duplicates
 		options["include_from_blockchain"] = incFromBlockchain
@@ -225,31 +499,51 @@ func newSubTxFeedRequestBX(
 		options["include"] = []string{"tx_hash", "tx_contents"}
 	}
 
-	return NewRequest(id, "subscribe", []interface{}{
+	if filters != "" {
+		options["filters"] = filters
+	}
+
+	return NewRequest(0, "subscribe", []interface{}{
 		feedName, options,
 	})
 }
 
-func newSubBkFeedRequestEvm(id int) *Request {
-	return NewRequest(id, "eth_subscribe", []interface{}{
+func newSubBkFeedRequestEvm() *Request {
+	return NewRequest(0, "eth_subscribe", []interface{}{
 		"newHeads",
 	})
 }
 
-func newSubBkFeedRequestBX(id int, feedName string, excBkContents bool) *Request {
+func newSubBkFeedRequestBX(feedName string, excBkContents bool) *Request {
 	options := make(map[string]interface{})
 
-	if excBkContents {
+	if feedName == FeedTxReceipts {
+		options["include"] = []string{
+			"receipt.block_hash",
+			"receipt.block_number",
+			"receipt.transaction_hash",
+			"receipt.status",
+		}
+	} else if excBkContents {
 		options["include"] = []string{"hash", "header"}
 	} else {
 		options["include"] = []string{"hash", "header", "transactions", "uncles"}
 	}
 
-	return NewRequest(id, "subscribe", []interface{}{
+	return NewRequest(0, "subscribe", []interface{}{
 		feedName, options,
 	})
 }
 
+func newSubEthOnBlockRequestBX(callParams []map[string]string) *Request {
+	return NewRequest(0, "subscribe", []interface{}{
+		FeedEthOnBlock, map[string]interface{}{
+			"include":     []string{"name", "response", "block_height", "tag"},
+			"call_params": callParams,
+		},
+	})
+}
+
 // NewRequest is a convenience method to create a Request struct.
 func NewRequest(id int, method string, params []interface{}) *Request {
 	return &Request{
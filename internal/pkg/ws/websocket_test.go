@@ -0,0 +1,214 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newReversingServer answers subscription requests immediately, but holds back other calls
+// until two of them are pending and then answers them in reverse order, interleaved with
+// subscription notifications.
+func newReversingServer(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("cannot upgrade connection: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		var held []Request
+		for {
+			var req Request
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+
+			if req.Method == "eth_subscribe" {
+				_ = conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": "0xsub"})
+				continue
+			}
+
+			if held = append(held, req); len(held) < 2 {
+				continue
+			}
+
+			for i := len(held) - 1; i >= 0; i-- {
+				_ = conn.WriteJSON(map[string]interface{}{
+					"jsonrpc": "2.0",
+					"method":  "eth_subscription",
+					"params":  map[string]interface{}{"subscription": "0xsub", "result": i},
+				})
+				_ = conn.WriteJSON(map[string]interface{}{
+					"jsonrpc": "2.0", "id": held[i].ID, "result": held[i].Params[0],
+				})
+			}
+			held = nil
+		}
+	}))
+}
+
+func TestConnectionMultiplexing(t *testing.T) {
+	server := newReversingServer(t)
+	defer server.Close()
+
+	conn, err := NewConnection("ws"+strings.TrimPrefix(server.URL, "http"), "")
+	if err != nil {
+		t.Fatalf("cannot connect: %v", err)
+	}
+	defer conn.Close()
+
+	sub, err := conn.SubscribeTxFeedEvm()
+	if err != nil {
+		t.Fatalf("cannot subscribe: %v", err)
+	}
+
+	if sub.ID != "0xsub" {
+		t.Fatalf("unexpected subscription ID %q", sub.ID)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(param string) {
+			defer wg.Done()
+
+			data, err := conn.Call(NewRequest(1, "echo", []interface{}{param}))
+			if err != nil {
+				t.Errorf("call failed: %v", err)
+				return
+			}
+
+			var res struct {
+				Result string `json:"result"`
+			}
+			if err := json.Unmarshal(data, &res); err != nil {
+				t.Errorf("cannot parse response: %v", err)
+				return
+			}
+
+			if res.Result != param {
+				t.Errorf("expected response %q, got %q", param, res.Result)
+			}
+		}(fmt.Sprintf("call-%d", i))
+	}
+	wg.Wait()
+
+	// The notifications preceded the responses, so they were read from the socket before
+	// the calls returned.
+	callsDone := time.Now()
+
+	for i := 0; i < 2; i++ {
+		msg, err := sub.Next()
+		if err != nil {
+			t.Fatalf("cannot read notification: %v", err)
+		}
+
+		if !strings.Contains(string(msg.Data), `"subscription":"0xsub"`) {
+			t.Fatalf("unexpected notification: %s", msg.Data)
+		}
+
+		if msg.Received.IsZero() || !msg.Received.Before(callsDone) {
+			t.Errorf("expected notification to be stamped before %s, got %s", callsDone, msg.Received)
+		}
+	}
+}
+
+// blockingTransport never delivers a message, the test feeds the connection via dispatch.
+type blockingTransport struct {
+	closed chan struct{}
+}
+
+func (t *blockingTransport) read() ([]byte, error) {
+	<-t.closed
+	return nil, ErrConnectionClosed
+}
+
+func (t *blockingTransport) write([]byte) error { return nil }
+func (t *blockingTransport) close() error       { close(t.closed); return nil }
+func (t *blockingTransport) abort() error       { return t.close() }
+func (t *blockingTransport) remoteAddr() net.Addr {
+	return &net.UnixAddr{Name: "test", Net: "unix"}
+}
+
+func TestDispatchDoesNotBlockOnSlowConsumer(t *testing.T) {
+	conn := newConnection(&blockingTransport{closed: make(chan struct{})})
+	defer conn.Close()
+
+	sub := &Subscription{ID: "0xsub", Conn: conn, Type: eth, messages: make(chan Message, 1)}
+	call := &pendingCall{res: make(chan Message, 1)}
+
+	conn.mu.Lock()
+	conn.subs[sub.ID] = sub
+	conn.pending[7] = call
+	conn.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 3; i++ {
+			conn.dispatch(Message{Data: []byte(`{"params":{"subscription":"0xsub","result":1}}`)})
+		}
+		for i := 0; i < 2; i++ {
+			conn.dispatch(Message{Data: []byte(`{"id":7,"result":"0x1"}`)})
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("dispatch blocked on a full subscription or a duplicate response")
+	}
+
+	if dropped := sub.Dropped(); dropped != 2 {
+		t.Errorf("expected 2 dropped notifications, got %d", dropped)
+	}
+
+	if msg := <-call.res; string(msg.Data) != `{"id":7,"result":"0x1"}` {
+		t.Errorf("unexpected response %s", msg.Data)
+	}
+}
+
+func TestSubscriptionOverflow(t *testing.T) {
+	var (
+		sub   = &Subscription{ID: "0xsub", Type: eth, messages: make(chan Message, 1)}
+		start = time.Now()
+		at    = func(i int) time.Time { return start.Add(time.Duration(i) * time.Second) }
+	)
+
+	for i := 0; i < 3; i++ {
+		sub.deliver(Message{Received: at(i)})
+	}
+
+	if msg := <-sub.messages; msg.Overflow != nil {
+		t.Errorf("expected no overflow before the dropped notifications, got %+v", msg.Overflow)
+	}
+
+	sub.deliver(Message{Received: at(3)})
+
+	msg := <-sub.messages
+	if msg.Overflow == nil {
+		t.Fatal("expected the overflow of the dropped notifications")
+	}
+
+	if !msg.Overflow.Start.Equal(at(1)) || !msg.Overflow.End.Equal(at(2)) || msg.Overflow.Reason != ErrNotificationsDropped {
+		t.Errorf("expected overflow from %s to %s, got %+v", at(1), at(2), msg.Overflow)
+	}
+
+	sub.deliver(Message{Received: at(4)})
+	if msg := <-sub.messages; msg.Overflow != nil {
+		t.Errorf("expected the overflow to be reported once, got %+v", msg.Overflow)
+	}
+}
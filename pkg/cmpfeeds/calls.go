@@ -0,0 +1,207 @@
+package cmpfeeds
+
+import (
+	"encoding/json"
+	"fmt"
+	"performance/internal/pkg/ws"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ethCallOptions are the parameters of the methods the ethOnBlock feed can call, the
+// first parameters of a method are required.
+var ethCallOptions = map[string]struct {
+	options  []string
+	required int
+}{
+	"eth_blockNumber":         {},
+	"eth_getBalance":          {options: []string{"address"}, required: 1},
+	"eth_getTransactionCount": {options: []string{"address"}, required: 1},
+	"eth_getCode":             {options: []string{"address"}, required: 1},
+	"eth_getStorageAt":        {options: []string{"address", "pos"}, required: 2},
+	"eth_call":                {options: []string{"to", "from", "gas", "gasPrice", "value", "data"}, required: 1},
+}
+
+// defaultCall is called if no calls are specified for the ethOnBlock feed.
+const defaultCall = "height=eth_blockNumber"
+
+// ethCall is a call executed on every block by the ethOnBlock feed of the gateway and by the
+// node, to compare the time of the results.
+type ethCall struct {
+	name    string
+	method  string
+	options map[string]string
+}
+
+// parseCalls parses values of the repeatable call flag. Each call has a form of
+// name=method[,option=value...]. The cli library splits flag values on commas, so the
+// options are received as separate items and are applied to the preceding call.
+func parseCalls(values []string) ([]*ethCall, error) {
+	if len(values) == 0 {
+		values = []string{defaultCall}
+	}
+
+	var (
+		calls []*ethCall
+		names = make(map[string]struct{})
+	)
+
+	for _, value := range values {
+		kv := strings.SplitN(strings.TrimSpace(value), "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid call %q, expected name=method[,option=value]", value)
+		}
+
+		key, val := kv[0], kv[1]
+		if isCallOption(key) {
+			if len(calls) == 0 {
+				return nil, fmt.Errorf("option %q must follow a call name=method", value)
+			}
+
+			call := calls[len(calls)-1]
+			if !call.hasOption(key) {
+				return nil, fmt.Errorf("invalid option %q of call %q of method %s", key, call.name, call.method)
+			}
+			call.options[key] = val
+			continue
+		}
+
+		if _, ok := ethCallOptions[val]; !ok {
+			return nil, fmt.Errorf("unsupported method %q of call %q", val, key)
+		}
+
+		if _, ok := names[key]; ok {
+			return nil, fmt.Errorf("duplicate call name %q", key)
+		}
+
+		names[key] = struct{}{}
+		calls = append(calls, &ethCall{name: key, method: val, options: make(map[string]string)})
+	}
+
+	for _, call := range calls {
+		method := ethCallOptions[call.method]
+		for _, option := range method.options[:method.required] {
+			if call.options[option] == "" {
+				return nil, fmt.Errorf("option %q of call %q is not specified", option, call.name)
+			}
+		}
+	}
+
+	return calls, nil
+}
+
+func isCallOption(key string) bool {
+	for _, method := range ethCallOptions {
+		for _, option := range method.options {
+			if option == key {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (c *ethCall) hasOption(key string) bool {
+	for _, option := range ethCallOptions[c.method].options {
+		if option == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// callParams returns the description of the call for the ethOnBlock subscription.
+func (c *ethCall) callParams() map[string]string {
+	res := map[string]string{"name": c.name, "method": c.method}
+	for key, value := range c.options {
+		res[key] = value
+	}
+
+	return res
+}
+
+// request returns the request executing the call on the block with the given number.
+func (c *ethCall) request(number uint64) *ws.Request {
+	var (
+		block  = hexutil.EncodeUint64(number)
+		params []interface{}
+	)
+
+	switch c.method {
+	case "eth_blockNumber":
+		params = []interface{}{}
+	case "eth_getStorageAt":
+		params = []interface{}{c.options["address"], c.options["pos"], block}
+	case "eth_call":
+		params = []interface{}{c.options, block}
+	default:
+		params = []interface{}{c.options["address"], block}
+	}
+
+	return ws.NewRequest(1, c.method, params)
+}
+
+// callResult is the result of a call of the ethOnBlock feed.
+type callResult struct {
+	Name        string          `json:"name"`
+	BlockHeight hexutil.Uint64  `json:"blockHeight"`
+	Response    json.RawMessage `json:"response"`
+}
+
+// callResultMessage is the message of the ethOnBlock feed. The results of the calls
+// executed by the node are wrapped in the same message, so they are processed and
+// recorded the same way.
+type callResultMessage struct {
+	Params struct {
+		Result callResult `json:"result"`
+	} `json:"params"`
+}
+
+// nodeCallResult wraps the response of the node to the call executed on the block in a
+// message of the ethOnBlock feed.
+func nodeCallResult(call *ethCall, number uint64, data []byte) ([]byte, error) {
+	var res struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal response of call %q: %v", call.name, err)
+	}
+
+	if res.Error != nil {
+		return nil, fmt.Errorf("call %q failed: %s (%d)", call.name, res.Error.Message, res.Error.Code)
+	}
+
+	var msg callResultMessage
+	msg.Params.Result = callResult{
+		Name:        call.name,
+		BlockHeight: hexutil.Uint64(number),
+		Response:    res.Result,
+	}
+
+	return json.Marshal(msg)
+}
+
+// parseCallContents parses the response of a call of the ethOnBlock feed, so the responses
+// of the gateway and the node can be verified.
+func parseCallContents(data []byte) (*blockContents, error) {
+	var msg callResultMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal call result: %v", err)
+	}
+
+	var response interface{}
+	if err := json.Unmarshal(msg.Params.Result.Response, &response); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal response of call %q: %v", msg.Params.Result.Name, err)
+	}
+
+	return &blockContents{
+		fields: map[string]string{"response": strings.ToLower(fmt.Sprint(response))},
+	}, nil
+}
@@ -0,0 +1,47 @@
+package cmpfeeds
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCalls(t *testing.T) {
+	calls, err := parseCalls([]string{
+		"balance=eth_getBalance", "address=0xabc",
+		"slot=eth_getStorageAt", "address=0xabc", "pos=0x1",
+		"height=eth_blockNumber",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(calls) != 3 {
+		t.Fatalf("expected 3 calls, got %d", len(calls))
+	}
+
+	expected := map[string]string{"name": "slot", "method": "eth_getStorageAt", "address": "0xabc", "pos": "0x1"}
+	if params := calls[1].callParams(); !reflect.DeepEqual(params, expected) {
+		t.Errorf("expected call params %v, got %v", expected, params)
+	}
+
+	req := calls[1].request(16)
+	if req.Method != "eth_getStorageAt" || !reflect.DeepEqual(req.Params, []interface{}{"0xabc", "0x1", "0x10"}) {
+		t.Errorf("unexpected request %+v", req)
+	}
+
+	if calls, err = parseCalls(nil); err != nil || len(calls) != 1 || calls[0].method != "eth_blockNumber" {
+		t.Errorf("expected the default call, got %v: %v", calls, err)
+	}
+
+	for _, values := range [][]string{
+		{"address=0xabc"},
+		{"balance=eth_getBalance"},
+		{"balance=eth_getBalance", "pos=0x1"},
+		{"logs=eth_getLogs"},
+		{"height=eth_blockNumber", "height=eth_blockNumber"},
+	} {
+		if _, err := parseCalls(values); err == nil {
+			t.Errorf("expected error for %q", values)
+		}
+	}
+}
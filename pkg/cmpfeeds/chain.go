@@ -0,0 +1,129 @@
+package cmpfeeds
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// maxReorgDepth is the number of heights below the head the chain views keep between
+// intervals, reorgs deeper than that are not detected.
+const maxReorgDepth = 256
+
+// blockKey identifies a block at its height, so competing blocks at the same height are
+// distinct entries. The results of the ethOnBlock feed are identified by the height and the
+// name of the call instead.
+type blockKey struct {
+	number uint64
+	hash   string
+	call   string
+}
+
+// id returns the hash of the block or the name and the height of the call.
+func (k blockKey) id() string {
+	if k.call != "" {
+		return fmt.Sprintf("%s@%d", k.call, k.number)
+	}
+
+	return k.hash
+}
+
+// blockHeader is the part of an announced block which places it in the chain.
+type blockHeader struct {
+	Number     hexutil.Uint64 `json:"number"`
+	Hash       string         `json:"hash"`
+	ParentHash string         `json:"parentHash"`
+}
+
+func (h blockHeader) key() blockKey {
+	return blockKey{number: uint64(h.Number), hash: h.Hash}
+}
+
+// reorg is a change of the chain announced by a source which replaced depth blocks.
+type reorg struct {
+	source int
+	depth  int
+}
+
+// chainView is the chain as announced by a feed. A block announced above the head replaces
+// the blocks of the view which are not its ancestors. A block announced at or below the
+// head, which is not part of the view, replaces the blocks from its height up only if the
+// feed announces reorgs, as newHeads does. Other feeds, e.g. bdnBlocks, deliver competing
+// blocks late as well, so such a block is a sibling which leaves the view unchanged until a
+// block above the head links to it through its parents.
+type chainView struct {
+	hashes          map[uint64]string
+	head            uint64
+	announcesReorgs bool
+}
+
+func newChainView(announcesReorgs bool) *chainView {
+	return &chainView{hashes: make(map[uint64]string), announcesReorgs: announcesReorgs}
+}
+
+// add makes the block the head of the view and returns the number of replaced blocks. The
+// ancestors of the block are looked up in parents to find where the chains diverged. A
+// sibling, which does not change the view, returns 0.
+func (v *chainView) add(b blockHeader, parents map[string]blockHeader) int {
+	number := uint64(b.Number)
+	if v.hashes[number] == b.Hash {
+		return 0
+	}
+
+	if number <= v.head && len(v.hashes) > 0 && !v.announcesReorgs {
+		return 0
+	}
+
+	depth := 0
+	for n := number; n <= v.head; n++ {
+		if _, ok := v.hashes[n]; ok {
+			delete(v.hashes, n)
+			depth++
+		}
+	}
+
+	for n, hash := number, b.ParentHash; n > 0 && hash != ""; {
+		n--
+
+		old, ok := v.hashes[n]
+		if !ok || old == hash {
+			break
+		}
+
+		v.hashes[n] = hash
+		depth++
+
+		parent, ok := parents[hash]
+		if !ok {
+			break
+		}
+		hash = parent.ParentHash
+	}
+
+	v.hashes[number] = b.Hash
+	v.head = number
+
+	return depth
+}
+
+// contains checks if the block is part of the view.
+func (v *chainView) contains(key blockKey) bool {
+	return v.hashes[key.number] == key.hash
+}
+
+// prune drops the heights more than maxReorgDepth below the head.
+func (v *chainView) prune() {
+	for n := range v.hashes {
+		if n+maxReorgDepth < v.head {
+			delete(v.hashes, n)
+		}
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
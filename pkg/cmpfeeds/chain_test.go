@@ -0,0 +1,82 @@
+package cmpfeeds
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestChainViewAdd(t *testing.T) {
+	block := func(number uint64, hash, parent string) blockHeader {
+		return blockHeader{Number: hexutil.Uint64(number), Hash: hash, ParentHash: parent}
+	}
+
+	tests := []struct {
+		name            string
+		announcesReorgs bool
+		blocks          []blockHeader
+		depths          []int
+		head            uint64
+		canonical       []string
+	}{
+		{
+			name:      "sibling below head",
+			blocks:    []blockHeader{block(1, "a1", ""), block(2, "a2", "a1"), block(3, "a3", "a2"), block(2, "b2", "a1")},
+			depths:    []int{0, 0, 0, 0},
+			head:      3,
+			canonical: []string{"a1", "a2", "a3"},
+		},
+		{
+			name:      "sibling at head",
+			blocks:    []blockHeader{block(1, "a1", ""), block(2, "a2", "a1"), block(2, "b2", "a1")},
+			depths:    []int{0, 0, 0},
+			head:      2,
+			canonical: []string{"a1", "a2"},
+		},
+		{
+			name:      "block built on sibling",
+			blocks:    []blockHeader{block(1, "a1", ""), block(2, "a2", "a1"), block(2, "b2", "a1"), block(3, "b3", "b2")},
+			depths:    []int{0, 0, 0, 1},
+			head:      3,
+			canonical: []string{"a1", "b2", "b3"},
+		},
+		{
+			name:            "announced reorg below head",
+			announcesReorgs: true,
+			blocks:          []blockHeader{block(1, "a1", ""), block(2, "a2", "a1"), block(3, "a3", "a2"), block(2, "b2", "a1")},
+			depths:          []int{0, 0, 0, 2},
+			head:            2,
+			canonical:       []string{"a1", "b2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				view    = newChainView(tt.announcesReorgs)
+				parents = make(map[string]blockHeader)
+			)
+
+			for i, b := range tt.blocks {
+				parents[b.Hash] = b
+				if depth := view.add(b, parents); depth != tt.depths[i] {
+					t.Errorf("expected block %s to replace %d blocks, got %d", b.Hash, tt.depths[i], depth)
+				}
+			}
+
+			if view.head != tt.head {
+				t.Errorf("expected head %d, got %d", tt.head, view.head)
+			}
+
+			for i, hash := range tt.canonical {
+				if key := (blockKey{number: uint64(i + 1), hash: hash}); !view.contains(key) {
+					t.Errorf("expected block %s at height %d to be in the view", hash, key.number)
+				}
+			}
+
+			if len(view.hashes) != len(tt.canonical) {
+				t.Errorf("expected %d blocks in the view, got %d", len(tt.canonical), len(view.hashes))
+			}
+		})
+	}
+}
@@ -9,35 +9,81 @@ import (
 	"math"
 	"os"
 	"performance/internal/pkg/flags"
+	"performance/internal/pkg/metrics"
+	"performance/internal/pkg/report"
+	"performance/internal/pkg/stats"
 	"performance/internal/pkg/utils"
 	"performance/internal/pkg/ws"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 )
 
+// Names of the sources in the block feeds comparison metrics.
+const (
+	sourceGateway = "gateway"
+	sourceNode    = "node"
+)
+
+// Indices of the sources of the block feed messages.
+const (
+	gatewayIndex = iota
+	nodeIndex
+)
+
+var sourceNames = [...]string{gatewayIndex: sourceGateway, nodeIndex: sourceNode}
+
 // BkFeedsCompareService represents a service which compares block feeds time difference
 // between EVM node and BX gateway.
 type BkFeedsCompareService struct {
 	handlers chan handler
-	evmCh    chan *message
+	feeds    *feedQueue
 	evmBkCh  chan *message
-	bxCh     chan *message
 
-	hashes chan string
+	// hashes queues the blocks whose contents should be fetched from the node, dropped is
+	// the number of blocks dropped because the queue was full and droppedHashes are the
+	// blocks dropped during the current interval, which are excluded from the comparison.
+	hashes        chan string
+	dropped       int
+	droppedHashes utils.HashSet
 
 	trailNewHashes        utils.HashSet
 	leadNewHashes         utils.HashSet
-	seenHashes            map[string]*hashEntry
+	seenHashes            map[blockKey]*hashEntry
 	timeToBeginComparison time.Time
 	timeToEndComparison   time.Time
+	intervalStart         time.Time
 	numIntervals          int
 
-	excBkContents bool
-	feedName      string
+	bxGaps  []ws.Gap
+	evmGaps []ws.Gap
+
+	// blocks are the announced blocks by hash, views are the chains announced by each of the
+	// sources and canonical is the chain combined from both of them.
+	blocks    map[string]blockHeader
+	views     [2]*chainView
+	canonical *chainView
+	reorgs    []reorg
+
+	// clock is the real time, or the recorded time if the comparison is replayed.
+	clock  clock
+	replay *replayer
+
+	histogramBounds []float64
+	runStats        *bkStats
+	runLatency      *latencyStats
+	metrics         *metrics.Feed
+	ignoreDelta     time.Duration
+
+	excBkContents    bool
+	verifyBkContents bool
+	feedName         string
+	calls            []*ethCall
 
 	allHashesFile     *csv.Writer
 	missingHashesFile *bufio.Writer
@@ -46,21 +92,87 @@ type BkFeedsCompareService struct {
 // NewBkFeedsCompareService creates and initializes BkFeedsCompareService instance.
 func NewBkFeedsCompareService() *BkFeedsCompareService {
 	const bufSize = 8192
+	m := metrics.NewFeed(bkFeed)
 	return &BkFeedsCompareService{
 		handlers:       make(chan handler),
-		bxCh:           make(chan *message),
-		evmCh:          make(chan *message),
+		feeds:          newFeedQueue(m),
 		evmBkCh:        make(chan *message, bufSize),
 		hashes:         make(chan string, bufSize),
+		droppedHashes:  utils.NewHashSet(),
 		trailNewHashes: utils.NewHashSet(),
 		leadNewHashes:  utils.NewHashSet(),
-		seenHashes:     make(map[string]*hashEntry),
+		seenHashes:     make(map[blockKey]*hashEntry),
+		blocks:         make(map[string]blockHeader),
+		views:          [2]*chainView{newChainView(false), newChainView(true)},
+		canonical:      newChainView(false),
+		runStats:       &bkStats{},
+		runLatency:     newLatencyStats(),
+		metrics:        m,
+		clock:          realClock{},
 	}
 }
 
 // Run is an entry point to the BkFeedsCompareService.
 func (s *BkFeedsCompareService) Run(c *cli.Context) error {
+	var (
+		bxURI  = c.String(flags.Gateway.Name)
+		evmURI = c.String(flags.FeedWSEndpoint.Name)
+	)
+
+	if c.Bool(flags.UseCloudAPI.Name) {
+		bxURI = c.String(flags.CloudAPIWSURI.Name)
+	}
+
 	s.excBkContents = c.Bool(flags.ExcludeBkContents.Name)
+	s.verifyBkContents = c.Bool(flags.VerifyBkContents.Name)
+	s.feedName = c.String(flags.BkFeedName.Name)
+	if s.replay != nil {
+		header := s.replay.recording.header
+		if len(header.Sources) != 2 {
+			return fmt.Errorf("expected 2 sources in recording of %s feed, got %d", bkFeed, len(header.Sources))
+		}
+
+		bxURI, evmURI = header.Sources[gatewayIndex].URI, header.Sources[nodeIndex].URI
+		s.excBkContents = header.ExcludeContents
+		s.feedName = header.FeedName
+	}
+
+	if s.verifyBkContents && s.excBkContents {
+		return fmt.Errorf("error: --%s requires the block contents, it cannot be used with --%s",
+			flags.VerifyBkContents.Name, flags.ExcludeBkContents.Name)
+	}
+
+	switch s.feedName {
+	case ws.FeedTxReceipts, ws.FeedEthOnBlock:
+		if s.excBkContents {
+			return fmt.Errorf("error: %s feed is compared with the responses of the node, it cannot be used with --%s",
+				s.feedName, flags.ExcludeBkContents.Name)
+		}
+
+		if s.feedName == ws.FeedTxReceipts && s.verifyBkContents {
+			return fmt.Errorf("error: --%s is not supported for %s feed",
+				flags.VerifyBkContents.Name, s.feedName)
+		}
+	}
+
+	if s.feedName == ws.FeedEthOnBlock && s.replay == nil {
+		calls, err := parseCalls(c.StringSlice(flags.EthCall.Name))
+		if err != nil {
+			return fmt.Errorf("error: invalid --%s value: %v", flags.EthCall.Name, err)
+		}
+		s.calls = calls
+	}
+
+	rep, err := report.New(c, map[string]string{"gateway": bxURI, "evmNode": evmURI})
+	if err != nil {
+		return err
+	}
+
+	bounds, err := stats.ParseBounds(c.String(flags.HistogramBuckets.Name))
+	if err != nil {
+		return fmt.Errorf("error: invalid --%s value: %v", flags.HistogramBuckets.Name, err)
+	}
+	s.histogramBounds = bounds
 
 	if d := strings.ToUpper(c.String(flags.Dump.Name)); d != "" {
 		const all, missing, allAndMissing = "ALL", "MISSING", "ALL,MISSING"
@@ -127,41 +239,80 @@ func (s *BkFeedsCompareService) Run(c *cli.Context) error {
 		leadTimeSec  = c.Int(flags.LeadTime.Name)
 		intervalSec  = c.Int(flags.Interval.Name)
 		trailTimeSec = c.Int(flags.BkTrailTime.Name)
-		evmURI       = c.String(flags.FeedWSEndpoint.Name)
+		ignoreDelta  = c.Int(flags.BkIgnoreDelta.Name)
+		out          = rep.Output()
 		ctx, cancel  = context.WithCancel(context.Background())
 
 		readerGroup sync.WaitGroup
 		handleGroup sync.WaitGroup
 	)
 
-	s.timeToBeginComparison = time.Now().Add(time.Second * time.Duration(leadTimeSec))
+	defer cancel()
+
+	if addr := c.String(flags.MetricsAddr.Name); addr != "" {
+		metrics.Serve(ctx, addr)
+	}
+
+	// Contents readers share a single connection, requests are multiplexed by ID.
+	var contentsConn *ws.Connection
+	if !s.excBkContents && s.replay == nil {
+		log.Infof("Initiating connection to %s", evmURI)
+		conn, err := ws.Dial(evmURI, "")
+		if err != nil {
+			return fmt.Errorf("cannot establish connection to %s: %v", evmURI, err)
+		}
+		log.Infof("Connection to %s established", evmURI)
+
+		defer func() {
+			if err := conn.Close(); err != nil {
+				log.Errorf("cannot close socket connection to %s: %v", evmURI, err)
+			}
+		}()
+
+		contentsConn = conn
+	}
+
+	s.timeToBeginComparison = s.clock.Now().Add(time.Second * time.Duration(leadTimeSec))
 	s.timeToEndComparison = s.timeToBeginComparison.Add(time.Second * time.Duration(intervalSec))
+	s.intervalStart = s.timeToBeginComparison
 	s.numIntervals = c.Int(flags.NumIntervals.Name)
-	s.feedName = c.String(flags.BkFeedName.Name)
+	s.ignoreDelta = time.Second * time.Duration(ignoreDelta)
 
-	var bxURI string
-	if c.Bool(flags.UseCloudAPI.Name) {
-		bxURI = c.String(flags.CloudAPIWSURI.Name)
-	} else {
-		bxURI = c.String(flags.Gateway.Name)
+	if dir := c.String(flags.Record.Name); dir != "" {
+		rec, err := newRecorder(dir, s.recordingHeader(bxURI, evmURI))
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			if err := rec.Close(); err != nil {
+				log.Errorf("cannot close recording: %v", err)
+			}
+		}()
+
+		s.feeds.recorder = rec
 	}
 
-	readerGroup.Add(2)
-	go s.readFeedFromBX(
-		ctx,
-		&readerGroup,
-		s.bxCh,
-		bxURI,
-		c.String(flags.AuthHeader.Name),
-	)
-	go s.readFeedFromEvm(
-		ctx,
-		&readerGroup,
-		s.evmCh,
-		evmURI,
-	)
+	if s.replay != nil {
+		readerGroup.Add(2)
+		go s.replay.run(ctx, &readerGroup, s.feeds, s.evmBkCh, s.handlers, s.sourceGaps)
+		go discardHashes(ctx, &readerGroup, s.hashes)
+	} else {
+		readerGroup.Add(2)
+		go s.readFeedFromBX(
+			ctx,
+			&readerGroup,
+			bxURI,
+			c.String(flags.AuthHeader.Name),
+		)
+		go s.readFeedFromEvm(
+			ctx,
+			&readerGroup,
+			evmURI,
+		)
+	}
 
-	if !s.excBkContents {
+	if contentsConn != nil {
 		const totalReaders = 4
 		for i := 0; i < totalReaders; i++ {
 			readerGroup.Add(1)
@@ -169,7 +320,7 @@ func (s *BkFeedsCompareService) Run(c *cli.Context) error {
 				ctx,
 				&readerGroup,
 				s.evmBkCh,
-				evmURI,
+				contentsConn,
 			)
 		}
 	}
@@ -177,14 +328,19 @@ func (s *BkFeedsCompareService) Run(c *cli.Context) error {
 	handleGroup.Add(1)
 	go s.handleUpdates(ctx, &handleGroup)
 
-	time.Sleep(time.Second * time.Duration(leadTimeSec))
+	s.clock.Sleep(time.Second * time.Duration(leadTimeSec))
 	for i := 0; i < s.numIntervals; i++ {
-		time.Sleep(time.Second * time.Duration(intervalSec))
+		s.clock.Sleep(time.Second * time.Duration(intervalSec))
 		s.clearTrailNewHashes()
-		time.Sleep(time.Second * time.Duration(trailTimeSec))
+		s.clock.Sleep(time.Second * time.Duration(trailTimeSec))
 
 		func(numIntervalsPassed int) {
 			s.handlers <- func() error {
+				var (
+					intervalEnd = s.clock.Now()
+					res, lat    = s.stats(ignoreDelta)
+				)
+
 				msg := fmt.Sprintf(
 					"-----------------------------------------------------\n"+
 						"Interval (%d/%d): %d seconds. \n"+
@@ -193,34 +349,75 @@ func (s *BkFeedsCompareService) Run(c *cli.Context) error {
 					numIntervalsPassed,
 					s.numIntervals,
 					intervalSec,
-					time.Now().Format("2006-01-02T15:04:05.000"),
-					s.stats(c.Int(flags.BkIgnoreDelta.Name)),
+					intervalEnd.Format("2006-01-02T15:04:05.000"),
+					res,
 				)
 
+				rep.AddInterval(numIntervalsPassed, s.intervalStart, intervalEnd, res)
+				s.runStats.add(res)
+				s.runLatency.merge(lat)
+
 				s.drainChannels()
 
-				s.seenHashes = make(map[string]*hashEntry)
+				s.seenHashes = make(map[blockKey]*hashEntry)
+				s.reorgs = nil
+				s.droppedHashes = utils.NewHashSet()
+				s.pruneBlocks()
 				s.leadNewHashes = utils.NewHashSet()
-				s.timeToEndComparison = time.Now().Add(time.Second * time.Duration(intervalSec))
+				s.intervalStart = s.clock.Now()
+				s.timeToEndComparison = s.intervalStart.Add(time.Second * time.Duration(intervalSec))
 
-				fmt.Print(msg)
+				fmt.Fprint(out, msg)
 
 				if numIntervalsPassed == s.numIntervals {
-					fmt.Printf("%d of %d intervals complete. Exiting.\n\n",
+					s.runStats.finish(s.runLatency, s.histogramBounds)
+
+					if s.numIntervals > 1 {
+						fmt.Fprintf(out, "-----------------------------------------------------\n"+
+							"Summary of %d intervals:\n%s\n",
+							s.numIntervals, s.runStats)
+					}
+
+					fmt.Fprintf(out, "%d of %d intervals complete. Exiting.\n\n",
 						numIntervalsPassed, s.numIntervals)
 				}
 
 				return nil
 			}
 		}(i + 1)
-
 	}
 
 	cancel()
 	readerGroup.Wait()
 	handleGroup.Wait()
 
-	return nil
+	rep.SetAggregate(s.runStats)
+
+	return rep.Write()
+}
+
+// sourceGaps returns the gaps of the source.
+func (s *BkFeedsCompareService) sourceGaps(source int) *[]ws.Gap {
+	if source == gatewayIndex {
+		return &s.bxGaps
+	}
+
+	return &s.evmGaps
+}
+
+// recordingHeader describes the comparison in a recording.
+func (s *BkFeedsCompareService) recordingHeader(bxURI, evmURI string) *recordingHeader {
+	sources := make([]recordedSource, 2)
+	sources[gatewayIndex] = recordedSource{Name: sourceGateway, URI: bxURI, Type: sourceTypeBX}
+	sources[nodeIndex] = recordedSource{Name: sourceNode, URI: evmURI, Type: sourceTypeEvm}
+
+	return &recordingHeader{
+		Feed:            bkFeed,
+		FeedName:        s.feedName,
+		Start:           s.clock.Now(),
+		ExcludeContents: s.excBkContents,
+		Sources:         sources,
+	}
 }
 
 func (s *BkFeedsCompareService) handleUpdates(
@@ -229,49 +426,16 @@ func (s *BkFeedsCompareService) handleUpdates(
 ) {
 	defer wg.Done()
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case update, ok := <-s.handlers:
-			if !ok {
-				continue
-			}
-
-			if err := update(); err != nil {
-				log.Errorf("error in update function: %v", err)
-			}
-		case data, ok := <-s.evmBkCh:
-			if !ok {
-				continue
-			}
-
-			if err := s.processBkContentsFromEvm(data); err != nil {
-				log.Errorf("error: %v", err)
-			}
-		default:
-			select {
-			case data, ok := <-s.bxCh:
-				if !ok {
-					continue
-				}
-
-				if err := s.processFeedFromBX(data); err != nil {
-					log.Errorf("error: %v", err)
-				}
-			case data, ok := <-s.evmCh:
-				if !ok {
-					continue
-				}
+	handleEvents(ctx, s.handlers, s.evmBkCh, s.feeds, s.processBkContentsFromEvm, s.processFeed)
+}
 
-				if err := s.processFeedFromEvm(data); err != nil {
-					log.Errorf("error: %v", err)
-				}
-			default:
-				break
-			}
-		}
+// processFeed processes a message of the block feed of a source.
+func (s *BkFeedsCompareService) processFeed(data *message) error {
+	if data.source == gatewayIndex {
+		return s.processFeedFromBX(data)
 	}
+
+	return s.processFeedFromEvm(data)
 }
 
 func (s *BkFeedsCompareService) processFeedFromBX(data *message) error {
@@ -280,77 +444,128 @@ func (s *BkFeedsCompareService) processFeedFromBX(data *message) error {
 			s.feedName, data.err)
 	}
 
-	timeReceived := time.Now()
+	switch s.feedName {
+	case ws.FeedTxReceipts:
+		return s.processReceiptFromBX(data)
+	case ws.FeedEthOnBlock:
+		return s.processCallResult(data, gatewayIndex)
+	}
+
+	timeReceived := data.received
 
 	var msg bxBkFeedResponse
 	if err := json.Unmarshal(data.bytes, &msg); err != nil {
 		return fmt.Errorf("failed to unmarshal message: %v", err)
 	}
 
-	hash := msg.Params.Result.Hash
-	log.Debugf("got message at %s (BXR node, ALL), hash: %s", timeReceived, hash)
+	header := msg.Params.Result.Header
+	header.Hash = msg.Params.Result.Hash
+	log.Debugf("got message at %s (BXR node, ALL), number: %d, hash: %s",
+		timeReceived, header.Number, header.Hash)
+
+	s.observeBlock(gatewayIndex, header)
 
 	if timeReceived.Before(s.timeToBeginComparison) {
-		s.leadNewHashes.Add(hash)
+		s.leadNewHashes.Add(header.Hash)
 		return nil
 	}
 
-	if entry, ok := s.seenHashes[hash]; ok {
-		if entry.bxrTimeReceived.IsZero() {
-			entry.bxrTimeReceived = timeReceived
-		}
-	} else if timeReceived.Before(s.timeToEndComparison) &&
-		!s.trailNewHashes.Contains(hash) &&
-		!s.leadNewHashes.Contains(hash) {
+	entry := s.markSeen(header.key(), gatewayIndex, timeReceived)
 
-		s.seenHashes[hash] = &hashEntry{
-			hash:            hash,
-			bxrTimeReceived: timeReceived,
-		}
-	} else {
-		s.trailNewHashes.Add(hash)
+	return s.keepContents(entry, gatewayIndex, data.bytes, parseGatewayContents)
+}
+
+// processReceiptFromBX processes a receipt of the txReceipts feed. The receipts of a block are
+// sent one by one, the block is received with the first one.
+func (s *BkFeedsCompareService) processReceiptFromBX(data *message) error {
+	timeReceived := data.received
+
+	var msg bxReceiptFeedResponse
+	if err := json.Unmarshal(data.bytes, &msg); err != nil {
+		return fmt.Errorf("failed to unmarshal message: %v", err)
+	}
+
+	receipt := msg.Params.Result.Receipt
+	header := blockHeader{Number: receipt.BlockNumber, Hash: receipt.BlockHash}
+	log.Debugf("got message at %s (BXR node, RCP), number: %d, hash: %s, txHash: %s",
+		timeReceived, header.Number, header.Hash, receipt.TransactionHash)
+
+	s.observeBlock(gatewayIndex, header)
+
+	if timeReceived.Before(s.timeToBeginComparison) {
+		s.leadNewHashes.Add(header.Hash)
+		return nil
 	}
 
+	s.markSeen(header.key(), gatewayIndex, timeReceived)
+
 	return nil
 }
 
+// processCallResult processes a result of a call of the ethOnBlock feed received from the
+// gateway or executed by the node.
+func (s *BkFeedsCompareService) processCallResult(data *message, source int) error {
+	timeReceived := data.received
+
+	var msg callResultMessage
+	if err := json.Unmarshal(data.bytes, &msg); err != nil {
+		return fmt.Errorf("failed to unmarshal message: %v", err)
+	}
+
+	var (
+		res = msg.Params.Result
+		key = blockKey{number: uint64(res.BlockHeight), call: res.Name}
+	)
+	log.Debugf("got message at %s (%s, CALL), call: %s, response: %s",
+		timeReceived, sourceNames[source], key.id(), res.Response)
+
+	if timeReceived.Before(s.timeToBeginComparison) {
+		s.leadNewHashes.Add(key.id())
+		return nil
+	}
+
+	entry := s.markSeen(key, source, timeReceived)
+
+	return s.keepContents(entry, source, data.bytes, parseCallContents)
+}
+
 func (s *BkFeedsCompareService) processFeedFromEvm(data *message) error {
 	if data.err != nil {
 		return fmt.Errorf(
 			"failed to read message from EVM feed: %v", data.err)
 	}
 
-	timeReceived := time.Now()
+	timeReceived := data.received
 
 	var msg evmBkFeedResponse
 	if err := json.Unmarshal(data.bytes, &msg); err != nil {
 		return fmt.Errorf("failed to unmarshal message: %v", err)
 	}
 
-	hash := msg.Params.Result.Hash
-	log.Debugf("got message at %s (EVM node, SUB), hash: %s", timeReceived, hash)
+	header := msg.Params.Result
+	log.Debugf("got message at %s (EVM node, SUB), number: %d, hash: %s",
+		timeReceived, header.Number, header.Hash)
+
+	s.observeBlock(nodeIndex, header)
 
 	if timeReceived.Before(s.timeToBeginComparison) {
-		s.leadNewHashes.Add(hash)
+		s.leadNewHashes.Add(header.Hash)
 		return nil
 	}
 
 	if !s.excBkContents {
-		go func() { s.hashes <- hash }()
-	} else if entry, ok := s.seenHashes[hash]; ok {
-		if entry.evmTimeReceived.IsZero() {
-			entry.evmTimeReceived = timeReceived
-		}
-	} else if timeReceived.Before(s.timeToEndComparison) &&
-		!s.trailNewHashes.Contains(hash) &&
-		!s.leadNewHashes.Contains(hash) {
-
-		s.seenHashes[hash] = &hashEntry{
-			evmTimeReceived: timeReceived,
-			hash:            hash,
+		item := s.contentsItem(header.key())
+		if !requestContents(s.hashes, item, s.metrics, sourceNode) {
+			s.droppedHashes.Add(item)
+			if s.dropped++; s.dropped == 1 {
+				log.Warnf("contents queue of the node is full, dropping block %s, "+
+					"further drops are logged at debug level", item)
+			} else {
+				log.Debugf("contents queue of the node is full, dropping block %s", item)
+			}
 		}
 	} else {
-		s.trailNewHashes.Add(hash)
+		s.markSeen(header.key(), nodeIndex, timeReceived)
 	}
 
 	return nil
@@ -364,7 +579,14 @@ func (s *BkFeedsCompareService) processBkContentsFromEvm(data *message) error {
 			hash, data.err)
 	}
 
-	timeReceived := time.Now()
+	switch s.feedName {
+	case ws.FeedTxReceipts:
+		return s.processReceiptsFromEvm(data)
+	case ws.FeedEthOnBlock:
+		return s.processCallResult(data, nodeIndex)
+	}
+
+	timeReceived := data.received
 
 	var msg evmBkContentsResponse
 	if err := json.Unmarshal(data.bytes, &msg); err != nil {
@@ -377,42 +599,229 @@ func (s *BkFeedsCompareService) processBkContentsFromEvm(data *message) error {
 		return nil
 	}
 
-	if entry, ok := s.seenHashes[hash]; ok {
-		if entry.evmTimeReceived.IsZero() {
-			entry.evmTimeReceived = timeReceived
-		}
-	} else if timeReceived.Before(s.timeToEndComparison) &&
-		!s.trailNewHashes.Contains(hash) &&
-		!s.leadNewHashes.Contains(hash) {
+	entry := s.markSeen(msg.Result.key(), nodeIndex, timeReceived)
+
+	return s.keepContents(entry, nodeIndex, data.bytes, parseNodeContents)
+}
+
+// processReceiptsFromEvm processes the receipts of a block returned by the node. Blocks
+// without transactions are skipped, because the txReceipts feed does not send them.
+func (s *BkFeedsCompareService) processReceiptsFromEvm(data *message) error {
+	timeReceived := data.received
+
+	var msg evmReceiptsResponse
+	if err := json.Unmarshal(data.bytes, &msg); err != nil {
+		return fmt.Errorf("failed to unmarshal message: %v", err)
+	}
+
+	log.Debugf("got message at %s (EVM node, RCP), hash: %s, receipts: %d",
+		timeReceived, data.hash, len(msg.Result))
 
-		s.seenHashes[hash] = &hashEntry{
-			evmTimeReceived: timeReceived,
-			hash:            hash,
+	if len(msg.Result) == 0 {
+		return nil
+	}
+
+	receipt := msg.Result[0]
+	s.markSeen(blockKey{number: uint64(receipt.BlockNumber), hash: receipt.BlockHash}, nodeIndex, timeReceived)
+
+	return nil
+}
+
+// contentsItem returns what is requested from the node for the block: its hash, or its
+// number, on which the calls of the ethOnBlock feed are executed.
+func (s *BkFeedsCompareService) contentsItem(key blockKey) string {
+	if s.feedName == ws.FeedEthOnBlock {
+		return hexutil.EncodeUint64(key.number)
+	}
+
+	return key.hash
+}
+
+// observeBlock applies a block announced by the source to the chain views and records the
+// reorg of the source, if the block replaced some of the blocks it announced before.
+func (s *BkFeedsCompareService) observeBlock(source int, header blockHeader) {
+	s.blocks[header.Hash] = header
+
+	if depth := s.views[source].add(header, s.blocks); depth > 0 {
+		log.Infof("%s reorganized %d blocks, new head %d (%s)",
+			sourceNames[source], depth, header.Number, header.Hash)
+		s.reorgs = append(s.reorgs, reorg{source: source, depth: depth})
+		s.metrics.Reorg(sourceNames[source], depth)
+	}
+
+	// Competing blocks at or below the head do not change which blocks are canonical until
+	// a block above the head is built on them.
+	s.canonical.add(header, s.blocks)
+}
+
+// markSeen records the time a block was received from a source. It returns the entry of the
+// block if this is the first time the block was received from the source during the
+// comparison, nil otherwise.
+func (s *BkFeedsCompareService) markSeen(key blockKey, source int, timeReceived time.Time) *hashEntry {
+	id := key.id()
+
+	if entry, ok := s.seenHashes[key]; ok {
+		if entry.timeReceived(source).IsZero() {
+			entry.setTimeReceived(source, timeReceived)
+			s.recordSeen(entry, sourceNames[source])
+			return entry
 		}
+	} else if timeReceived.Before(s.timeToEndComparison) &&
+		!s.trailNewHashes.Contains(id) &&
+		!s.leadNewHashes.Contains(id) {
+
+		entry := &hashEntry{hash: id, number: key.number}
+		entry.setTimeReceived(source, timeReceived)
+		s.seenHashes[key] = entry
+		s.metrics.Seen(sourceNames[source])
+		return entry
 	} else {
-		s.trailNewHashes.Add(hash)
+		s.trailNewHashes.Add(id)
 	}
 
 	return nil
 }
 
-func (s *BkFeedsCompareService) stats(ignoreDelta int) string {
+// keepContents parses the contents of a block received from the source, so they can be
+// verified once the block is received from the other source.
+func (s *BkFeedsCompareService) keepContents(
+	entry *hashEntry,
+	source int,
+	data []byte,
+	parse func([]byte) (*blockContents, error),
+) error {
+	if entry == nil || !s.verifyBkContents {
+		return nil
+	}
+
+	contents, err := parse(data)
+	if err != nil {
+		return fmt.Errorf("cannot verify contents of block %q from %s: %v", entry.hash, sourceNames[source], err)
+	}
+	entry.contents[source] = contents
+
+	return nil
+}
+
+// verifyContents compares the contents of a block received from both sources.
+func (s *BkFeedsCompareService) verifyContents(entry *hashEntry, res *bkStats) {
+	gateway, node := entry.contents[gatewayIndex], entry.contents[nodeIndex]
+	if gateway == nil || node == nil {
+		return
+	}
+
+	res.BkContentsVerified++
+
+	fields := gateway.mismatches(node)
+	if len(fields) == 0 {
+		return
+	}
+
+	log.Warnf("block %d (%s) from gateway does not match the node: %s",
+		entry.number, entry.hash, strings.Join(fields, ", "))
+
+	res.BkContentsMismatched++
+	if res.ContentMismatches == nil {
+		res.ContentMismatches = make(map[string]int)
+	}
+	for _, field := range fields {
+		res.ContentMismatches[field]++
+	}
+}
+
+// pruneBlocks drops the blocks too far below the head to be replaced by a reorg.
+func (s *BkFeedsCompareService) pruneBlocks() {
+	for _, v := range s.views {
+		v.prune()
+	}
+	s.canonical.prune()
+
+	for hash, header := range s.blocks {
+		if uint64(header.Number)+maxReorgDepth < s.canonical.head {
+			delete(s.blocks, hash)
+		}
+	}
+}
+
+// recordSeen updates the metrics after a block which was already received from the other
+// source was received from source.
+func (s *BkFeedsCompareService) recordSeen(entry *hashEntry, source string) {
+	s.metrics.Seen(source)
+
+	diff := entry.bxrTimeReceived.Sub(entry.evmTimeReceived)
+	if absDuration(diff) > s.ignoreDelta {
+		s.metrics.HighDeltaIgnored()
+		return
+	}
+
+	s.metrics.Delta(sourceGateway, sourceNode, diff)
+
+	switch {
+	case diff < 0:
+		s.metrics.FirstSeen(sourceGateway)
+	case diff > 0:
+		s.metrics.FirstSeen(sourceNode)
+	}
+}
+
+func (s *BkFeedsCompareService) stats(ignoreDelta int) (*bkStats, *latencyStats) {
 	const timestampFormat = "2006-01-02T15:04:05.000"
+
 	var (
-		bkSeenByBothFeedsGatewayFirst      = 0
-		bkSeenByBothFeedsEvmNodeFirst      = 0
-		bkReceivedByGatewayFirstTotalDelta = 0.0
-		bkReceivedByEvmNodeFirstTotalDelta = 0.0
-		newBkFromGatewayFeedFirst          = 0
-		newBkFromEvmNodeFeedFirst          = 0
-		totalBkFromGateway                 = 0
-		totalBkFromEvmNode                 = 0
+		res     = &bkStats{}
+		latency = newLatencyStats()
 	)
 
-	for bkHash, entry := range s.seenHashes {
+	// The blocks are visited in order, so the latency stats of a replayed comparison are
+	// accumulated in the same order as in the recorded one.
+	keys := make([]blockKey, 0, len(s.seenHashes))
+	for key := range s.seenHashes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].number != keys[j].number {
+			return keys[i].number < keys[j].number
+		}
+		return keys[i].hash < keys[j].hash
+	})
+
+	heights := make(map[uint64]int, len(s.seenHashes))
+
+	for _, key := range keys {
+		entry := s.seenHashes[key]
+		bkHash := entry.hash
+		s.verifyContents(entry, res)
+
+		// The results of the ethOnBlock feed are not part of the chain.
+		isBlock := key.call == ""
+		if isBlock {
+			heights[key.number]++
+		}
+
+		// Orphaned blocks are counted for the sources which announced them, but a block
+		// which lost the race to become canonical is not missing from the other source.
+		if isBlock && !s.canonical.contains(key) {
+			if !entry.bxrTimeReceived.IsZero() {
+				res.TotalBkFromGateway++
+				res.OrphanedBkFromGateway++
+			}
+			if !entry.evmTimeReceived.IsZero() {
+				res.TotalBkFromEvmNode++
+				res.OrphanedBkFromEvmNode++
+			}
+			continue
+		}
+
 		if entry.bxrTimeReceived.IsZero() {
 			evmNodeTimeReceived := entry.evmTimeReceived
 
+			if inGap(s.bxGaps, evmNodeTimeReceived) {
+				res.BkIgnoredDueToOutages++
+				continue
+			}
+
+			s.metrics.Missing(sourceGateway)
+
 			if s.missingHashesFile != nil {
 				line := fmt.Sprintf("%s\n", bkHash)
 				if _, err := s.missingHashesFile.WriteString(line); err != nil {
@@ -425,21 +834,39 @@ func (s *BkFeedsCompareService) stats(ignoreDelta int) string {
 					log.Errorf("cannot add bkHash %q to all hashes file: %v", bkHash, err)
 				}
 			}
-			newBkFromEvmNodeFeedFirst++
-			totalBkFromEvmNode++
+			res.NewBkFromEvmNodeFirst++
+			res.TotalBkFromEvmNode++
+			if isBlock {
+				res.CanonicalBkFromEvmNode++
+			}
 			continue
 		}
 		if entry.evmTimeReceived.IsZero() {
 			gatewayTimeReceived := entry.bxrTimeReceived
 
+			if inGap(s.evmGaps, gatewayTimeReceived) {
+				res.BkIgnoredDueToOutages++
+				continue
+			}
+
+			// The node announced the block, but its contents were never requested.
+			if s.droppedHashes.Contains(s.contentsItem(key)) {
+				continue
+			}
+
+			s.metrics.Missing(sourceNode)
+
 			if s.allHashesFile != nil {
 				record := []string{bkHash, gatewayTimeReceived.Format(timestampFormat), "0"}
 				if err := s.allHashesFile.Write(record); err != nil {
 					log.Errorf("cannot add bkHash %q to all hashes file: %v", bkHash, err)
 				}
 			}
-			newBkFromGatewayFeedFirst++
-			totalBkFromGateway++
+			res.NewBkFromGatewayFirst++
+			res.TotalBkFromGateway++
+			if isBlock {
+				res.CanonicalBkFromGateway++
+			}
 			continue
 		}
 
@@ -449,8 +876,12 @@ func (s *BkFeedsCompareService) stats(ignoreDelta int) string {
 			timeReceivedDiff    = gatewayTimeReceived.Sub(evmNodeTimeReceived)
 		)
 
-		totalBkFromGateway++
-		totalBkFromEvmNode++
+		res.TotalBkFromGateway++
+		res.TotalBkFromEvmNode++
+		if isBlock {
+			res.CanonicalBkFromGateway++
+			res.CanonicalBkFromEvmNode++
+		}
 
 		if s.allHashesFile != nil {
 			record := []string{
@@ -467,41 +898,99 @@ func (s *BkFeedsCompareService) stats(ignoreDelta int) string {
 			continue
 		}
 
+		latency.add(timeReceivedDiff)
+
 		switch {
 		case gatewayTimeReceived.Before(evmNodeTimeReceived):
-			newBkFromGatewayFeedFirst++
-			bkSeenByBothFeedsGatewayFirst++
-			bkReceivedByGatewayFirstTotalDelta += -timeReceivedDiff.Seconds()
+			res.NewBkFromGatewayFirst++
+			res.BkSeenByBothFeedsGatewayFirst++
 		case evmNodeTimeReceived.Before(gatewayTimeReceived):
-			newBkFromEvmNodeFeedFirst++
-			bkSeenByBothFeedsEvmNodeFirst++
-			bkReceivedByEvmNodeFirstTotalDelta += timeReceivedDiff.Seconds()
+			res.NewBkFromEvmNodeFirst++
+			res.BkSeenByBothFeedsEvmNodeFirst++
+		}
+	}
+
+	for _, n := range heights {
+		if n > 1 {
+			res.SiblingBlocks++
+		}
+	}
+
+	for _, r := range s.reorgs {
+		if r.source == gatewayIndex {
+			res.GatewayReorgs++
+			res.GatewayMaxReorgDepth = maxInt(res.GatewayMaxReorgDepth, r.depth)
+		} else {
+			res.EvmNodeReorgs++
+			res.EvmNodeMaxReorgDepth = maxInt(res.EvmNodeMaxReorgDepth, r.depth)
 		}
 	}
 
 	var (
-		newBkSeenByBothFeeds = bkSeenByBothFeedsGatewayFirst +
-			bkSeenByBothFeedsEvmNodeFirst
-		bkReceivedByGatewayFirstAvgDelta = 0
-		bkReceivedByEvmNodeFirstAvgDelta = 0
-		bkPercentageSeenByGatewayFirst   = 0
+		now                             = s.clock.Now()
+		gatewayOutages, gatewayDowntime = gapsSummary(s.bxGaps, s.intervalStart, now)
+		evmNodeOutages, evmNodeDowntime = gapsSummary(s.evmGaps, s.intervalStart, now)
 	)
 
-	if bkSeenByBothFeedsGatewayFirst != 0 {
-		bkReceivedByGatewayFirstAvgDelta = int(math.Round(
-			bkReceivedByGatewayFirstTotalDelta / float64(bkSeenByBothFeedsGatewayFirst) * 1000))
-	}
+	res.GatewayOutages = gatewayOutages
+	res.GatewayDowntimeMs = gatewayDowntime.Milliseconds()
+	res.EvmNodeOutages = evmNodeOutages
+	res.EvmNodeDowntimeMs = evmNodeDowntime.Milliseconds()
+	res.ContentsDropped = len(s.droppedHashes)
+	res.finish(latency, s.histogramBounds)
+
+	return res, latency
+}
 
-	if bkSeenByBothFeedsEvmNodeFirst != 0 {
-		bkReceivedByEvmNodeFirstAvgDelta = int(math.Round(
-			bkReceivedByEvmNodeFirstTotalDelta / float64(bkSeenByBothFeedsEvmNodeFirst) * 1000))
+// add accumulates counters of an interval into the run totals.
+func (r *bkStats) add(other *bkStats) {
+	r.NewBkFromGatewayFirst += other.NewBkFromGatewayFirst
+	r.NewBkFromEvmNodeFirst += other.NewBkFromEvmNodeFirst
+	r.TotalBkFromGateway += other.TotalBkFromGateway
+	r.TotalBkFromEvmNode += other.TotalBkFromEvmNode
+	r.BkSeenByBothFeedsGatewayFirst += other.BkSeenByBothFeedsGatewayFirst
+	r.BkSeenByBothFeedsEvmNodeFirst += other.BkSeenByBothFeedsEvmNodeFirst
+	r.BkIgnoredDueToOutages += other.BkIgnoredDueToOutages
+	r.ContentsDropped += other.ContentsDropped
+	r.GatewayOutages += other.GatewayOutages
+	r.GatewayDowntimeMs += other.GatewayDowntimeMs
+	r.EvmNodeOutages += other.EvmNodeOutages
+	r.EvmNodeDowntimeMs += other.EvmNodeDowntimeMs
+	r.CanonicalBkFromGateway += other.CanonicalBkFromGateway
+	r.OrphanedBkFromGateway += other.OrphanedBkFromGateway
+	r.CanonicalBkFromEvmNode += other.CanonicalBkFromEvmNode
+	r.OrphanedBkFromEvmNode += other.OrphanedBkFromEvmNode
+	r.SiblingBlocks += other.SiblingBlocks
+	r.GatewayReorgs += other.GatewayReorgs
+	r.GatewayMaxReorgDepth = maxInt(r.GatewayMaxReorgDepth, other.GatewayMaxReorgDepth)
+	r.EvmNodeReorgs += other.EvmNodeReorgs
+	r.EvmNodeMaxReorgDepth = maxInt(r.EvmNodeMaxReorgDepth, other.EvmNodeMaxReorgDepth)
+	r.BkContentsVerified += other.BkContentsVerified
+	r.BkContentsMismatched += other.BkContentsMismatched
+
+	for field, n := range other.ContentMismatches {
+		if r.ContentMismatches == nil {
+			r.ContentMismatches = make(map[string]int)
+		}
+		r.ContentMismatches[field] += n
 	}
+}
 
-	if newBkSeenByBothFeeds != 0 {
-		bkPercentageSeenByGatewayFirst = int(
-			(float64(bkSeenByBothFeedsGatewayFirst) / float64(newBkSeenByBothFeeds)) * 100)
+// finish computes the values which are derived from the counters and latencies.
+func (r *bkStats) finish(latency *latencyStats, bounds []float64) {
+	r.BkSeenByBothFeeds = r.BkSeenByBothFeedsGatewayFirst + r.BkSeenByBothFeedsEvmNodeFirst
+	r.Latency = latency.summary(bounds)
+	r.AvgDeltaGatewayFirstMs = int(math.Round(r.Latency.GatewayFirst.Mean))
+	r.AvgDeltaEvmNodeFirstMs = int(math.Round(r.Latency.EvmNodeFirst.Mean))
+	r.PercentageSeenByGatewayFirst = 0
+
+	if r.BkSeenByBothFeeds != 0 {
+		r.PercentageSeenByGatewayFirst = int(
+			(float64(r.BkSeenByBothFeedsGatewayFirst) / float64(r.BkSeenByBothFeeds)) * 100)
 	}
+}
 
+func (r *bkStats) String() string {
 	return fmt.Sprintf("\nBlock summary\n"+
 		"Number of new blocks received first from gateway: %d\n"+
 		"Number of new blocks received first from node: %d\n"+
@@ -514,145 +1003,105 @@ func (s *BkFeedsCompareService) stats(ignoreDelta int) string {
 		"Number of blocks received from Evm node first: %d\n"+
 		"Percentage of blocks seen first from gateway: %d\n"+
 		"Average time difference for blocks received first from gateway (ms): %d\n"+
-		"Average time difference for blocks received first from Evm node (ms): %d\n",
-		newBkFromGatewayFeedFirst,
-		newBkFromEvmNodeFeedFirst,
-		newBkFromGatewayFeedFirst+newBkFromEvmNodeFeedFirst,
-		totalBkFromGateway,
-		totalBkFromEvmNode,
-		newBkSeenByBothFeeds,
-		bkSeenByBothFeedsGatewayFirst,
-		bkSeenByBothFeedsEvmNodeFirst,
-		bkPercentageSeenByGatewayFirst,
-		bkReceivedByGatewayFirstAvgDelta,
-		bkReceivedByEvmNodeFirstAvgDelta,
-	)
+		"Average time difference for blocks received first from Evm node (ms): %d\n"+
+		"\nNumber of blocks ignored due to feed outages: %d\n"+
+		"Number of dropped block contents requests: %d\n"+
+		"Gateway feed outages: %d (%s)\n"+
+		"Evm node feed outages: %d (%s)\n"+
+		"\nCanonical blocks from gateway: %d, orphaned: %d\n"+
+		"Canonical blocks from evm node: %d, orphaned: %d\n"+
+		"Heights with competing blocks: %d\n"+
+		"Gateway reorgs: %d (max depth %d)\n"+
+		"Evm node reorgs: %d (max depth %d)\n",
+		r.NewBkFromGatewayFirst,
+		r.NewBkFromEvmNodeFirst,
+		r.NewBkFromGatewayFirst+r.NewBkFromEvmNodeFirst,
+		r.TotalBkFromGateway,
+		r.TotalBkFromEvmNode,
+		r.BkSeenByBothFeeds,
+		r.BkSeenByBothFeedsGatewayFirst,
+		r.BkSeenByBothFeedsEvmNodeFirst,
+		r.PercentageSeenByGatewayFirst,
+		r.AvgDeltaGatewayFirstMs,
+		r.AvgDeltaEvmNodeFirstMs,
+		r.BkIgnoredDueToOutages,
+		r.ContentsDropped,
+		r.GatewayOutages, time.Duration(r.GatewayDowntimeMs)*time.Millisecond,
+		r.EvmNodeOutages, time.Duration(r.EvmNodeDowntimeMs)*time.Millisecond,
+		r.CanonicalBkFromGateway, r.OrphanedBkFromGateway,
+		r.CanonicalBkFromEvmNode, r.OrphanedBkFromEvmNode,
+		r.SiblingBlocks,
+		r.GatewayReorgs, r.GatewayMaxReorgDepth,
+		r.EvmNodeReorgs, r.EvmNodeMaxReorgDepth,
+	) + r.contentsString() + r.Latency.String()
+}
+
+func (r *bkStats) contentsString() string {
+	if r.BkContentsVerified == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nBlocks with verified contents: %d, mismatched: %d\n",
+		r.BkContentsVerified, r.BkContentsMismatched)
+	for _, field := range sortedCounts(r.ContentMismatches) {
+		fmt.Fprintf(&b, "  %s: %d\n", field, r.ContentMismatches[field])
+	}
+
+	return b.String()
 }
 
 func (s *BkFeedsCompareService) readFeedFromBX(
 	ctx context.Context,
 	wg *sync.WaitGroup,
-	out chan<- *message,
 	uri string,
 	authHeader string,
 ) {
-	defer wg.Done()
-
-	log.Infof("Initiating connection to: %s", uri)
-	conn, err := ws.NewConnection(uri, authHeader)
-	if err != nil {
-		log.Errorf("cannot establish connection to %s: %v", uri, err)
-		return
-	}
-	log.Infof("Connection to %s established", uri)
-
-	defer func() {
-		if err := conn.Close(); err != nil {
-			log.Errorf("cannot close socket connection to %s: %v", uri, err)
-		}
-	}()
-
-	sub, err := conn.SubscribeBkFeedBX(1, s.feedName, s.excBkContents)
-
-	if err != nil {
-		log.Errorf("cannot subscribe to feed %q: %v", s.feedName, err)
-		return
-	}
-
-	defer func() {
-		if err := sub.Unsubscribe(); err != nil {
-			log.Errorf("cannot unsubscribe from feed %q: %v", s.feedName, err)
-		}
-	}()
+	sub := ws.NewResilientSubscription(
+		uri,
+		authHeader,
+		func(conn *ws.Connection) (*ws.Subscription, error) {
+			if s.feedName == ws.FeedEthOnBlock {
+				callParams := make([]map[string]string, 0, len(s.calls))
+				for _, call := range s.calls {
+					callParams = append(callParams, call.callParams())
+				}
 
-	for {
-		var (
-			data, err = sub.NextMessage()
-			msg       = &message{
-				bytes: data,
-				err:   err,
+				return conn.SubscribeEthOnBlockBX(callParams)
 			}
-		)
 
-		select {
-		case <-ctx.Done():
-			return
-		case out <- msg:
-		}
-	}
+			return conn.SubscribeBkFeedBX(s.feedName, s.excBkContents)
+		},
+		reconnectOptions(ctx, s.handlers, s.feeds, gatewayIndex, &s.bxGaps, s.metrics, sourceGateway),
+	)
+
+	readFeed(ctx, wg, s.feeds, sub, gatewayIndex, sourceGateway, s.feedName)
 }
 
 func (s *BkFeedsCompareService) readFeedFromEvm(
 	ctx context.Context,
 	wg *sync.WaitGroup,
-	out chan<- *message,
 	uri string,
 ) {
-	defer wg.Done()
-
-	log.Infof("Initiating connection to %s", uri)
-	conn, err := ws.NewConnection(uri, "")
-	if err != nil {
-		log.Errorf("cannot establish connection to %s: %v", uri, err)
-		return
-	}
-	log.Infof("Connection to %s established", uri)
-
-	defer func() {
-		if err := conn.Close(); err != nil {
-			log.Errorf("cannot close socket connection to %s: %v", uri, err)
-		}
-	}()
-
-	sub, err := conn.SubscribeBkFeedEvm(1)
-	if err != nil {
-		log.Errorf("cannot subscribe to EVM feed: %v", err)
-		return
-	}
-
-	defer func() {
-		if err := sub.Unsubscribe(); err != nil {
-			log.Errorf("cannot unsubscribe from EVM feed: %v", err)
-		}
-	}()
-
-	for {
-		var (
-			data, err = sub.NextMessage()
-			msg       = &message{
-				bytes: data,
-				err:   err,
-			}
-		)
+	sub := ws.NewResilientSubscription(
+		uri,
+		"",
+		func(conn *ws.Connection) (*ws.Subscription, error) {
+			return conn.SubscribeBkFeedEvm()
+		},
+		reconnectOptions(ctx, s.handlers, s.feeds, nodeIndex, &s.evmGaps, s.metrics, sourceNode),
+	)
 
-		select {
-		case <-ctx.Done():
-			return
-		case out <- msg:
-		}
-	}
+	readFeed(ctx, wg, s.feeds, sub, nodeIndex, sourceNode, "newHeads")
 }
 
 func (s *BkFeedsCompareService) readBkContentsFromEvm(
 	ctx context.Context,
 	wg *sync.WaitGroup,
 	out chan<- *message,
-	uri string,
+	conn *ws.Connection,
 ) {
 	defer wg.Done()
-	log.Infof("Initiating connection to %s", uri)
-	conn, err := ws.NewConnection(uri, "")
-	if err != nil {
-		log.Errorf("cannot establish connection to %s: %v", uri, err)
-		return
-	}
-	log.Infof("Connection to %s established", uri)
-
-	defer func() {
-		if err := conn.Close(); err != nil {
-			log.Errorf("cannot close socket connection to %s: %v", uri, err)
-		}
-	}()
 
 	for {
 		select {
@@ -663,22 +1112,46 @@ func (s *BkFeedsCompareService) readBkContentsFromEvm(
 				return
 			}
 
-			var (
-				data, err = conn.Call(ws.NewRequest(1, "eth_getBlockByHash", []interface{}{bkHash, true}))
-				msg       = &message{
-					hash:  bkHash,
-					err:   err,
-					bytes: data,
+			for _, msg := range s.fetchContents(ctx, conn, bkHash) {
+				select {
+				case <-ctx.Done():
+					return
+				case out <- msg:
 				}
-			)
+			}
+		}
+	}
+}
 
-			select {
-			case <-ctx.Done():
-				return
-			case out <- msg:
+// fetchContents requests the node equivalent of the gateway feed message for the block: the
+// block, its receipts or the results of the calls of the ethOnBlock feed. The calls are
+// executed on the block with the number passed instead of the hash.
+func (s *BkFeedsCompareService) fetchContents(ctx context.Context, conn *ws.Connection, bkHash string) []*message {
+	switch s.feedName {
+	case ws.FeedTxReceipts:
+		res, err := conn.CallMessage(ctx, ws.NewRequest(1, "eth_getBlockReceipts", []interface{}{bkHash}))
+		return []*message{{hash: bkHash, err: err, bytes: res.Data, received: res.Received}}
+	case ws.FeedEthOnBlock:
+		number, err := hexutil.DecodeUint64(bkHash)
+		if err != nil {
+			return []*message{{hash: bkHash, err: err}}
+		}
+
+		msgs := make([]*message, 0, len(s.calls))
+		for _, call := range s.calls {
+			res, err := conn.CallMessage(ctx, call.request(number))
+			if err == nil {
+				res.Data, err = nodeCallResult(call, number, res.Data)
 			}
+
+			msgs = append(msgs, &message{hash: bkHash, err: err, bytes: res.Data, received: res.Received})
 		}
+
+		return msgs
 	}
+
+	res, err := conn.CallMessage(ctx, ws.NewRequest(1, "eth_getBlockByHash", []interface{}{bkHash, true}))
+	return []*message{{hash: bkHash, err: err, bytes: res.Data, received: res.Received}}
 }
 
 func (s *BkFeedsCompareService) clearTrailNewHashes() {
@@ -0,0 +1,949 @@
+package cmpfeeds
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"performance/internal/pkg/flags"
+	"performance/internal/pkg/mock"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/urfave/cli/v2"
+)
+
+func waitSubscriptions(t *testing.T, gateway *mock.Gateway, node *mock.Node) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := gateway.WaitSubscriptions(ctx, 1); err != nil {
+		t.Fatalf("gateway: %v", err)
+	}
+
+	if err := node.WaitSubscriptions(ctx, 1); err != nil {
+		t.Fatalf("node: %v", err)
+	}
+}
+
+func hash(i int) string {
+	return fmt.Sprintf("0x%064x", i)
+}
+
+func signedTxs(t *testing.T, n int) []*types.Transaction {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		signer = types.NewLondonSigner(big.NewInt(1))
+		to     = common.Address{}
+		txs    = make([]*types.Transaction, 0, n)
+	)
+
+	for i := 0; i < n; i++ {
+		tx, err := types.SignTx(types.NewTx(&types.LegacyTx{
+			Nonce:    uint64(i),
+			GasPrice: big.NewInt(1),
+			Gas:      21000,
+			To:       &to,
+			Value:    big.NewInt(0),
+		}), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		txs = append(txs, tx)
+	}
+
+	return txs
+}
+
+func TestTxFeedsCompare(t *testing.T) {
+	gateway, node := mock.NewGateway(), mock.NewNode()
+	defer gateway.Close()
+	defer node.Close()
+
+	var (
+		res  txStats
+		dir  = t.TempDir()
+		done = mock.RunCommand(t, NewTxFeedsCompareService().Run, []cli.Flag{
+			flags.Gateway,
+			flags.FeedWSEndpoint,
+			flags.TxFeedName,
+			flags.MinGasPrice,
+			flags.Interval,
+			flags.NumIntervals,
+			flags.LeadTime,
+			flags.TxTrailTime,
+			flags.TxIgnoreDelta,
+			flags.HistogramBuckets,
+			flags.Record,
+		}, &res,
+			"--gateway", gateway.URL(),
+			"--feed-ws-endpoint", node.WSURL(),
+			"--min-gas-price", "1",
+			"--lead-time", "0",
+			"--interval", "2",
+			"--trail-time", "1",
+			"--record", dir,
+		)
+		price    = big.NewInt(2 * params.GWei)
+		lowPrice = big.NewInt(params.GWei / 2)
+		step     = 300 * time.Millisecond
+	)
+
+	waitSubscriptions(t, gateway, node)
+
+	// Received from the gateway first.
+	gateway.AnnounceTx(mock.Tx{Hash: hash(1), GasPrice: price})
+	time.Sleep(step)
+	node.AnnounceTx(mock.Tx{Hash: hash(1), GasPrice: price})
+
+	// Received from the node first.
+	node.AnnounceTx(mock.Tx{Hash: hash(2), GasPrice: price})
+	time.Sleep(step)
+	gateway.AnnounceTx(mock.Tx{Hash: hash(2), GasPrice: price})
+
+	// Received from a single feed.
+	gateway.AnnounceTx(mock.Tx{Hash: hash(3), GasPrice: price})
+	node.AnnounceTx(mock.Tx{Hash: hash(4), GasPrice: price})
+
+	// Ignored due to the low gas price.
+	gateway.AnnounceTx(mock.Tx{Hash: hash(5), GasPrice: lowPrice})
+	node.AnnounceTx(mock.Tx{Hash: hash(5), GasPrice: lowPrice})
+
+	if err := <-done; err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	expected := map[string][2]int{
+		"TxSeenByBothFeeds":             {res.TxSeenByBothFeeds, 2},
+		"TxSeenByBothFeedsGatewayFirst": {res.TxSeenByBothFeedsGatewayFirst, 1},
+		"TxSeenByBothFeedsEvmNodeFirst": {res.TxSeenByBothFeedsEvmNodeFirst, 1},
+		"TotalTxFromGateway":            {res.TotalTxFromGateway, 3},
+		"TotalTxFromEvmNode":            {res.TotalTxFromEvmNode, 3},
+		"NewTxFromGatewayFirst":         {res.NewTxFromGatewayFirst, 2},
+		"NewTxFromEvmNodeFirst":         {res.NewTxFromEvmNodeFirst, 2},
+		"LowFeeTxIgnored":               {res.LowFeeTxIgnored, 1},
+		"TxIgnoredDueToOutages":         {res.TxIgnoredDueToOutages, 0},
+	}
+	for name, values := range expected {
+		if values[0] != values[1] {
+			t.Errorf("expected %s to be %d, got %d", name, values[1], values[0])
+		}
+	}
+
+	if res.AvgDeltaGatewayFirstMs < int(step.Milliseconds()) {
+		t.Errorf("expected average delta of at least %s, got %dms", step, res.AvgDeltaGatewayFirstMs)
+	}
+
+	var replayed txStats
+	replay(t, dir, &replayed, "--min-gas-price", "1", "--lead-time", "0", "--interval", "2", "--trail-time", "1")
+	if !reflect.DeepEqual(res, replayed) {
+		t.Errorf("expected replay to match the recorded run:\n%+v\ngot:\n%+v", res, replayed)
+	}
+
+	// The low fee transaction is compared without the minimum gas price.
+	replay(t, dir, &replayed, "--lead-time", "0", "--interval", "2", "--trail-time", "1")
+	if replayed.TxSeenByBothFeeds != 3 || replayed.LowFeeTxIgnored != 0 {
+		t.Errorf("expected 3 transactions seen by both feeds and none ignored, got %d and %d",
+			replayed.TxSeenByBothFeeds, replayed.LowFeeTxIgnored)
+	}
+}
+
+func TestTxFeedsCompareFilters(t *testing.T) {
+	gateway, node := mock.NewGateway(), mock.NewNode()
+	defer gateway.Close()
+	defer node.Close()
+
+	const (
+		to      = "0x00000000000000000000000000000000000000aa"
+		other   = "0x00000000000000000000000000000000000000bb"
+		filters = "({to} == '" + to + "') AND ({gas_price} >= 1e9)"
+	)
+
+	var (
+		res  txStats
+		dir  = t.TempDir()
+		done = mock.RunCommand(t, NewTxFeedsCompareService().Run, []cli.Flag{
+			flags.Gateway,
+			flags.FeedWSEndpoint,
+			flags.TxFeedName,
+			flags.Filters,
+			flags.Interval,
+			flags.NumIntervals,
+			flags.LeadTime,
+			flags.TxTrailTime,
+			flags.TxIgnoreDelta,
+			flags.HistogramBuckets,
+			flags.Record,
+		}, &res,
+			"--gateway", gateway.URL(),
+			"--feed-ws-endpoint", node.WSURL(),
+			"--filters", filters,
+			"--lead-time", "0",
+			"--interval", "2",
+			"--trail-time", "1",
+			"--record", dir,
+		)
+		price = big.NewInt(2 * params.GWei)
+		step  = 300 * time.Millisecond
+	)
+
+	waitSubscriptions(t, gateway, node)
+
+	if got := gateway.SubscribeOptions("newTxs")["filters"]; got != filters {
+		t.Errorf("expected the gateway subscription to have filters %q, got %v", filters, got)
+	}
+
+	// The gateway filters its feed, so it announces only the matching transaction.
+	gateway.AnnounceTx(mock.Tx{Hash: hash(1), To: to, GasPrice: price})
+	time.Sleep(step)
+	node.AnnounceTx(mock.Tx{Hash: hash(1), To: to, GasPrice: price})
+
+	// Filtered out of the node feed.
+	node.AnnounceTx(mock.Tx{Hash: hash(2), To: other, GasPrice: price})
+	node.AnnounceTx(mock.Tx{Hash: hash(3), To: to, GasPrice: big.NewInt(params.GWei / 2)})
+
+	if err := <-done; err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	expected := map[string][2]int{
+		"TxSeenByBothFeeds":  {res.TxSeenByBothFeeds, 1},
+		"TotalTxFromGateway": {res.TotalTxFromGateway, 1},
+		"TotalTxFromEvmNode": {res.TotalTxFromEvmNode, 1},
+		"LowFeeTxIgnored":    {res.LowFeeTxIgnored, 0},
+	}
+	for name, values := range expected {
+		if values[0] != values[1] {
+			t.Errorf("expected %s to be %d, got %d", name, values[1], values[0])
+		}
+	}
+
+	// The filters of the recording are applied by the replay.
+	var replayed txStats
+	replay(t, dir, &replayed, "--lead-time", "0", "--interval", "2", "--trail-time", "1")
+	if !reflect.DeepEqual(res, replayed) {
+		t.Errorf("expected replay to match the recorded run:\n%+v\ngot:\n%+v", res, replayed)
+	}
+}
+
+func TestTxFeedsCompareCriteria(t *testing.T) {
+	gateway, node := mock.NewGateway(), mock.NewNode()
+	defer gateway.Close()
+	defer node.Close()
+
+	const (
+		sender   = "0x00000000000000000000000000000000000000Aa"
+		transfer = "0xa9059cbb0000000000000000000000000000000000000000000000000000000000000001"
+		to       = "0x00000000000000000000000000000000000000bb"
+	)
+
+	var (
+		res      txStats
+		dir      = t.TempDir()
+		criteria = []string{
+			"--from-addresses", sender,
+			"--method-ids", "0xA9059CBB",
+			"--max-value", "1",
+			"--tx-types", "dynamic",
+			"--min-priority-fee", "1",
+		}
+		done = mock.RunCommand(t, NewTxFeedsCompareService().Run, []cli.Flag{
+			flags.Gateway,
+			flags.FeedWSEndpoint,
+			flags.TxFeedName,
+			flags.FromAddresses,
+			flags.MethodIDs,
+			flags.MaxValue,
+			flags.TxTypes,
+			flags.MinPriorityFee,
+			flags.Interval,
+			flags.NumIntervals,
+			flags.LeadTime,
+			flags.TxTrailTime,
+			flags.TxIgnoreDelta,
+			flags.HistogramBuckets,
+			flags.Record,
+		}, &res, append([]string{
+			"--gateway", gateway.URL(),
+			"--feed-ws-endpoint", node.WSURL(),
+			"--lead-time", "0",
+			"--interval", "2",
+			"--trail-time", "1",
+			"--record", dir,
+		}, criteria...)...)
+		price = big.NewInt(3 * params.GWei)
+		fee   = big.NewInt(2 * params.GWei)
+		value = big.NewInt(params.Ether / 2)
+	)
+
+	waitSubscriptions(t, gateway, node)
+
+	for _, tx := range []mock.Tx{
+		// Meets the criteria.
+		{Hash: hash(1), From: sender, To: to, Value: value, GasPrice: price, MaxPriorityFeePerGas: fee, Input: transfer},
+		// Another sender.
+		{Hash: hash(2), From: to, To: to, Value: value, GasPrice: price, MaxPriorityFeePerGas: fee, Input: transfer},
+		// A legacy transaction.
+		{Hash: hash(3), From: sender, To: to, Value: value, GasPrice: price, Input: transfer},
+		// A low priority fee.
+		{Hash: hash(4), From: sender, To: to, Value: value, GasPrice: price, MaxPriorityFeePerGas: big.NewInt(params.GWei / 2), Input: transfer},
+		// A high value.
+		{Hash: hash(5), From: sender, To: to, Value: big.NewInt(2 * params.Ether), GasPrice: price, MaxPriorityFeePerGas: fee, Input: transfer},
+		// Another method.
+		{Hash: hash(6), From: sender, To: to, Value: value, GasPrice: price, MaxPriorityFeePerGas: fee, Input: "0x095ea7b3"},
+		// A contract creation.
+		{Hash: hash(7), From: sender, GasPrice: price, MaxPriorityFeePerGas: fee, Input: "0x6080"},
+	} {
+		gateway.AnnounceTx(tx)
+		node.AnnounceTx(tx)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	expected := map[string][2]int{
+		"TxSeenByBothFeeds":  {res.TxSeenByBothFeeds, 1},
+		"TotalTxFromGateway": {res.TotalTxFromGateway, 1},
+		"TotalTxFromEvmNode": {res.TotalTxFromEvmNode, 1},
+	}
+	for name, values := range expected {
+		if values[0] != values[1] {
+			t.Errorf("expected %s to be %d, got %d", name, values[1], values[0])
+		}
+	}
+
+	args := []string{"--lead-time", "0", "--interval", "2", "--trail-time", "1"}
+
+	var replayed txStats
+	replay(t, dir, &replayed, append(args, criteria...)...)
+	if !reflect.DeepEqual(res, replayed) {
+		t.Errorf("expected replay to match the recorded run:\n%+v\ngot:\n%+v", res, replayed)
+	}
+
+	// The criteria are applied to both feeds of the replay.
+	replay(t, dir, &replayed, args...)
+	if replayed.TxSeenByBothFeeds != 7 {
+		t.Errorf("expected 7 transactions seen by both feeds, got %d", replayed.TxSeenByBothFeeds)
+	}
+
+	replay(t, dir, &replayed, append(args, "--contract-creation")...)
+	if replayed.TxSeenByBothFeeds != 1 || replayed.TotalTxFromGateway != 1 || replayed.TotalTxFromEvmNode != 1 {
+		t.Errorf("expected a single contract creation seen by both feeds, got %+v", replayed)
+	}
+}
+
+func TestTxFeedsCompareTransactionStatus(t *testing.T) {
+	gateway, node := mock.NewGateway(), mock.NewNode()
+	defer gateway.Close()
+	defer node.Close()
+
+	var (
+		res  txStats
+		done = mock.RunCommand(t, NewTxFeedsCompareService().Run, []cli.Flag{
+			flags.Gateway,
+			flags.FeedWSEndpoint,
+			flags.TxFeedName,
+			flags.Interval,
+			flags.NumIntervals,
+			flags.LeadTime,
+			flags.TxTrailTime,
+			flags.TxIgnoreDelta,
+			flags.HistogramBuckets,
+		}, &res,
+			"--gateway", gateway.URL(),
+			"--feed-ws-endpoint", node.WSURL(),
+			"--feed-name", "transactionStatus",
+			"--lead-time", "0",
+			"--interval", "2",
+			"--trail-time", "1",
+		)
+		step = 300 * time.Millisecond
+	)
+
+	waitSubscriptions(t, gateway, node)
+
+	gateway.AnnounceTxStatus(hash(1), "PENDING")
+	time.Sleep(step)
+	node.AnnounceTx(mock.Tx{Hash: hash(1)})
+
+	// Later updates of the status are not compared.
+	time.Sleep(step)
+	gateway.AnnounceTxStatus(hash(1), "CONFIRMED")
+
+	if err := <-done; err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	expected := map[string][2]int{
+		"TxSeenByBothFeeds":             {res.TxSeenByBothFeeds, 1},
+		"TxSeenByBothFeedsGatewayFirst": {res.TxSeenByBothFeedsGatewayFirst, 1},
+		"TotalTxFromGateway":            {res.TotalTxFromGateway, 1},
+		"TotalTxFromEvmNode":            {res.TotalTxFromEvmNode, 1},
+	}
+	for name, values := range expected {
+		if values[0] != values[1] {
+			t.Errorf("expected %s to be %d, got %d", name, values[1], values[0])
+		}
+	}
+}
+
+func TestTxFeedsCompareOutage(t *testing.T) {
+	gateway, node := mock.NewGateway(), mock.NewNode()
+	defer gateway.Close()
+	defer node.Close()
+
+	var (
+		res  txStats
+		dir  = t.TempDir()
+		done = mock.RunCommand(t, NewTxFeedsCompareService().Run, []cli.Flag{
+			flags.Gateway,
+			flags.FeedWSEndpoint,
+			flags.TxFeedName,
+			flags.Interval,
+			flags.NumIntervals,
+			flags.LeadTime,
+			flags.TxTrailTime,
+			flags.TxIgnoreDelta,
+			flags.HistogramBuckets,
+			flags.Record,
+		}, &res,
+			"--gateway", gateway.URL(),
+			"--feed-ws-endpoint", node.WSURL(),
+			"--lead-time", "0",
+			"--interval", "2",
+			"--trail-time", "1",
+			"--record", dir,
+		)
+		price = big.NewInt(2 * params.GWei)
+	)
+
+	waitSubscriptions(t, gateway, node)
+
+	gateway.AnnounceTx(mock.Tx{Hash: hash(1), GasPrice: price})
+	node.AnnounceTx(mock.Tx{Hash: hash(1), GasPrice: price})
+
+	// Announced by the node while the gateway reconnects.
+	gateway.SetResponseDelay(300 * time.Millisecond)
+	gateway.DropConnections()
+	time.Sleep(100 * time.Millisecond)
+	node.AnnounceTx(mock.Tx{Hash: hash(2), GasPrice: price})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := gateway.WaitSubscriptions(ctx, 1); err != nil {
+		t.Fatalf("gateway did not resubscribe: %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	expected := map[string][2]int{
+		"TxSeenByBothFeeds":     {res.TxSeenByBothFeeds, 1},
+		"TxIgnoredDueToOutages": {res.TxIgnoredDueToOutages, 1},
+		"GatewayOutages":        {res.GatewayOutages, 1},
+	}
+	for name, values := range expected {
+		if values[0] != values[1] {
+			t.Errorf("expected %s to be %d, got %d", name, values[1], values[0])
+		}
+	}
+
+	// The recorded outage is replayed into the comparison.
+	var replayed txStats
+	replay(t, dir, &replayed, "--lead-time", "0", "--interval", "2", "--trail-time", "1")
+	if !reflect.DeepEqual(res, replayed) {
+		t.Errorf("expected replay to match the recorded run:\n%+v\ngot:\n%+v", res, replayed)
+	}
+}
+
+func TestTxFeedsCompareDroppedContents(t *testing.T) {
+	s := NewTxFeedsCompareService()
+	s.sources = []*feedSource{
+		newFeedSource("gateway", "", sourceTypeBX, ""),
+		newFeedSource("node", "", sourceTypeEvm, ""),
+	}
+	s.gateway, s.evmNode = 0, 1
+	s.timeToEndComparison = time.Now().Add(time.Minute)
+
+	// The contents queue of the node is full, the transactions it announces are dropped.
+	s.sources[s.evmNode].hashes = make(chan string)
+
+	for _, data := range []*message{
+		{source: s.evmNode, bytes: []byte(fmt.Sprintf(`{"params":{"result":%q}}`, hash(1))), received: time.Now()},
+		{source: s.gateway, bytes: []byte(fmt.Sprintf(`{"params":{"result":{"txHash":%q}}}`, hash(1))), received: time.Now()},
+		{source: s.gateway, bytes: []byte(fmt.Sprintf(`{"params":{"result":{"txHash":%q}}}`, hash(2))), received: time.Now()},
+	} {
+		if err := s.processFeed(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, _ := s.stats(5)
+
+	expected := map[string][2]int{
+		"TotalTxFromGateway":    {res.TotalTxFromGateway, 1},
+		"NewTxFromGatewayFirst": {res.NewTxFromGatewayFirst, 1},
+		"TotalTxFromEvmNode":    {res.TotalTxFromEvmNode, 0},
+		"ContentsDropped":       {res.ContentsDropped, 1},
+	}
+	for name, values := range expected {
+		if values[0] != values[1] {
+			t.Errorf("expected %s to be %d, got %d", name, values[1], values[0])
+		}
+	}
+}
+
+func TestBkFeedsCompare(t *testing.T) {
+	gateway, node := mock.NewGateway(), mock.NewNode()
+	defer gateway.Close()
+	defer node.Close()
+
+	var (
+		res  bkStats
+		dir  = t.TempDir()
+		done = mock.RunCommand(t, NewBkFeedsCompareService().Run, []cli.Flag{
+			flags.Gateway,
+			flags.FeedWSEndpoint,
+			flags.BkFeedName,
+			flags.Interval,
+			flags.NumIntervals,
+			flags.LeadTime,
+			flags.BkTrailTime,
+			flags.BkIgnoreDelta,
+			flags.HistogramBuckets,
+			flags.Record,
+		}, &res,
+			"--gateway", gateway.URL(),
+			"--feed-ws-endpoint", node.WSURL(),
+			"--lead-time", "0",
+			"--interval", "2",
+			"--trail-time", "1",
+			"--record", dir,
+		)
+		step = 300 * time.Millisecond
+	)
+
+	waitSubscriptions(t, gateway, node)
+
+	gateway.AnnounceBlock(mock.Block{Hash: hash(1), Number: 1})
+	time.Sleep(step)
+	node.AnnounceBlock(mock.Block{Hash: hash(1), Number: 1})
+
+	node.AnnounceBlock(mock.Block{Hash: hash(2), Number: 2})
+	time.Sleep(step)
+	gateway.AnnounceBlock(mock.Block{Hash: hash(2), Number: 2})
+
+	gateway.AnnounceBlock(mock.Block{Hash: hash(3), Number: 3})
+
+	if err := <-done; err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	expected := map[string][2]int{
+		"BkSeenByBothFeeds":             {res.BkSeenByBothFeeds, 2},
+		"BkSeenByBothFeedsGatewayFirst": {res.BkSeenByBothFeedsGatewayFirst, 1},
+		"BkSeenByBothFeedsEvmNodeFirst": {res.BkSeenByBothFeedsEvmNodeFirst, 1},
+		"TotalBkFromGateway":            {res.TotalBkFromGateway, 3},
+		"TotalBkFromEvmNode":            {res.TotalBkFromEvmNode, 2},
+		"NewBkFromGatewayFirst":         {res.NewBkFromGatewayFirst, 2},
+		"NewBkFromEvmNodeFirst":         {res.NewBkFromEvmNodeFirst, 1},
+	}
+	for name, values := range expected {
+		if values[0] != values[1] {
+			t.Errorf("expected %s to be %d, got %d", name, values[1], values[0])
+		}
+	}
+	var replayed bkStats
+	replay(t, dir, &replayed, "--lead-time", "0", "--interval", "2", "--trail-time", "1")
+	if !reflect.DeepEqual(res, replayed) {
+		t.Errorf("expected replay to match the recorded run:\n%+v\ngot:\n%+v", res, replayed)
+	}
+}
+
+func TestBkFeedsCompareReorg(t *testing.T) {
+	gateway, node := mock.NewGateway(), mock.NewNode()
+	defer gateway.Close()
+	defer node.Close()
+
+	var (
+		res  bkStats
+		done = mock.RunCommand(t, NewBkFeedsCompareService().Run, []cli.Flag{
+			flags.Gateway,
+			flags.FeedWSEndpoint,
+			flags.BkFeedName,
+			flags.Interval,
+			flags.NumIntervals,
+			flags.LeadTime,
+			flags.BkTrailTime,
+			flags.BkIgnoreDelta,
+			flags.HistogramBuckets,
+		}, &res,
+			"--gateway", gateway.URL(),
+			"--feed-ws-endpoint", node.WSURL(),
+			"--lead-time", "0",
+			"--interval", "2",
+			"--trail-time", "1",
+		)
+		step = 200 * time.Millisecond
+
+		first   = mock.Block{Hash: hash(1), Number: 1}
+		orphan  = mock.Block{Hash: hash(2), Number: 2, ParentHash: hash(1)}
+		sibling = mock.Block{Hash: hash(12), Number: 2, ParentHash: hash(1)}
+		head    = mock.Block{Hash: hash(3), Number: 3, ParentHash: hash(12)}
+	)
+
+	waitSubscriptions(t, gateway, node)
+
+	for _, b := range []mock.Block{first, orphan} {
+		gateway.AnnounceBlock(b)
+		node.AnnounceBlock(b)
+		time.Sleep(step)
+	}
+
+	// The node switches to the sibling, the gateway learns about it from the next block only.
+	node.AnnounceBlock(sibling)
+	time.Sleep(step)
+	node.AnnounceBlock(head)
+	gateway.AnnounceBlock(head)
+
+	if err := <-done; err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	expected := map[string][2]int{
+		"TotalBkFromGateway":     {res.TotalBkFromGateway, 3},
+		"TotalBkFromEvmNode":     {res.TotalBkFromEvmNode, 4},
+		"CanonicalBkFromGateway": {res.CanonicalBkFromGateway, 2},
+		"OrphanedBkFromGateway":  {res.OrphanedBkFromGateway, 1},
+		"CanonicalBkFromEvmNode": {res.CanonicalBkFromEvmNode, 3},
+		"OrphanedBkFromEvmNode":  {res.OrphanedBkFromEvmNode, 1},
+		"NewBkFromEvmNodeFirst":  {res.NewBkFromEvmNodeFirst - res.BkSeenByBothFeedsEvmNodeFirst, 1},
+		"SiblingBlocks":          {res.SiblingBlocks, 1},
+		"GatewayReorgs":          {res.GatewayReorgs, 1},
+		"GatewayMaxReorgDepth":   {res.GatewayMaxReorgDepth, 1},
+		"EvmNodeReorgs":          {res.EvmNodeReorgs, 1},
+		"EvmNodeMaxReorgDepth":   {res.EvmNodeMaxReorgDepth, 1},
+	}
+	for name, values := range expected {
+		if values[0] != values[1] {
+			t.Errorf("expected %s to be %d, got %d", name, values[1], values[0])
+		}
+	}
+}
+
+func TestBkFeedsCompareLateSibling(t *testing.T) {
+	gateway, node := mock.NewGateway(), mock.NewNode()
+	defer gateway.Close()
+	defer node.Close()
+
+	var (
+		res  bkStats
+		done = mock.RunCommand(t, NewBkFeedsCompareService().Run, []cli.Flag{
+			flags.Gateway,
+			flags.FeedWSEndpoint,
+			flags.BkFeedName,
+			flags.Interval,
+			flags.NumIntervals,
+			flags.LeadTime,
+			flags.BkTrailTime,
+			flags.BkIgnoreDelta,
+			flags.HistogramBuckets,
+		}, &res,
+			"--gateway", gateway.URL(),
+			"--feed-ws-endpoint", node.WSURL(),
+			"--lead-time", "0",
+			"--interval", "2",
+			"--trail-time", "1",
+		)
+		step = 200 * time.Millisecond
+
+		blocks = []mock.Block{
+			{Hash: hash(1), Number: 1},
+			{Hash: hash(2), Number: 2, ParentHash: hash(1)},
+			{Hash: hash(3), Number: 3, ParentHash: hash(2)},
+		}
+		sibling = mock.Block{Hash: hash(12), Number: 2, ParentHash: hash(1)}
+	)
+
+	waitSubscriptions(t, gateway, node)
+
+	for _, b := range blocks {
+		gateway.AnnounceBlock(b)
+		node.AnnounceBlock(b)
+		time.Sleep(step)
+	}
+
+	// The gateway delivers the competing block after the head, the chain does not change.
+	gateway.AnnounceBlock(sibling)
+
+	if err := <-done; err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	expected := map[string][2]int{
+		"TotalBkFromGateway":     {res.TotalBkFromGateway, 4},
+		"TotalBkFromEvmNode":     {res.TotalBkFromEvmNode, 3},
+		"CanonicalBkFromGateway": {res.CanonicalBkFromGateway, 3},
+		"OrphanedBkFromGateway":  {res.OrphanedBkFromGateway, 1},
+		"CanonicalBkFromEvmNode": {res.CanonicalBkFromEvmNode, 3},
+		"OrphanedBkFromEvmNode":  {res.OrphanedBkFromEvmNode, 0},
+		"SiblingBlocks":          {res.SiblingBlocks, 1},
+		"GatewayReorgs":          {res.GatewayReorgs, 0},
+		"EvmNodeReorgs":          {res.EvmNodeReorgs, 0},
+	}
+	for name, values := range expected {
+		if values[0] != values[1] {
+			t.Errorf("expected %s to be %d, got %d", name, values[1], values[0])
+		}
+	}
+}
+
+func TestBkFeedsVerifyContents(t *testing.T) {
+	gateway, node := mock.NewGateway(), mock.NewNode()
+	defer gateway.Close()
+	defer node.Close()
+
+	var (
+		res  bkStats
+		dir  = t.TempDir()
+		done = mock.RunCommand(t, NewBkFeedsCompareService().Run, []cli.Flag{
+			flags.Gateway,
+			flags.FeedWSEndpoint,
+			flags.BkFeedName,
+			flags.VerifyBkContents,
+			flags.Interval,
+			flags.NumIntervals,
+			flags.LeadTime,
+			flags.BkTrailTime,
+			flags.BkIgnoreDelta,
+			flags.HistogramBuckets,
+			flags.Record,
+		}, &res,
+			"--gateway", gateway.URL(),
+			"--feed-ws-endpoint", node.WSURL(),
+			"--verify-block-contents",
+			"--lead-time", "0",
+			"--interval", "2",
+			"--trail-time", "1",
+			"--record", dir,
+		)
+		txs = signedTxs(t, 2)
+		now = time.Now()
+	)
+
+	waitSubscriptions(t, gateway, node)
+
+	valid := mock.Block{Hash: hash(1), Number: 1, Txs: txs, Time: now}
+	gateway.AnnounceBlock(valid)
+	node.AnnounceBlock(valid)
+
+	// The gateway sends a partial block with a wrong timestamp and an unknown uncle.
+	node.AnnounceBlock(mock.Block{Hash: hash(2), Number: 2, ParentHash: hash(1), Txs: txs, Time: now})
+	gateway.AnnounceBlock(mock.Block{
+		Hash:       hash(2),
+		Number:     2,
+		ParentHash: hash(1),
+		Txs:        txs[:1],
+		Uncles:     []string{hash(9)},
+		Time:       now.Add(time.Second),
+	})
+
+	if err := <-done; err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	expected := map[string][2]int{
+		"BkContentsVerified":   {res.BkContentsVerified, 2},
+		"BkContentsMismatched": {res.BkContentsMismatched, 1},
+	}
+	for name, values := range expected {
+		if values[0] != values[1] {
+			t.Errorf("expected %s to be %d, got %d", name, values[1], values[0])
+		}
+	}
+
+	mismatches := map[string]int{"header.timestamp": 1, "transactions": 1, "uncles": 1}
+	if !reflect.DeepEqual(res.ContentMismatches, mismatches) {
+		t.Errorf("expected mismatches %v, got %v", mismatches, res.ContentMismatches)
+	}
+
+	var replayed bkStats
+	replay(t, dir, &replayed, "--verify-block-contents", "--lead-time", "0", "--interval", "2", "--trail-time", "1")
+	if !reflect.DeepEqual(res, replayed) {
+		t.Errorf("expected replay to match the recorded run:\n%+v\ngot:\n%+v", res, replayed)
+	}
+}
+
+func TestBkFeedsCompareReceipts(t *testing.T) {
+	gateway, node := mock.NewGateway(), mock.NewNode()
+	defer gateway.Close()
+	defer node.Close()
+
+	var (
+		res  bkStats
+		done = mock.RunCommand(t, NewBkFeedsCompareService().Run, []cli.Flag{
+			flags.Gateway,
+			flags.FeedWSEndpoint,
+			flags.BkFeedName,
+			flags.Interval,
+			flags.NumIntervals,
+			flags.LeadTime,
+			flags.BkTrailTime,
+			flags.BkIgnoreDelta,
+			flags.HistogramBuckets,
+		}, &res,
+			"--gateway", gateway.URL(),
+			"--feed-ws-endpoint", node.WSURL(),
+			"--feed-name", "txReceipts",
+			"--lead-time", "0",
+			"--interval", "2",
+			"--trail-time", "1",
+		)
+		block = mock.Block{Hash: hash(1), Number: 1, Txs: signedTxs(t, 2)}
+	)
+
+	waitSubscriptions(t, gateway, node)
+
+	node.AnnounceBlock(block)
+	time.Sleep(300 * time.Millisecond)
+	gateway.AnnounceReceipts(block)
+
+	// Blocks without transactions have no receipts.
+	node.AnnounceBlock(mock.Block{Hash: hash(2), Number: 2, ParentHash: hash(1), Txs: []*types.Transaction{}})
+
+	if err := <-done; err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	expected := map[string][2]int{
+		"BkSeenByBothFeeds":             {res.BkSeenByBothFeeds, 1},
+		"BkSeenByBothFeedsEvmNodeFirst": {res.BkSeenByBothFeedsEvmNodeFirst, 1},
+		"TotalBkFromGateway":            {res.TotalBkFromGateway, 1},
+		"TotalBkFromEvmNode":            {res.TotalBkFromEvmNode, 1},
+	}
+	for name, values := range expected {
+		if values[0] != values[1] {
+			t.Errorf("expected %s to be %d, got %d", name, values[1], values[0])
+		}
+	}
+}
+
+func TestBkFeedsCompareEthOnBlock(t *testing.T) {
+	gateway, node := mock.NewGateway(), mock.NewNode()
+	defer gateway.Close()
+	defer node.Close()
+
+	var (
+		res  bkStats
+		dir  = t.TempDir()
+		done = mock.RunCommand(t, NewBkFeedsCompareService().Run, []cli.Flag{
+			flags.Gateway,
+			flags.FeedWSEndpoint,
+			flags.BkFeedName,
+			flags.EthCall,
+			flags.VerifyBkContents,
+			flags.Interval,
+			flags.NumIntervals,
+			flags.LeadTime,
+			flags.BkTrailTime,
+			flags.BkIgnoreDelta,
+			flags.HistogramBuckets,
+			flags.Record,
+		}, &res,
+			"--gateway", gateway.URL(),
+			"--feed-ws-endpoint", node.WSURL(),
+			"--feed-name", "ethOnBlock",
+			"--call", "height=eth_blockNumber",
+			"--verify-block-contents",
+			"--lead-time", "0",
+			"--interval", "2",
+			"--trail-time", "1",
+			"--record", dir,
+		)
+		step = 300 * time.Millisecond
+	)
+
+	waitSubscriptions(t, gateway, node)
+
+	gateway.AnnounceCall("height", 1, "0x1")
+	time.Sleep(step)
+	node.AnnounceBlock(mock.Block{Hash: hash(1), Number: 1})
+	time.Sleep(step)
+
+	// The gateway sends a wrong response.
+	node.AnnounceBlock(mock.Block{Hash: hash(2), Number: 2, ParentHash: hash(1)})
+	time.Sleep(step)
+	gateway.AnnounceCall("height", 2, "0x3")
+
+	if err := <-done; err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	expected := map[string][2]int{
+		"BkSeenByBothFeeds":             {res.BkSeenByBothFeeds, 2},
+		"BkSeenByBothFeedsGatewayFirst": {res.BkSeenByBothFeedsGatewayFirst, 1},
+		"BkSeenByBothFeedsEvmNodeFirst": {res.BkSeenByBothFeedsEvmNodeFirst, 1},
+		"BkContentsVerified":            {res.BkContentsVerified, 2},
+		"BkContentsMismatched":          {res.BkContentsMismatched, 1},
+		"CanonicalBkFromEvmNode":        {res.CanonicalBkFromEvmNode, 0},
+		"SiblingBlocks":                 {res.SiblingBlocks, 0},
+	}
+	for name, values := range expected {
+		if values[0] != values[1] {
+			t.Errorf("expected %s to be %d, got %d", name, values[1], values[0])
+		}
+	}
+
+	if mismatches := map[string]int{"response": 1}; !reflect.DeepEqual(res.ContentMismatches, mismatches) {
+		t.Errorf("expected mismatches %v, got %v", mismatches, res.ContentMismatches)
+	}
+
+	var replayed bkStats
+	replay(t, dir, &replayed, "--verify-block-contents", "--lead-time", "0", "--interval", "2", "--trail-time", "1")
+	if !reflect.DeepEqual(res, replayed) {
+		t.Errorf("expected replay to match the recorded run:\n%+v\ngot:\n%+v", res, replayed)
+	}
+}
+
+// replay replays the recording in dir as fast as possible and decodes the aggregate report.
+func replay(t *testing.T, dir string, res interface{}, args ...string) {
+	recordings, err := filepath.Glob(filepath.Join(dir, "*.rec"))
+	if err != nil || len(recordings) != 1 {
+		t.Fatalf("expected a single recording in %s, got %v: %v", dir, recordings, err)
+	}
+
+	err = <-mock.RunCommand(t, NewReplayService().Run, []cli.Flag{
+		flags.Recording,
+		flags.ReplaySpeed,
+		flags.MinGasPrice,
+		flags.FromAddresses,
+		flags.MethodIDs,
+		flags.MaxValue,
+		flags.TxTypes,
+		flags.MinPriorityFee,
+		flags.ContractCreation,
+		flags.Interval,
+		flags.NumIntervals,
+		flags.LeadTime,
+		flags.TxTrailTime,
+		flags.TxIgnoreDelta,
+		flags.VerifyBkContents,
+		flags.HistogramBuckets,
+	}, res, append([]string{"--recording", recordings[0]}, args...)...)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+}
@@ -9,6 +9,11 @@ import (
 	"math"
 	"os"
 	"performance/internal/pkg/flags"
+	"performance/internal/pkg/metrics"
+	"performance/internal/pkg/report"
+	"performance/internal/pkg/stats"
+	"performance/internal/pkg/txfee"
+	"performance/internal/pkg/txfilter"
 	"performance/internal/pkg/utils"
 	"performance/internal/pkg/ws"
 	"strconv"
@@ -21,28 +26,45 @@ import (
 )
 
 // TxFeedsCompareService represents a service which compares transaction feeds time difference
-// between EVM node and BX gateway.
+// between EVM nodes and BX gateways.
 type TxFeedsCompareService struct {
 	handlers chan handler
-	evmCh    chan *message
+	feeds    *feedQueue
 	evmTxCh  chan *message
-	bxCh     chan *message
 
-	hashes chan string
+	// sources are all feeds taking part in the comparison. The gateway and evmNode are the
+	// indices of the first BX and EVM sources which are compared in detail, or -1.
+	sources []*feedSource
+	gateway int
+	evmNode int
 
 	trailNewHashes        utils.HashSet
 	leadNewHashes         utils.HashSet
 	lowFeeHashes          utils.HashSet
 	highDeltaHashes       utils.HashSet
-	seenHashes            map[string]*hashEntry
+	seenHashes            map[string]*txEntry
 	timeToBeginComparison time.Time
 	timeToEndComparison   time.Time
+	intervalStart         time.Time
 	numIntervals          int
 
+	// clock is the real time, or the recorded time if the comparison is replayed.
+	clock  clock
+	replay *replayer
+
+	histogramBounds []float64
+	runStats        *txStats
+	runLatency      *latencyStats
+	metrics         *metrics.Feed
+	ignoreDelta     time.Duration
+
 	excTxContents bool
 	minGasPrice   *float64
 	addresses     utils.HashSet
+	criteria      txfilter.Criteria
+	filter        *txfilter.Filter
 	feedName      string
+	useGoGateway  bool
 
 	allHashesFile     *csv.Writer
 	missingHashesFile *bufio.Writer
@@ -51,22 +73,39 @@ type TxFeedsCompareService struct {
 // NewTxFeedsCompareService creates and initializes TxFeedsCompareService instance.
 func NewTxFeedsCompareService() *TxFeedsCompareService {
 	const bufSize = 8192
+	m := metrics.NewFeed(txFeed)
 	return &TxFeedsCompareService{
 		handlers:        make(chan handler),
-		bxCh:            make(chan *message),
-		evmCh:           make(chan *message),
+		feeds:           newFeedQueue(m),
 		evmTxCh:         make(chan *message, bufSize),
-		hashes:          make(chan string, bufSize),
 		lowFeeHashes:    utils.NewHashSet(),
 		highDeltaHashes: utils.NewHashSet(),
 		trailNewHashes:  utils.NewHashSet(),
 		leadNewHashes:   utils.NewHashSet(),
-		seenHashes:      make(map[string]*hashEntry),
+		seenHashes:      make(map[string]*txEntry),
+		runStats:        &txStats{},
+		runLatency:      newLatencyStats(),
+		metrics:         m,
+		clock:           realClock{},
 	}
 }
 
 // Run is an entry point to the TxFeedsCompareService.
 func (s *TxFeedsCompareService) Run(c *cli.Context) error {
+	if err := s.initSources(c); err != nil {
+		return err
+	}
+
+	endpoints := make(map[string]string)
+	for _, src := range s.sources {
+		endpoints[src.name] = src.uri
+	}
+
+	rep, err := report.New(c, endpoints)
+	if err != nil {
+		return err
+	}
+
 	if mgp := c.Float64(flags.MinGasPrice.Name); mgp != 0.0 {
 		s.minGasPrice = &mgp
 	}
@@ -78,13 +117,52 @@ func (s *TxFeedsCompareService) Run(c *cli.Context) error {
 		}
 	}
 
+	if err := s.initCriteria(c); err != nil {
+		return err
+	}
+
 	s.excTxContents = c.Bool(flags.ExcludeTxContents.Name)
+	s.feedName = c.String(flags.TxFeedName.Name)
+	filters := c.String(flags.Filters.Name)
+	if s.replay != nil {
+		s.excTxContents = s.replay.recording.header.ExcludeContents
+		s.feedName = s.replay.recording.header.FeedName
+		filters = s.replay.recording.header.Filters
+	}
+
+	if filters != "" {
+		if s.filter, err = txfilter.Parse(filters); err != nil {
+			return fmt.Errorf("error: invalid --%s value: %v", flags.Filters.Name, err)
+		}
+	}
+
+	bounds, err := stats.ParseBounds(c.String(flags.HistogramBuckets.Name))
+	if err != nil {
+		return fmt.Errorf("error: invalid --%s value: %v", flags.HistogramBuckets.Name, err)
+	}
+	s.histogramBounds = bounds
 
 	if (s.minGasPrice != nil || len(s.addresses) > 0) && s.excTxContents {
 		return fmt.Errorf(
 			"error: if filtering by minimum gas price or addresses, exclude-tx-contents must be false")
 	}
 
+	if !s.criteria.Empty() && s.excTxContents {
+		return fmt.Errorf("error: if filtering by transaction contents, exclude-tx-contents must be false")
+	}
+
+	if !s.criteria.Empty() && s.feedName == ws.FeedTransactionStatus {
+		return fmt.Errorf("error: filtering by transaction contents is not supported by the %s feed", s.feedName)
+	}
+
+	if s.filter != nil && s.excTxContents {
+		return fmt.Errorf("error: if filtering by --%s, exclude-tx-contents must be false", flags.Filters.Name)
+	}
+
+	if s.filter != nil && s.feedName == ws.FeedTransactionStatus {
+		return fmt.Errorf("error: --%s is not supported by the %s feed", flags.Filters.Name, s.feedName)
+	}
+
 	if s.minGasPrice != nil {
 		*s.minGasPrice *= 10e8
 	}
@@ -154,53 +232,103 @@ func (s *TxFeedsCompareService) Run(c *cli.Context) error {
 		leadTimeSec  = c.Int(flags.LeadTime.Name)
 		intervalSec  = c.Int(flags.Interval.Name)
 		trailTimeSec = c.Int(flags.TxTrailTime.Name)
-		evmURI       = c.String(flags.FeedWSEndpoint.Name)
+		ignoreDelta  = c.Int(flags.TxIgnoreDelta.Name)
+		verbose      = c.Bool(flags.Verbose.Name)
+		out          = rep.Output()
 		ctx, cancel  = context.WithCancel(context.Background())
 
 		readerGroup sync.WaitGroup
 		handleGroup sync.WaitGroup
 	)
 
-	s.timeToBeginComparison = time.Now().Add(time.Second * time.Duration(leadTimeSec))
+	defer cancel()
+
+	if addr := c.String(flags.MetricsAddr.Name); addr != "" {
+		metrics.Serve(ctx, addr)
+	}
+
+	// Contents readers of a source share a single connection, requests are multiplexed by ID.
+	contentsConns := make(map[int]*ws.Connection)
+	if !s.excTxContents && s.replay == nil {
+		for i, src := range s.sources {
+			if src.typ != sourceTypeEvm {
+				continue
+			}
+
+			log.Infof("Initiating connection to %s", src.uri)
+			conn, err := ws.Dial(src.uri, src.authHeader)
+			if err != nil {
+				return fmt.Errorf("cannot establish connection to %s: %v", src.uri, err)
+			}
+			log.Infof("Connection to %s established", src.uri)
+
+			defer func(uri string) {
+				if err := conn.Close(); err != nil {
+					log.Errorf("cannot close socket connection to %s: %v", uri, err)
+				}
+			}(src.uri)
+
+			contentsConns[i] = conn
+		}
+	}
+
+	s.timeToBeginComparison = s.clock.Now().Add(time.Second * time.Duration(leadTimeSec))
 	s.timeToEndComparison = s.timeToBeginComparison.Add(time.Second * time.Duration(intervalSec))
+	s.intervalStart = s.timeToBeginComparison
 	s.numIntervals = c.Int(flags.NumIntervals.Name)
-	s.feedName = c.String(flags.TxFeedName.Name)
+	s.ignoreDelta = time.Second * time.Duration(ignoreDelta)
 
-	readerGroup.Add(2)
-	if c.Bool(flags.UseCloudAPI.Name) {
-		go s.readFeedFromBX(
-			ctx,
-			&readerGroup,
-			s.bxCh,
-			c.String(flags.CloudAPIWSURI.Name),
-			c.String(flags.AuthHeader.Name),
-			c.Bool(flags.ExcludeDuplicates.Name),
-			c.Bool(flags.ExcludeFromBlockchain.Name),
-			false,
-		)
+	if dir := c.String(flags.Record.Name); dir != "" {
+		rec, err := newRecorder(dir, s.recordingHeader())
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			if err := rec.Close(); err != nil {
+				log.Errorf("cannot close recording: %v", err)
+			}
+		}()
+
+		s.feeds.recorder = rec
+	}
+
+	if s.replay != nil {
+		readerGroup.Add(1)
+		go s.replay.run(ctx, &readerGroup, s.feeds, s.evmTxCh, s.handlers, s.sourceGaps)
+
+		for _, src := range s.sources {
+			readerGroup.Add(1)
+			go discardHashes(ctx, &readerGroup, src.hashes)
+		}
 	} else {
-		go s.readFeedFromBX(
-			ctx,
-			&readerGroup,
-			s.bxCh,
-			c.String(flags.Gateway.Name),
-			c.String(flags.AuthHeader.Name),
-			c.Bool(flags.ExcludeDuplicates.Name),
-			c.Bool(flags.ExcludeFromBlockchain.Name),
-			c.Bool(flags.UseGoGateway.Name),
-		)
+		for i, src := range s.sources {
+			readerGroup.Add(1)
+			switch src.typ {
+			case sourceTypeBX:
+				go s.readFeedFromBX(
+					ctx,
+					&readerGroup,
+					i,
+					c.Bool(flags.ExcludeDuplicates.Name),
+					c.Bool(flags.ExcludeFromBlockchain.Name),
+				)
+			case sourceTypeEvm:
+				go s.readFeedFromEvm(ctx, &readerGroup, i)
+			}
+		}
 	}
-	go s.readFeedFromEvm(ctx, &readerGroup, s.evmCh, evmURI)
 
-	if !s.excTxContents {
+	for i, conn := range contentsConns {
 		const totalReaders = 4
-		for i := 0; i < totalReaders; i++ {
+		for j := 0; j < totalReaders; j++ {
 			readerGroup.Add(1)
 			go s.readTxContentsFromEvm(
 				ctx,
 				&readerGroup,
 				s.evmTxCh,
-				evmURI,
+				i,
+				conn,
 			)
 		}
 	}
@@ -208,14 +336,19 @@ func (s *TxFeedsCompareService) Run(c *cli.Context) error {
 	handleGroup.Add(1)
 	go s.handleUpdates(ctx, &handleGroup)
 
-	time.Sleep(time.Second * time.Duration(leadTimeSec))
+	s.clock.Sleep(time.Second * time.Duration(leadTimeSec))
 	for i := 0; i < s.numIntervals; i++ {
-		time.Sleep(time.Second * time.Duration(intervalSec))
+		s.clock.Sleep(time.Second * time.Duration(intervalSec))
 		s.clearTrailNewHashes()
-		time.Sleep(time.Second * time.Duration(trailTimeSec))
+		s.clock.Sleep(time.Second * time.Duration(trailTimeSec))
 
 		func(numIntervalsPassed int) {
 			s.handlers <- func() error {
+				var (
+					intervalEnd = s.clock.Now()
+					res, lat    = s.stats(ignoreDelta)
+				)
+
 				msg := fmt.Sprintf(
 					"-----------------------------------------------------\n"+
 						"Interval (%d/%d): %d seconds. \n"+
@@ -225,22 +358,37 @@ func (s *TxFeedsCompareService) Run(c *cli.Context) error {
 					numIntervalsPassed,
 					s.numIntervals,
 					intervalSec,
-					time.Now().Format("2006-01-02T15:04:05.000"),
+					intervalEnd.Format("2006-01-02T15:04:05.000"),
 					c.Float64(flags.MinGasPrice.Name),
-					s.stats(c.Int(flags.TxIgnoreDelta.Name),
-						c.Bool(flags.Verbose.Name)),
+					res.format(verbose),
 				)
 
+				rep.AddInterval(numIntervalsPassed, s.intervalStart, intervalEnd, res)
+				s.runStats.add(res)
+				s.runLatency.merge(lat)
+
 				s.drainChannels()
 
-				s.seenHashes = make(map[string]*hashEntry)
+				s.seenHashes = make(map[string]*txEntry)
 				s.leadNewHashes = utils.NewHashSet()
-				s.timeToEndComparison = time.Now().Add(time.Second * time.Duration(intervalSec))
+				for _, src := range s.sources {
+					src.droppedHashes = utils.NewHashSet()
+				}
+				s.intervalStart = s.clock.Now()
+				s.timeToEndComparison = s.intervalStart.Add(time.Second * time.Duration(intervalSec))
 
-				fmt.Print(msg)
+				fmt.Fprint(out, msg)
 
 				if numIntervalsPassed == s.numIntervals {
-					fmt.Printf("%d of %d intervals complete. Exiting.\n\n",
+					s.runStats.finish(s.runLatency, s.histogramBounds)
+
+					if s.numIntervals > 1 {
+						fmt.Fprintf(out, "-----------------------------------------------------\n"+
+							"Summary of %d intervals:\n%s\n",
+							s.numIntervals, s.runStats.format(verbose))
+					}
+
+					fmt.Fprintf(out, "%d of %d intervals complete. Exiting.\n\n",
 						numIntervalsPassed, s.numIntervals)
 				}
 
@@ -253,6 +401,115 @@ func (s *TxFeedsCompareService) Run(c *cli.Context) error {
 	readerGroup.Wait()
 	handleGroup.Wait()
 
+	rep.SetAggregate(s.runStats)
+
+	return rep.Write()
+}
+
+// initCriteria sets the criteria the transactions of all sources are filtered by.
+func (s *TxFeedsCompareService) initCriteria(c *cli.Context) error {
+	var err error
+
+	if from := c.String(flags.FromAddresses.Name); from != "" {
+		s.criteria.From = txfilter.ParseAddresses(from)
+	}
+
+	if ids := c.String(flags.MethodIDs.Name); ids != "" {
+		if s.criteria.MethodIDs, err = txfilter.ParseMethodIDs(ids); err != nil {
+			return fmt.Errorf("error: invalid --%s value: %v", flags.MethodIDs.Name, err)
+		}
+	}
+
+	if c.IsSet(flags.MinValue.Name) {
+		s.criteria.MinValue = txfee.FromEther(c.Float64(flags.MinValue.Name))
+	}
+
+	if c.IsSet(flags.MaxValue.Name) {
+		s.criteria.MaxValue = txfee.FromEther(c.Float64(flags.MaxValue.Name))
+	}
+
+	if s.criteria.MinValue != nil && s.criteria.MaxValue != nil && s.criteria.MinValue.Cmp(s.criteria.MaxValue) > 0 {
+		return fmt.Errorf("error: --%s cannot be greater than --%s", flags.MinValue.Name, flags.MaxValue.Name)
+	}
+
+	if types := c.String(flags.TxTypes.Name); types != "" {
+		if s.criteria.Types, err = txfilter.ParseTypes(types); err != nil {
+			return fmt.Errorf("error: invalid --%s value: %v", flags.TxTypes.Name, err)
+		}
+	}
+
+	if c.IsSet(flags.MinPriorityFee.Name) {
+		s.criteria.MinPriorityFee = txfee.FromGwei(c.Float64(flags.MinPriorityFee.Name))
+	}
+
+	s.criteria.ContractCreation = c.Bool(flags.ContractCreation.Name)
+
+	return nil
+}
+
+// sourceGaps returns the gaps of the source.
+func (s *TxFeedsCompareService) sourceGaps(source int) *[]ws.Gap {
+	return &s.sources[source].gaps
+}
+
+// recordingHeader describes the comparison in a recording.
+func (s *TxFeedsCompareService) recordingHeader() *recordingHeader {
+	header := &recordingHeader{
+		Feed:            txFeed,
+		FeedName:        s.feedName,
+		Start:           s.clock.Now(),
+		ExcludeContents: s.excTxContents,
+	}
+
+	if s.filter != nil {
+		header.Filters = s.filter.String()
+	}
+
+	for _, src := range s.sources {
+		header.Sources = append(header.Sources, recordedSource{Name: src.name, URI: src.uri, Type: src.typ})
+	}
+
+	return header
+}
+
+// initSources creates the compared sources from the replayed recording, the source flags
+// or, if there are none, from the gateway and node flags.
+func (s *TxFeedsCompareService) initSources(c *cli.Context) error {
+	s.useGoGateway = c.Bool(flags.UseGoGateway.Name)
+
+	if s.replay != nil {
+		for _, src := range s.replay.recording.header.Sources {
+			s.sources = append(s.sources, newFeedSource(src.Name, src.URI, src.Type, ""))
+		}
+	} else if values := c.StringSlice(flags.Source.Name); len(values) > 0 {
+		sources, err := parseSources(values, c.String(flags.AuthHeader.Name))
+		if err != nil {
+			return fmt.Errorf("error: invalid --%s value: %v", flags.Source.Name, err)
+		}
+		s.sources = sources
+	} else {
+		gatewayURI := c.String(flags.Gateway.Name)
+		if c.Bool(flags.UseCloudAPI.Name) {
+			gatewayURI = c.String(flags.CloudAPIWSURI.Name)
+			s.useGoGateway = false
+		}
+
+		s.sources = []*feedSource{
+			newFeedSource("gateway", gatewayURI, sourceTypeBX, c.String(flags.AuthHeader.Name)),
+			newFeedSource("node", c.String(flags.FeedWSEndpoint.Name), sourceTypeEvm, ""),
+		}
+	}
+
+	s.gateway, s.evmNode = -1, -1
+	for i, src := range s.sources {
+		switch {
+		case src.typ == sourceTypeBX && s.gateway < 0:
+			s.gateway = i
+		case src.typ == sourceTypeEvm && s.evmNode < 0:
+			s.evmNode = i
+		}
+	}
+
 	return nil
 }
 
@@ -262,58 +519,25 @@ func (s *TxFeedsCompareService) handleUpdates(
 ) {
 	defer wg.Done()
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case update, ok := <-s.handlers:
-			if !ok {
-				continue
-			}
-
-			if err := update(); err != nil {
-				log.Errorf("error in update function: %v", err)
-			}
-		case data, ok := <-s.evmTxCh:
-			if !ok {
-				continue
-			}
-
-			if err := s.processTxContentsFromEvm(data); err != nil {
-				log.Errorf("error: %v", err)
-			}
-		default:
-			select {
-			case data, ok := <-s.bxCh:
-				if !ok {
-					continue
-				}
-
-				if err := s.processFeedFromBX(data); err != nil {
-					log.Errorf("error: %v", err)
-				}
-			case data, ok := <-s.evmCh:
-				if !ok {
-					continue
-				}
+	handleEvents(ctx, s.handlers, s.evmTxCh, s.feeds, s.processTxContentsFromEvm, s.processFeed)
+}
 
-				if err := s.processFeedFromEvm(data); err != nil {
-					log.Errorf("error: %v", err)
-				}
-			default:
-				break
-			}
-		}
+// processFeed processes a message of the transaction feed of a source.
+func (s *TxFeedsCompareService) processFeed(data *message) error {
+	if s.sources[data.source].typ == sourceTypeBX {
+		return s.processFeedFromBX(data)
 	}
+
+	return s.processFeedFromEvm(data)
 }
 
 func (s *TxFeedsCompareService) processFeedFromBX(data *message) error {
 	if data.err != nil {
-		return fmt.Errorf("failed to read message from feed %q: %v",
-			s.feedName, data.err)
+		return fmt.Errorf("failed to read message from feed %q of %s: %v",
+			s.feedName, s.sources[data.source].name, data.err)
 	}
 
-	timeReceived := time.Now()
+	timeReceived := data.received
 
 	var msg bxTxFeedResponse
 	if err := json.Unmarshal(data.bytes, &msg); err != nil {
@@ -321,7 +545,8 @@ func (s *TxFeedsCompareService) processFeedFromBX(data *message) error {
 	}
 
 	txHash := msg.Params.Result.TxHash
-	log.Debugf("got message at %s (BXR node, ALL), txHash: %s", timeReceived, txHash)
+	log.Debugf("got message at %s (BXR node %s, ALL), txHash: %s, status: %s",
+		timeReceived, s.sources[data.source].name, txHash, msg.Params.Result.Status)
 
 	if timeReceived.Before(s.timeToBeginComparison) {
 		s.leadNewHashes.Add(txHash)
@@ -329,6 +554,10 @@ func (s *TxFeedsCompareService) processFeedFromBX(data *message) error {
 	}
 
 	if !s.excTxContents {
+		if !s.criteria.Match(&msg.Params.Result.TxContents) {
+			return nil
+		}
+
 		to := msg.Params.Result.TxContents.To
 		if !s.addresses.Empty() && to != nil && !s.addresses.Contains(*to) {
 			return nil
@@ -342,27 +571,13 @@ func (s *TxFeedsCompareService) processFeedFromBX(data *message) error {
 			}
 
 			if float64(gasPrice) < *s.minGasPrice {
-				s.lowFeeHashes.Add(txHash)
+				s.ignoreLowFee(txHash)
 				return nil
 			}
 		}
 	}
 
-	if entry, ok := s.seenHashes[txHash]; ok {
-		if entry.bxrTimeReceived.IsZero() {
-			entry.bxrTimeReceived = timeReceived
-		}
-	} else if timeReceived.Before(s.timeToEndComparison) &&
-		!s.trailNewHashes.Contains(txHash) &&
-		!s.leadNewHashes.Contains(txHash) {
-
-		s.seenHashes[txHash] = &hashEntry{
-			hash:            txHash,
-			bxrTimeReceived: timeReceived,
-		}
-	} else {
-		s.trailNewHashes.Add(txHash)
-	}
+	s.markSeen(txHash, data.source, timeReceived)
 
 	return nil
 }
@@ -373,7 +588,7 @@ func (s *TxFeedsCompareService) processFeedFromEvm(data *message) error {
 			"failed to read message from EVM feed: %v", data.err)
 	}
 
-	timeReceived := time.Now()
+	timeReceived := data.received
 
 	var msg evmTxFeedResponse
 	if err := json.Unmarshal(data.bytes, &msg); err != nil {
@@ -381,7 +596,8 @@ func (s *TxFeedsCompareService) processFeedFromEvm(data *message) error {
 	}
 
 	txHash := msg.Params.Result
-	log.Debugf("got message at %s (EVM node, SUB), txHash: %s", timeReceived, txHash)
+	log.Debugf("got message at %s (EVM node %s, SUB), txHash: %s",
+		timeReceived, s.sources[data.source].name, txHash)
 
 	if timeReceived.Before(s.timeToBeginComparison) {
 		s.leadNewHashes.Add(txHash)
@@ -389,21 +605,18 @@ func (s *TxFeedsCompareService) processFeedFromEvm(data *message) error {
 	}
 
 	if !s.excTxContents {
-		go func() { s.hashes <- txHash }()
-	} else if entry, ok := s.seenHashes[txHash]; ok {
-		if entry.evmTimeReceived.IsZero() {
-			entry.evmTimeReceived = timeReceived
-		}
-	} else if timeReceived.Before(s.timeToEndComparison) &&
-		!s.trailNewHashes.Contains(txHash) &&
-		!s.leadNewHashes.Contains(txHash) {
-
-		s.seenHashes[txHash] = &hashEntry{
-			evmTimeReceived: timeReceived,
-			hash:            txHash,
+		src := s.sources[data.source]
+		if !requestContents(src.hashes, txHash, s.metrics, src.name) {
+			src.droppedHashes.Add(txHash)
+			if src.dropped++; src.dropped == 1 {
+				log.Warnf("contents queue of %s is full, dropping txHash %s, "+
+					"further drops are logged at debug level", src.name, txHash)
+			} else {
+				log.Debugf("contents queue of %s is full, dropping txHash %s", src.name, txHash)
+			}
 		}
 	} else {
-		s.trailNewHashes.Add(txHash)
+		s.markSeen(txHash, data.source, timeReceived)
 	}
 
 	return nil
@@ -417,70 +630,192 @@ func (s *TxFeedsCompareService) processTxContentsFromEvm(data *message) error {
 			txHash, data.err)
 	}
 
-	timeReceived := time.Now()
+	timeReceived := data.received
 
 	var msg evmTxContentsResponse
 	if err := json.Unmarshal(data.bytes, &msg); err != nil {
 		return fmt.Errorf("failed to unmarshal message: %v", err)
 	}
 
-	log.Debugf("got message at %s (EVM node, TXC), txHash: %s", timeReceived, txHash)
+	log.Debugf("got message at %s (EVM node %s, TXC), txHash: %s",
+		timeReceived, s.sources[data.source].name, txHash)
 
 	if msg.Result == nil {
 		return nil
 	}
 
-	if !s.addresses.Empty() && !s.addresses.Contains(msg.Result.To) {
+	// The gateway applies the filters to its feed, the transactions of the node are
+	// filtered the same way.
+	if s.filter != nil && !s.filter.Match(msg.Result) {
 		return nil
 	}
 
-	gasPrice, err := parseGasPrice(msg.Result.GasPrice)
-	if err != nil {
-		return fmt.Errorf("cannot parse gas price %q for transaction %q: %v",
-			msg.Result.GasPrice, txHash, err)
+	if !s.criteria.Match(msg.Result) {
+		return nil
 	}
 
-	if s.minGasPrice != nil && float64(gasPrice) < *s.minGasPrice {
-		s.lowFeeHashes.Add(txHash)
+	if to := msg.Result.To; !s.addresses.Empty() && (to == nil || !s.addresses.Contains(*to)) {
 		return nil
 	}
 
+	if price := msg.Result.GasPrice; price != nil {
+		gasPrice, err := parseGasPrice(*price)
+		if err != nil {
+			return fmt.Errorf("cannot parse gas price %q for transaction %q: %v",
+				*price, txHash, err)
+		}
+
+		if s.minGasPrice != nil && float64(gasPrice) < *s.minGasPrice {
+			s.ignoreLowFee(txHash)
+			return nil
+		}
+	}
+
+	s.markSeen(txHash, data.source, timeReceived)
+
+	return nil
+}
+
+// markSeen records the time a transaction was received from a source.
+func (s *TxFeedsCompareService) markSeen(txHash string, source int, timeReceived time.Time) {
 	if entry, ok := s.seenHashes[txHash]; ok {
-		if entry.evmTimeReceived.IsZero() {
-			entry.evmTimeReceived = timeReceived
+		if entry.timeReceived[source].IsZero() {
+			entry.timeReceived[source] = timeReceived
+			s.recordSeen(entry, source)
 		}
 	} else if timeReceived.Before(s.timeToEndComparison) &&
 		!s.trailNewHashes.Contains(txHash) &&
 		!s.leadNewHashes.Contains(txHash) {
 
-		s.seenHashes[txHash] = &hashEntry{
-			evmTimeReceived: timeReceived,
-			hash:            txHash,
+		entry := &txEntry{
+			hash:         txHash,
+			timeReceived: make([]time.Time, len(s.sources)),
 		}
+		entry.timeReceived[source] = timeReceived
+		s.seenHashes[txHash] = entry
+		s.recordSeen(entry, source)
 	} else {
 		s.trailNewHashes.Add(txHash)
 	}
+}
 
-	return nil
+// recordSeen updates the metrics after a transaction was received from a source.
+func (s *TxFeedsCompareService) recordSeen(entry *txEntry, source int) {
+	var (
+		timeReceived = entry.timeReceived[source]
+		first        = source
+		seen         = 1
+	)
+
+	s.metrics.Seen(s.sources[source].name)
+
+	for i, t := range entry.timeReceived {
+		if i == source || t.IsZero() {
+			continue
+		}
+
+		seen++
+		if t.Before(entry.timeReceived[first]) {
+			first = i
+		}
+
+		if absDuration(timeReceived.Sub(t)) > s.ignoreDelta {
+			s.metrics.HighDeltaIgnored()
+			continue
+		}
+
+		// Pairs are labeled in the order of sources, so that each pair has a single series.
+		if i < source {
+			s.metrics.Delta(s.sources[i].name, s.sources[source].name, t.Sub(timeReceived))
+		} else {
+			s.metrics.Delta(s.sources[source].name, s.sources[i].name, timeReceived.Sub(t))
+		}
+	}
+
+	if seen == 2 && absDuration(timeReceived.Sub(entry.timeReceived[first])) <= s.ignoreDelta {
+		s.metrics.FirstSeen(s.sources[first].name)
+	}
+}
+
+// recordMissing updates the metrics with the transactions which were not received from
+// some of the sources, unless the sources were down at that time.
+func (s *TxFeedsCompareService) recordMissing() {
+	for _, entry := range s.seenHashes {
+		var first time.Time
+		for _, t := range entry.timeReceived {
+			if !t.IsZero() && (first.IsZero() || t.Before(first)) {
+				first = t
+			}
+		}
+
+		for i, t := range entry.timeReceived {
+			if t.IsZero() && !inGap(s.sources[i].gaps, first) &&
+				!s.sources[i].droppedHashes.Contains(entry.hash) {
+				s.metrics.Missing(s.sources[i].name)
+			}
+		}
+	}
+}
+
+func (s *TxFeedsCompareService) ignoreLowFee(txHash string) {
+	if !s.lowFeeHashes.Contains(txHash) {
+		s.lowFeeHashes.Add(txHash)
+		s.metrics.LowFeeIgnored()
+	}
+}
+
+func (s *TxFeedsCompareService) stats(ignoreDelta int) (*txStats, *latencyStats) {
+	var (
+		res     = &txStats{}
+		latency = newLatencyStats()
+	)
+
+	if s.gateway >= 0 && s.evmNode >= 0 {
+		s.pairStats(res, latency, ignoreDelta)
+	}
+
+	if !res.pair || len(s.sources) > 2 {
+		res.Sources = s.sourceStats(ignoreDelta)
+	}
+
+	s.recordMissing()
+
+	for _, src := range s.sources {
+		res.ContentsDropped += len(src.droppedHashes)
+	}
+	res.LowFeeTxIgnored = len(s.lowFeeHashes)
+	res.HighDeltaTxIgnored = len(s.highDeltaHashes)
+	res.finish(latency, s.histogramBounds)
+
+	return res, latency
 }
 
-func (s *TxFeedsCompareService) stats(ignoreDelta int, verbose bool) string {
+// pairStats compares the primary gateway and EVM node sources.
+func (s *TxFeedsCompareService) pairStats(res *txStats, latency *latencyStats, ignoreDelta int) {
 	const timestampFormat = "2006-01-02T15:04:05.000"
 
 	var (
-		txSeenByBothFeedsGatewayFirst      = 0
-		txSeenByBothFeedsEvmNodeFirst      = 0
-		txReceivedByGatewayFirstTotalDelta = 0.0
-		txReceivedByEvmNodeFirstTotalDelta = 0.0
-		newTxFromGatewayFeedFirst          = 0
-		newTxFromEvmNodeFeedFirst          = 0
-		totalTxFromGateway                 = 0
-		totalTxFromEvmNode                 = 0
+		gateway = s.sources[s.gateway]
+		evmNode = s.sources[s.evmNode]
 	)
 
+	res.pair = true
+
 	for txHash, entry := range s.seenHashes {
-		if entry.bxrTimeReceived.IsZero() {
-			evmNodeTimeReceived := entry.evmTimeReceived
+		var (
+			gatewayTimeReceived = entry.timeReceived[s.gateway]
+			evmNodeTimeReceived = entry.timeReceived[s.evmNode]
+		)
+
+		if gatewayTimeReceived.IsZero() && evmNodeTimeReceived.IsZero() {
+			continue
+		}
+
+		if gatewayTimeReceived.IsZero() {
+			if inGap(gateway.gaps, evmNodeTimeReceived) {
+				res.TxIgnoredDueToOutages++
+				continue
+			}
 
 			if s.missingHashesFile != nil {
 				line := fmt.Sprintf("%s\n", txHash)
@@ -494,12 +829,20 @@ func (s *TxFeedsCompareService) stats(ignoreDelta int, verbose bool) string {
 					log.Errorf("cannot add txHash %q to all hashes file: %v", txHash, err)
 				}
 			}
-			newTxFromEvmNodeFeedFirst++
-			totalTxFromEvmNode++
+			res.NewTxFromEvmNodeFirst++
+			res.TotalTxFromEvmNode++
 			continue
 		}
-		if entry.evmTimeReceived.IsZero() {
-			gatewayTimeReceived := entry.bxrTimeReceived
+		if evmNodeTimeReceived.IsZero() {
+			if inGap(evmNode.gaps, gatewayTimeReceived) {
+				res.TxIgnoredDueToOutages++
+				continue
+			}
+
+			// The node announced the transaction, but its contents were never requested.
+			if evmNode.droppedHashes.Contains(txHash) {
+				continue
+			}
 
 			if s.allHashesFile != nil {
 				record := []string{txHash, gatewayTimeReceived.Format(timestampFormat), "0", "0"}
@@ -507,25 +850,23 @@ func (s *TxFeedsCompareService) stats(ignoreDelta int, verbose bool) string {
 					log.Errorf("cannot add txHash %q to all hashes file: %v", txHash, err)
 				}
 			}
-			newTxFromGatewayFeedFirst++
-			totalTxFromGateway++
+			res.NewTxFromGatewayFirst++
+			res.TotalTxFromGateway++
 			continue
 		}
 
-		var (
-			evmNodeTimeReceived = entry.evmTimeReceived
-			gatewayTimeReceived = entry.bxrTimeReceived
-			timeReceivedDiff    = gatewayTimeReceived.Sub(evmNodeTimeReceived)
-		)
+		timeReceivedDiff := gatewayTimeReceived.Sub(evmNodeTimeReceived)
 
-		totalTxFromGateway++
-		totalTxFromEvmNode++
+		res.TotalTxFromGateway++
+		res.TotalTxFromEvmNode++
 
 		if math.Abs(timeReceivedDiff.Seconds()) > float64(ignoreDelta) {
 			s.highDeltaHashes.Add(entry.hash)
 			continue
 		}
 
+		latency.add(timeReceivedDiff)
+
 		if s.allHashesFile != nil {
 			record := []string{
 				txHash,
@@ -540,37 +881,142 @@ func (s *TxFeedsCompareService) stats(ignoreDelta int, verbose bool) string {
 
 		switch {
 		case gatewayTimeReceived.Before(evmNodeTimeReceived):
-			newTxFromGatewayFeedFirst++
-			txSeenByBothFeedsGatewayFirst++
-			txReceivedByGatewayFirstTotalDelta += -timeReceivedDiff.Seconds()
+			res.NewTxFromGatewayFirst++
+			res.TxSeenByBothFeedsGatewayFirst++
 		case evmNodeTimeReceived.Before(gatewayTimeReceived):
-			newTxFromEvmNodeFeedFirst++
-			txSeenByBothFeedsEvmNodeFirst++
-			txReceivedByEvmNodeFirstTotalDelta += timeReceivedDiff.Seconds()
+			res.NewTxFromEvmNodeFirst++
+			res.TxSeenByBothFeedsEvmNodeFirst++
 		}
 	}
 
 	var (
-		newTxSeenByBothFeeds = txSeenByBothFeedsGatewayFirst +
-			txSeenByBothFeedsEvmNodeFirst
-		txReceivedByGatewayFirstAvgDelta = 0
-		txReceivedByEvmNodeFirstAvgDelta = 0
-		txPercentageSeenByGatewayFirst   = 0
+		now                             = s.clock.Now()
+		gatewayOutages, gatewayDowntime = gapsSummary(gateway.gaps, s.intervalStart, now)
+		evmNodeOutages, evmNodeDowntime = gapsSummary(evmNode.gaps, s.intervalStart, now)
+	)
+
+	res.GatewayOutages = gatewayOutages
+	res.GatewayDowntimeMs = gatewayDowntime.Milliseconds()
+	res.EvmNodeOutages = evmNodeOutages
+	res.EvmNodeDowntimeMs = evmNodeDowntime.Milliseconds()
+}
+
+// sourceStats compares every pair of sources. Only the transactions received within
+// ignoreDelta seconds from each other are taken into account.
+func (s *TxFeedsCompareService) sourceStats(ignoreDelta int) *sourceStats {
+	var (
+		res      = newSourceStats(s.sourceNames())
+		maxDelta = time.Second * time.Duration(ignoreDelta)
 	)
 
-	if txSeenByBothFeedsGatewayFirst != 0 {
-		txReceivedByGatewayFirstAvgDelta = int(math.Round(
-			txReceivedByGatewayFirstTotalDelta / float64(txSeenByBothFeedsGatewayFirst) * 1000))
+	for _, entry := range s.seenHashes {
+		var (
+			first, last time.Time
+			firstSource int
+			seen        int
+		)
+
+		for i, t := range entry.timeReceived {
+			if t.IsZero() {
+				continue
+			}
+
+			res.Seen[i]++
+			seen++
+
+			if first.IsZero() || t.Before(first) {
+				first, firstSource = t, i
+			}
+			if t.After(last) {
+				last = t
+			}
+
+			for j, other := range entry.timeReceived[:i] {
+				if other.IsZero() || absDuration(t.Sub(other)) > maxDelta {
+					continue
+				}
+
+				res.Compared[i][j]++
+				res.Compared[j][i]++
+
+				switch {
+				case t.Before(other):
+					res.Wins[i][j]++
+				case other.Before(t):
+					res.Wins[j][i]++
+				}
+			}
+		}
+
+		if seen > 1 && last.Sub(first) <= maxDelta {
+			res.FirstSeen[firstSource]++
+		}
 	}
 
-	if txSeenByBothFeedsEvmNodeFirst != 0 {
-		txReceivedByEvmNodeFirstAvgDelta = int(math.Round(
-			txReceivedByEvmNodeFirstTotalDelta / float64(txSeenByBothFeedsEvmNodeFirst) * 1000))
+	return res
+}
+
+func (s *TxFeedsCompareService) sourceNames() []string {
+	names := make([]string, 0, len(s.sources))
+	for _, src := range s.sources {
+		names = append(names, src.name)
 	}
 
-	if newTxSeenByBothFeeds != 0 {
-		txPercentageSeenByGatewayFirst = int(
-			(float64(txSeenByBothFeedsGatewayFirst) / float64(newTxSeenByBothFeeds)) * 100)
+	return names
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
+
+// add accumulates counters of an interval into the run totals.
+func (r *txStats) add(other *txStats) {
+	r.TxSeenByBothFeedsGatewayFirst += other.TxSeenByBothFeedsGatewayFirst
+	r.TxSeenByBothFeedsEvmNodeFirst += other.TxSeenByBothFeedsEvmNodeFirst
+	r.TotalTxFromGateway += other.TotalTxFromGateway
+	r.TotalTxFromEvmNode += other.TotalTxFromEvmNode
+	r.TxIgnoredDueToOutages += other.TxIgnoredDueToOutages
+	r.NewTxFromGatewayFirst += other.NewTxFromGatewayFirst
+	r.NewTxFromEvmNodeFirst += other.NewTxFromEvmNodeFirst
+	r.GatewayOutages += other.GatewayOutages
+	r.GatewayDowntimeMs += other.GatewayDowntimeMs
+	r.EvmNodeOutages += other.EvmNodeOutages
+	r.EvmNodeDowntimeMs += other.EvmNodeDowntimeMs
+	r.ContentsDropped += other.ContentsDropped
+	// Ignored hashes are collected over the whole run.
+	r.LowFeeTxIgnored = other.LowFeeTxIgnored
+	r.HighDeltaTxIgnored = other.HighDeltaTxIgnored
+	r.pair = r.pair || other.pair
+
+	if other.Sources != nil {
+		if r.Sources == nil {
+			r.Sources = newSourceStats(other.Sources.Sources)
+		}
+		r.Sources.add(other.Sources)
+	}
+}
+
+// finish computes the values which are derived from the counters and latencies.
+func (r *txStats) finish(latency *latencyStats, bounds []float64) {
+	r.TxSeenByBothFeeds = r.TxSeenByBothFeedsGatewayFirst + r.TxSeenByBothFeedsEvmNodeFirst
+	r.Latency = latency.summary(bounds)
+	r.AvgDeltaGatewayFirstMs = int(math.Round(r.Latency.GatewayFirst.Mean))
+	r.AvgDeltaEvmNodeFirstMs = int(math.Round(r.Latency.EvmNodeFirst.Mean))
+	r.PercentageSeenByGatewayFirst = 0
+
+	if r.TxSeenByBothFeeds != 0 {
+		r.PercentageSeenByGatewayFirst = int(
+			(float64(r.TxSeenByBothFeedsGatewayFirst) / float64(r.TxSeenByBothFeeds)) * 100)
+	}
+}
+
+func (r *txStats) format(verbose bool) string {
+	if !r.pair {
+		return r.Sources.String()
 	}
 
 	results := fmt.Sprintf(
@@ -584,31 +1030,43 @@ func (s *TxFeedsCompareService) stats(ignoreDelta int, verbose bool) string {
 			"\nTotal Transactions summary:\n"+
 			"Total tx from gateway: %d\n"+
 			"Total tx from evm node: %d\n"+
-			"Number of low fee tx ignored: %d\n",
-
-		newTxSeenByBothFeeds,
-		txSeenByBothFeedsGatewayFirst,
-		txSeenByBothFeedsEvmNodeFirst,
-		txPercentageSeenByGatewayFirst,
-		txReceivedByGatewayFirstAvgDelta,
-		txReceivedByEvmNodeFirstAvgDelta,
-		totalTxFromGateway,
-		totalTxFromEvmNode,
-		len(s.lowFeeHashes))
-
-	verboseResults := fmt.Sprintf(
-		"Number of high delta tx ignored: %d\n"+
-			"Number of new transactions received first from gateway: %d\n"+
-			"Number of new transactions received first from node: %d\n"+
-			"Total number of transactions seen: %d\n",
-		len(s.highDeltaHashes),
-		newTxFromGatewayFeedFirst,
-		newTxFromEvmNodeFeedFirst,
-		newTxFromEvmNodeFeedFirst+newTxFromGatewayFeedFirst,
-	)
+			"Number of low fee tx ignored: %d\n"+
+			"Number of tx ignored due to feed outages: %d\n"+
+			"Number of dropped tx contents requests: %d\n"+
+			"Gateway feed outages: %d (%s)\n"+
+			"Evm node feed outages: %d (%s)\n",
+
+		r.TxSeenByBothFeeds,
+		r.TxSeenByBothFeedsGatewayFirst,
+		r.TxSeenByBothFeedsEvmNodeFirst,
+		r.PercentageSeenByGatewayFirst,
+		r.AvgDeltaGatewayFirstMs,
+		r.AvgDeltaEvmNodeFirstMs,
+		r.TotalTxFromGateway,
+		r.TotalTxFromEvmNode,
+		r.LowFeeTxIgnored,
+		r.TxIgnoredDueToOutages,
+		r.ContentsDropped,
+		r.GatewayOutages, time.Duration(r.GatewayDowntimeMs)*time.Millisecond,
+		r.EvmNodeOutages, time.Duration(r.EvmNodeDowntimeMs)*time.Millisecond)
+
+	results += r.Latency.String()
+
+	if r.Sources != nil {
+		results += r.Sources.String()
+	}
 
 	if verbose {
-		results += verboseResults
+		results += fmt.Sprintf(
+			"Number of high delta tx ignored: %d\n"+
+				"Number of new transactions received first from gateway: %d\n"+
+				"Number of new transactions received first from node: %d\n"+
+				"Total number of transactions seen: %d\n",
+			r.HighDeltaTxIgnored,
+			r.NewTxFromGatewayFirst,
+			r.NewTxFromEvmNodeFirst,
+			r.NewTxFromEvmNodeFirst+r.NewTxFromGatewayFirst,
+		)
 	}
 
 	return results
@@ -617,147 +1075,74 @@ func (s *TxFeedsCompareService) stats(ignoreDelta int, verbose bool) string {
 func (s *TxFeedsCompareService) readFeedFromBX(
 	ctx context.Context,
 	wg *sync.WaitGroup,
-	out chan<- *message,
-	uri string,
-	authHeader string,
+	source int,
 	excDuplicates bool,
 	excFromBlockchain bool,
-	useGoGateway bool,
 ) {
-	defer wg.Done()
-
-	log.Infof("Initiating connection to: %s", uri)
-	conn, err := ws.NewConnection(uri, authHeader)
-	if err != nil {
-		log.Errorf("cannot establish connection to %s: %v", uri, err)
-		return
-	}
-	log.Infof("Connection to %s established", uri)
-
-	defer func() {
-		if err := conn.Close(); err != nil {
-			log.Errorf("cannot close socket connection to %s: %v", uri, err)
-		}
-	}()
-
-	sub, err := conn.SubscribeTxFeedBX(1, s.feedName, s.excTxContents, !excDuplicates,
-		!excFromBlockchain, useGoGateway)
-	if err != nil {
-		log.Errorf("cannot subscribe to feed %q: %v", s.feedName, err)
-		return
+	var filters string
+	if s.filter != nil {
+		filters = s.filter.String()
 	}
 
-	defer func() {
-		if err := sub.Unsubscribe(); err != nil {
-			log.Errorf("cannot unsubscribe from feed %q: %v", s.feedName, err)
-		}
-	}()
-
-	for {
-		var (
-			data, err = sub.NextMessage()
-			msg       = &message{
-				bytes: data,
-				err:   err,
-			}
-		)
+	src := s.sources[source]
+	sub := ws.NewResilientSubscription(
+		src.uri,
+		src.authHeader,
+		func(conn *ws.Connection) (*ws.Subscription, error) {
+			return conn.SubscribeTxFeedBX(s.feedName, s.excTxContents, !excDuplicates,
+				!excFromBlockchain, s.useGoGateway, filters)
+		},
+		reconnectOptions(ctx, s.handlers, s.feeds, source, &src.gaps, s.metrics, src.name),
+	)
 
-		select {
-		case <-ctx.Done():
-			return
-		case out <- msg:
-		}
-	}
+	readFeed(ctx, wg, s.feeds, sub, source, src.name, s.feedName)
 }
 
 func (s *TxFeedsCompareService) readFeedFromEvm(
 	ctx context.Context,
 	wg *sync.WaitGroup,
-	out chan<- *message,
-	uri string,
+	source int,
 ) {
-	defer wg.Done()
-
-	log.Infof("Initiating connection to %s", uri)
-	conn, err := ws.NewConnection(uri, "")
-	if err != nil {
-		log.Errorf("cannot establish connection to %s: %v", uri, err)
-		return
-	}
-	log.Infof("Connection to %s established", uri)
-
-	defer func() {
-		if err := conn.Close(); err != nil {
-			log.Errorf("cannot close socket connection to %s: %v", uri, err)
-		}
-	}()
-
-	sub, err := conn.SubscribeTxFeedEvm(1)
-	if err != nil {
-		log.Errorf("cannot subscribe to EVM feed: %v", err)
-		return
-	}
-
-	defer func() {
-		if err := sub.Unsubscribe(); err != nil {
-			log.Errorf("cannot unsubscribe from EVM feed: %v", err)
-		}
-	}()
-
-	for {
-		var (
-			data, err = sub.NextMessage()
-			msg       = &message{
-				bytes: data,
-				err:   err,
-			}
-		)
+	src := s.sources[source]
+	sub := ws.NewResilientSubscription(
+		src.uri,
+		src.authHeader,
+		func(conn *ws.Connection) (*ws.Subscription, error) {
+			return conn.SubscribeTxFeedEvm()
+		},
+		reconnectOptions(ctx, s.handlers, s.feeds, source, &src.gaps, s.metrics, src.name),
+	)
 
-		select {
-		case <-ctx.Done():
-			return
-		case out <- msg:
-		}
-	}
+	readFeed(ctx, wg, s.feeds, sub, source, src.name, "newPendingTransactions")
 }
 
 func (s *TxFeedsCompareService) readTxContentsFromEvm(
 	ctx context.Context,
 	wg *sync.WaitGroup,
 	out chan<- *message,
-	uri string,
+	source int,
+	conn *ws.Connection,
 ) {
 	defer wg.Done()
 
-	log.Infof("Initiating connection to %s", uri)
-	conn, err := ws.NewConnection(uri, "")
-	if err != nil {
-		log.Errorf("cannot establish connection to %s: %v", uri, err)
-		return
-	}
-	log.Infof("Connection to %s established", uri)
-
-	defer func() {
-		if err := conn.Close(); err != nil {
-			log.Errorf("cannot close socket connection to %s: %v", uri, err)
-		}
-	}()
-
+	hashes := s.sources[source].hashes
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case txHash, ok := <-s.hashes:
+		case txHash, ok := <-hashes:
 			if !ok {
 				return
 			}
 
 			var (
-				data, err = conn.Call(ws.NewRequest(1, "eth_getTransactionByHash", []interface{}{txHash}))
-				msg       = &message{
-					hash:  txHash,
-					err:   err,
-					bytes: data,
+				res, err = conn.CallMessage(ctx, ws.NewRequest(1, "eth_getTransactionByHash", []interface{}{txHash}))
+				msg      = &message{
+					hash:     txHash,
+					source:   source,
+					err:      err,
+					bytes:    res.Data,
+					received: res.Received,
 				}
 			)
 
@@ -785,8 +1170,10 @@ func (s *TxFeedsCompareService) clearTrailNewHashes() {
 func (s *TxFeedsCompareService) drainChannels() {
 	done := make(chan struct{})
 	go func() {
-		for len(s.hashes) > 0 {
-			<-s.hashes
+		for _, src := range s.sources {
+			for len(src.hashes) > 0 {
+				<-src.hashes
+			}
 		}
 
 		for len(s.evmTxCh) > 0 {
@@ -0,0 +1,209 @@
+package cmpfeeds
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// verifiedHeaderFields are the header fields compared between the blocks received from the
+// gateway and the node. A field missing from both blocks is not compared.
+var verifiedHeaderFields = []string{
+	"parentHash",
+	"sha3Uncles",
+	"miner",
+	"stateRoot",
+	"transactionsRoot",
+	"receiptsRoot",
+	"logsBloom",
+	"difficulty",
+	"number",
+	"gasLimit",
+	"gasUsed",
+	"timestamp",
+	"extraData",
+	"mixHash",
+	"nonce",
+	"baseFeePerGas",
+	"withdrawalsRoot",
+}
+
+// quantityFields are the header fields encoded as hex numbers, which may be encoded with
+// leading zeros.
+var quantityFields = map[string]bool{
+	"difficulty":    true,
+	"number":        true,
+	"gasLimit":      true,
+	"gasUsed":       true,
+	"timestamp":     true,
+	"baseFeePerGas": true,
+}
+
+// blockContents is the part of a block, or of a call result of the ethOnBlock feed,
+// compared between the feeds. The fields are compared by name.
+type blockContents struct {
+	fields map[string]string
+	txs    []string
+	uncles []string
+}
+
+// rawBlockContents holds the transactions and uncles of a block, either of the gateway feed
+// message or of the node response. The gateway sends the uncles as headers and the node as
+// hashes.
+type rawBlockContents struct {
+	Transactions []struct {
+		Hash string `json:"hash"`
+	} `json:"transactions"`
+	Uncles []json.RawMessage `json:"uncles"`
+}
+
+// parseGatewayContents parses the contents of a block of the gateway feed message.
+func parseGatewayContents(data []byte) (*blockContents, error) {
+	var msg struct {
+		Params struct {
+			Result struct {
+				Header map[string]interface{} `json:"header"`
+				rawBlockContents
+			} `json:"result"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal block contents: %v", err)
+	}
+
+	res := msg.Params.Result
+	return newBlockContents(res.Header, &res.rawBlockContents)
+}
+
+// parseNodeContents parses the contents of a block returned by eth_getBlockByHash, it
+// returns nil if the node does not know the block.
+func parseNodeContents(data []byte) (*blockContents, error) {
+	var msg struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal block contents: %v", err)
+	}
+
+	if len(msg.Result) == 0 || string(msg.Result) == "null" {
+		return nil, nil
+	}
+
+	var (
+		header map[string]interface{}
+		raw    rawBlockContents
+	)
+	if err := json.Unmarshal(msg.Result, &header); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal block header: %v", err)
+	}
+	if err := json.Unmarshal(msg.Result, &raw); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal block contents: %v", err)
+	}
+
+	return newBlockContents(header, &raw)
+}
+
+func newBlockContents(header map[string]interface{}, raw *rawBlockContents) (*blockContents, error) {
+	res := &blockContents{
+		fields: make(map[string]string, len(verifiedHeaderFields)),
+		txs:    make([]string, 0, len(raw.Transactions)),
+		uncles: make([]string, 0, len(raw.Uncles)),
+	}
+
+	for _, field := range verifiedHeaderFields {
+		if value, ok := header[field]; ok && value != nil {
+			res.fields["header."+field] = normalizeField(field, fmt.Sprint(value))
+		}
+	}
+
+	for _, tx := range raw.Transactions {
+		res.txs = append(res.txs, strings.ToLower(tx.Hash))
+	}
+
+	for _, data := range raw.Uncles {
+		var uncle struct {
+			Hash string `json:"hash"`
+		}
+		if err := json.Unmarshal(data, &uncle.Hash); err != nil {
+			if err = json.Unmarshal(data, &uncle); err != nil {
+				return nil, fmt.Errorf("cannot unmarshal uncle: %v", err)
+			}
+		}
+
+		res.uncles = append(res.uncles, strings.ToLower(uncle.Hash))
+	}
+
+	return res, nil
+}
+
+func normalizeField(field, value string) string {
+	value = strings.ToLower(value)
+
+	if quantityFields[field] && strings.HasPrefix(value, "0x") {
+		if n, ok := new(big.Int).SetString(value[2:], 16); ok {
+			return "0x" + n.Text(16)
+		}
+	}
+
+	return value
+}
+
+// mismatches returns the fields of the gateway block which differ from the node block.
+func (c *blockContents) mismatches(node *blockContents) []string {
+	var (
+		res    []string
+		fields = make(map[string]int, len(c.fields))
+	)
+
+	for field := range c.fields {
+		fields[field]++
+	}
+	for field := range node.fields {
+		fields[field]++
+	}
+
+	for _, field := range sortedCounts(fields) {
+		gatewayValue, gatewayOk := c.fields[field]
+		nodeValue, nodeOk := node.fields[field]
+		if gatewayOk != nodeOk || gatewayValue != nodeValue {
+			res = append(res, field)
+		}
+	}
+
+	if !equalStrings(c.txs, node.txs) {
+		res = append(res, "transactions")
+	}
+
+	if !equalStrings(c.uncles, node.uncles) {
+		res = append(res, "uncles")
+	}
+
+	return res
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sortedCounts returns the keys of counts in order.
+func sortedCounts(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
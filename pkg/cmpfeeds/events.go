@@ -0,0 +1,164 @@
+package cmpfeeds
+
+import (
+	"context"
+	"performance/internal/pkg/metrics"
+	"performance/internal/pkg/ws"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// feedQueueSize bounds the number of feed messages waiting for the handler goroutine.
+const feedQueueSize = 8192
+
+// feedQueue merges the messages of all feed readers into a single bounded queue. A reader
+// blocks while the queue is full, the time it spends blocked is recorded as back-pressure.
+type feedQueue struct {
+	ch      chan *message
+	metrics *metrics.Feed
+
+	// delay and recorder are used only from the handler goroutine. The recorder is nil
+	// unless the messages are recorded.
+	delay    queueDelay
+	recorder *recorder
+}
+
+// queueDelay accumulates the time the feed messages waited for the handler goroutine
+// after they were read from the socket.
+type queueDelay struct {
+	count int
+	total time.Duration
+	max   time.Duration
+}
+
+func (d *queueDelay) add(delay time.Duration) {
+	d.count++
+	d.total += delay
+	if delay > d.max {
+		d.max = delay
+	}
+}
+
+func (d *queueDelay) avg() time.Duration {
+	if d.count == 0 {
+		return 0
+	}
+
+	return d.total / time.Duration(d.count)
+}
+
+func newFeedQueue(m *metrics.Feed) *feedQueue {
+	return &feedQueue{
+		ch:      make(chan *message, feedQueueSize),
+		metrics: m,
+	}
+}
+
+// push adds a message of the source to the queue, it returns false if ctx is done before
+// there is room for the message.
+func (q *feedQueue) push(ctx context.Context, msg *message, source string) bool {
+	select {
+	case q.ch <- msg:
+		return true
+	default:
+	}
+
+	blocked := time.Now()
+	defer func() { q.metrics.Blocked(source, time.Since(blocked)) }()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case q.ch <- msg:
+		return true
+	}
+}
+
+// requestContents queues the hash for the contents reader of the source, it returns false if
+// the queue is full. The reader waits for the handler goroutine to take the contents, so the
+// handler cannot wait for the reader; the hash is dropped and counted instead, which keeps
+// the queue bounded and the hashes in order.
+func requestContents(hashes chan<- string, hash string, m *metrics.Feed, source string) bool {
+	select {
+	case hashes <- hash:
+		return true
+	default:
+		m.ContentsDropped(source)
+		return false
+	}
+}
+
+// record writes the message to the recording, if any.
+func (q *feedQueue) record(kind byte, data *message) {
+	if q.recorder != nil {
+		q.recorder.write(kind, data)
+	}
+}
+
+// recordGap writes the start or the end of a gap of the source to the recording, if any.
+func (q *feedQueue) recordGap(source int, gap ws.Gap) {
+	if q.recorder != nil {
+		q.recorder.writeGap(source, gap)
+	}
+}
+
+// handleEvents runs the handlers and processes the contents and feed messages on the
+// calling goroutine until ctx is done. It blocks while there is nothing to do. The handlers
+// and the contents are preferred to the feed messages, because the handlers control the
+// comparison intervals and the contents complete messages which were already dequeued.
+func handleEvents(
+	ctx context.Context,
+	handlers <-chan handler,
+	contents <-chan *message,
+	feeds *feedQueue,
+	processContents func(*message) error,
+	processFeed func(*message) error,
+) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update := <-handlers:
+			runHandler(update)
+			continue
+		case data := <-contents:
+			feeds.record(recordContents, data)
+			process(processContents, data)
+			continue
+		default:
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case update := <-handlers:
+			runHandler(update)
+		case data := <-contents:
+			feeds.record(recordContents, data)
+			process(processContents, data)
+		case data := <-feeds.ch:
+			feeds.record(recordFeed, data)
+
+			if !data.received.IsZero() {
+				delay := time.Since(data.received)
+				feeds.delay.add(delay)
+				feeds.metrics.QueueDelay(delay, len(feeds.ch))
+			}
+
+			process(processFeed, data)
+		}
+	}
+}
+
+func runHandler(update handler) {
+	if err := update(); err != nil {
+		log.Errorf("error in update function: %v", err)
+	}
+}
+
+func process(fn func(*message) error, data *message) {
+	if err := fn(data); err != nil {
+		log.Errorf("error: %v", err)
+	}
+}
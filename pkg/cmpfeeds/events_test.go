@@ -0,0 +1,257 @@
+package cmpfeeds
+
+import (
+	"context"
+	"fmt"
+	"performance/internal/pkg/metrics"
+	"runtime"
+	runtimemetrics "runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHandleEvents(t *testing.T) {
+	var (
+		queue       = newFeedQueue(metrics.NewFeed("test"))
+		handlers    = make(chan handler)
+		ctx, cancel = context.WithCancel(context.Background())
+		processed   []int
+		done        = make(chan struct{})
+	)
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		if !queue.push(ctx, &message{source: i, received: time.Now()}, "test") {
+			t.Fatal("cannot push message")
+		}
+	}
+
+	go func() {
+		defer close(done)
+		handleEvents(ctx, handlers, nil, queue, nil, func(data *message) error {
+			processed = append(processed, data.source)
+			return nil
+		})
+	}()
+
+	delay := waitQueue(handlers, queue)
+	cancel()
+	<-done
+
+	if len(processed) != 3 || processed[0] != 0 || processed[2] != 2 {
+		t.Fatalf("expected messages to be processed in order, got %v", processed)
+	}
+
+	if delay.count != 3 || delay.max <= 0 {
+		t.Errorf("expected queue delay of 3 messages, got %+v", delay)
+	}
+}
+
+// waitQueue waits until the queue is empty and returns the queue delay of the processed messages.
+func waitQueue(handlers chan<- handler, queue *feedQueue) queueDelay {
+	for {
+		res := make(chan *queueDelay, 1)
+		handlers <- func() error {
+			if len(queue.ch) > 0 {
+				res <- nil
+				return nil
+			}
+
+			delay := queue.delay
+			res <- &delay
+			return nil
+		}
+
+		if delay := <-res; delay != nil {
+			return *delay
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// cpuTime returns the CPU time spent by the Go code of the process, if the runtime reports it.
+func cpuTime() (time.Duration, bool) {
+	// The CPU time metrics are updated by the garbage collector.
+	runtime.GC()
+
+	sample := []runtimemetrics.Sample{{Name: "/cpu/classes/user:cpu-seconds"}}
+	runtimemetrics.Read(sample)
+
+	if sample[0].Value.Kind() != runtimemetrics.KindFloat64 {
+		return 0, false
+	}
+
+	return time.Duration(sample[0].Value.Float64() * float64(time.Second)), true
+}
+
+// BenchmarkHandleUpdates feeds the transaction feeds comparison with two sources sending
+// messages as fast as possible and reports the CPU time per message, the time the messages
+// waited for the handler and the CPU use of the idle handler.
+func BenchmarkHandleUpdates(b *testing.B) {
+	benchmarkHandleUpdates(b, true)
+}
+
+// BenchmarkHandleUpdatesContents is like BenchmarkHandleUpdates, but the contents of the
+// transactions of the node are requested from a contents reader which answers immediately.
+// It also reports the share of the hashes dropped because the contents queue was full.
+func BenchmarkHandleUpdatesContents(b *testing.B) {
+	benchmarkHandleUpdates(b, false)
+}
+
+func benchmarkHandleUpdates(b *testing.B, excTxContents bool) {
+	const idleTime = 200 * time.Millisecond
+
+	s := NewTxFeedsCompareService()
+	s.sources = []*feedSource{
+		newFeedSource("gateway", "", sourceTypeBX, ""),
+		newFeedSource("node", "", sourceTypeEvm, ""),
+	}
+	s.gateway, s.evmNode = 0, 1
+	s.excTxContents = excTxContents
+	s.ignoreDelta = time.Second
+	s.timeToEndComparison = time.Now().Add(time.Hour)
+
+	feeds := [][][]byte{make([][]byte, b.N), make([][]byte, b.N)}
+	for i := 0; i < b.N; i++ {
+		feeds[0][i] = []byte(fmt.Sprintf(`{"params":{"result":{"txHash":"%s"}}}`, hash(i)))
+		feeds[1][i] = []byte(fmt.Sprintf(`{"params":{"subscription":"0x1","result":"%s"}}`, hash(i)))
+	}
+
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		handleGroup sync.WaitGroup
+		readerGroup sync.WaitGroup
+		// taken and sent count the hashes taken and the contents sent by the contents reader.
+		taken, sent int64
+	)
+	defer cancel()
+
+	handleGroup.Add(1)
+	go s.handleUpdates(ctx, &handleGroup)
+
+	if !excTxContents {
+		handleGroup.Add(1)
+		go func() {
+			defer handleGroup.Done()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case txHash := <-s.sources[1].hashes:
+					atomic.AddInt64(&taken, 1)
+					msg := &message{
+						hash:     txHash,
+						source:   1,
+						bytes:    []byte(fmt.Sprintf(`{"result":{"hash":"%s","gasPrice":"0x1"}}`, txHash)),
+						received: time.Now(),
+					}
+
+					select {
+					case <-ctx.Done():
+						return
+					case s.evmTxCh <- msg:
+						atomic.AddInt64(&sent, 1)
+					}
+				}
+			}
+		}()
+	}
+
+	start, ok := cpuTime()
+	b.ResetTimer()
+
+	for source, messages := range feeds {
+		readerGroup.Add(1)
+		go func(source int, messages [][]byte) {
+			defer readerGroup.Done()
+
+			for _, data := range messages {
+				msg := &message{source: source, bytes: data, received: time.Now()}
+				if !s.feeds.push(ctx, msg, s.sources[source].name) {
+					return
+				}
+			}
+		}(source, messages)
+	}
+
+	readerGroup.Wait()
+	delay := waitQueue(s.handlers, s.feeds)
+	if !excTxContents {
+		waitContents(s, &taken, &sent)
+	}
+
+	b.StopTimer()
+	end, _ := cpuTime()
+
+	b.ReportMetric(float64(delay.avg().Nanoseconds()), "queue-ns/msg")
+	b.ReportMetric(float64(delay.max.Nanoseconds()), "max-queue-ns")
+
+	if !excTxContents {
+		b.ReportMetric(100*float64(int64(b.N)-atomic.LoadInt64(&taken))/float64(b.N), "dropped-%")
+	}
+
+	if ok {
+		b.ReportMetric(float64((end-start).Nanoseconds())/float64(2*b.N), "cpu-ns/msg")
+
+		time.Sleep(idleTime)
+		idle, _ := cpuTime()
+		b.ReportMetric(100*float64(idle-end)/float64(idleTime), "idle-cpu-%")
+	}
+
+	cancel()
+	handleGroup.Wait()
+}
+
+// waitContents waits until the handler processed the contents of all requested hashes. The
+// check runs on the handler goroutine, so a requested hash is either queued, taken by the
+// reader and not sent yet, or its contents are queued.
+func waitContents(s *TxFeedsCompareService, taken, sent *int64) {
+	for {
+		res := make(chan bool, 1)
+		s.handlers <- func() error {
+			res <- len(s.feeds.ch) == 0 && len(s.sources[1].hashes) == 0 &&
+				len(s.evmTxCh) == 0 && atomic.LoadInt64(taken) == atomic.LoadInt64(sent)
+			return nil
+		}
+
+		if <-res {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRequestContents(t *testing.T) {
+	var (
+		hashes = make(chan string, 2)
+		m      = metrics.NewFeed("test")
+		done   = make(chan []bool)
+	)
+
+	// The requests never wait for the reader, the hashes beyond the capacity are dropped.
+	go func() {
+		var res []bool
+		for i := 0; i < 3; i++ {
+			res = append(res, requestContents(hashes, hash(i), m, "node"))
+		}
+		done <- res
+	}()
+
+	select {
+	case res := <-done:
+		if !res[0] || !res[1] || res[2] {
+			t.Errorf("expected only the third hash to be dropped, got %v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("requestContents blocked on a full queue")
+	}
+
+	for i := 0; i < 2; i++ {
+		if h := <-hashes; h != hash(i) {
+			t.Errorf("expected hash %s at position %d, got %s", hash(i), i, h)
+		}
+	}
+}
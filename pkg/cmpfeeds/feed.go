@@ -0,0 +1,147 @@
+package cmpfeeds
+
+import (
+	"context"
+	"performance/internal/pkg/metrics"
+	"performance/internal/pkg/ws"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// readFeed forwards messages of a resilient subscription to the queue until ctx is done.
+func readFeed(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	queue *feedQueue,
+	sub *ws.ResilientSubscription,
+	source int,
+	sourceName string,
+	feedName string,
+) {
+	defer wg.Done()
+
+	go func() {
+		<-ctx.Done()
+		if err := sub.Close(); err != nil {
+			log.Errorf("cannot close subscription to feed %q: %v", feedName, err)
+		}
+	}()
+
+	for {
+		msg, err := sub.NextMessage(ctx)
+		if err != nil {
+			return
+		}
+
+		if !queue.push(ctx, &message{source: source, bytes: msg.Data, received: msg.Received}, sourceName) {
+			return
+		}
+	}
+}
+
+// gapRecorder returns a callback which records feed outages of the source into gaps and
+// into the recording of the comparison, if any. The gaps are modified only from the handler
+// goroutine.
+func gapRecorder(
+	ctx context.Context,
+	handlers chan<- handler,
+	feeds *feedQueue,
+	source int,
+	gaps *[]ws.Gap,
+) func(ws.Gap) {
+	return func(gap ws.Gap) {
+		select {
+		case <-ctx.Done():
+		case handlers <- func() error {
+			*gaps = addGap(*gaps, gap)
+			feeds.recordGap(source, gap)
+			return nil
+		}:
+		}
+	}
+}
+
+// reconnectOptions records outages of a source into gaps and reports the connection state
+// of the source to the metrics. The notifications of the source dropped because the
+// comparison fell behind are recorded as gaps too, so they are not counted as missing.
+func reconnectOptions(
+	ctx context.Context,
+	handlers chan<- handler,
+	feeds *feedQueue,
+	source int,
+	gaps *[]ws.Gap,
+	m *metrics.Feed,
+	sourceName string,
+) ws.ReconnectOptions {
+	record := gapRecorder(ctx, handlers, feeds, source, gaps)
+
+	return ws.ReconnectOptions{
+		OnGap: func(gap ws.Gap) {
+			switch {
+			case gap.Reason == ws.ErrNotificationsDropped:
+				// The source stayed connected, only its notifications were dropped.
+			case gap.End.IsZero():
+				m.Connected(sourceName, false)
+			default:
+				m.Reconnected(sourceName)
+			}
+			record(gap)
+		},
+		OnConnect: func() { m.Connected(sourceName, true) },
+	}
+}
+
+// addGap appends a new gap or updates the end time of an already recorded one.
+func addGap(gaps []ws.Gap, gap ws.Gap) []ws.Gap {
+	for i := range gaps {
+		if gaps[i].Start.Equal(gap.Start) {
+			gaps[i] = gap
+			return gaps
+		}
+	}
+
+	return append(gaps, gap)
+}
+
+// inGap checks if t falls into any of the gaps.
+func inGap(gaps []ws.Gap, t time.Time) bool {
+	for _, gap := range gaps {
+		if gap.Contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// gapsSummary returns the number of gaps and their total duration which overlap the
+// [from, to) time range.
+func gapsSummary(gaps []ws.Gap, from, to time.Time) (int, time.Duration) {
+	var (
+		count int
+		total time.Duration
+	)
+
+	for _, gap := range gaps {
+		end := gap.End
+		if end.IsZero() || end.After(to) {
+			end = to
+		}
+
+		start := gap.Start
+		if start.Before(from) {
+			start = from
+		}
+
+		if !end.After(start) {
+			continue
+		}
+
+		count++
+		total += end.Sub(start)
+	}
+
+	return count, total
+}
@@ -0,0 +1,92 @@
+package cmpfeeds
+
+import (
+	"fmt"
+	"performance/internal/pkg/stats"
+	"strings"
+	"time"
+)
+
+// latencyStats holds distributions of time differences between items received on both feeds.
+type latencyStats struct {
+	// gatewayFirst and evmNodeFirst hold the lead (ms) of the feed which was first.
+	gatewayFirst *stats.Distribution
+	evmNodeFirst *stats.Distribution
+	// delta holds the signed difference (ms) between gateway and node receive time,
+	// negative values mean the gateway was first.
+	delta *stats.Distribution
+}
+
+// latencySummary is a reportable form of latencyStats.
+type latencySummary struct {
+	GatewayFirst stats.Summary  `json:"gatewayFirst"`
+	EvmNodeFirst stats.Summary  `json:"evmNodeFirst"`
+	Delta        stats.Summary  `json:"delta"`
+	Histogram    []stats.Bucket `json:"histogram"`
+}
+
+func newLatencyStats() *latencyStats {
+	return &latencyStats{
+		gatewayFirst: stats.NewDistribution(),
+		evmNodeFirst: stats.NewDistribution(),
+		delta:        stats.NewDistribution(),
+	}
+}
+
+func (l *latencyStats) add(diff time.Duration) {
+	ms := float64(diff.Microseconds()) / 1000
+
+	l.delta.Add(ms)
+
+	switch {
+	case diff < 0:
+		l.gatewayFirst.Add(-ms)
+	case diff > 0:
+		l.evmNodeFirst.Add(ms)
+	}
+}
+
+func (l *latencyStats) merge(other *latencyStats) {
+	l.gatewayFirst.Merge(other.gatewayFirst)
+	l.evmNodeFirst.Merge(other.evmNodeFirst)
+	l.delta.Merge(other.delta)
+}
+
+func (l *latencyStats) summary(bounds []float64) latencySummary {
+	res := latencySummary{
+		GatewayFirst: l.gatewayFirst.Summary(),
+		EvmNodeFirst: l.evmNodeFirst.Summary(),
+		Delta:        l.delta.Summary(),
+	}
+
+	if len(bounds) > 0 {
+		res.Histogram = l.delta.Histogram(bounds)
+	}
+
+	return res
+}
+
+func (l latencySummary) String() string {
+	var b strings.Builder
+
+	b.WriteString("\nLatency distribution (ms):\n")
+	writePercentiles(&b, "received first from gateway", l.GatewayFirst)
+	writePercentiles(&b, "received first from Evm node", l.EvmNodeFirst)
+	fmt.Fprintf(&b, "Time difference gateway - Evm node: mean %.1f, standard deviation %.1f\n",
+		l.Delta.Mean, l.Delta.StdDev)
+
+	if len(l.Histogram) > 0 {
+		b.WriteString("Time difference histogram (ms):\n")
+		for _, bucket := range l.Histogram {
+			fmt.Fprintf(&b, "  %-16s %d\n", bucket, bucket.Count)
+		}
+	}
+
+	return b.String()
+}
+
+func writePercentiles(b *strings.Builder, name string, s stats.Summary) {
+	fmt.Fprintf(b, "%s: p50 %.1f, p90 %.1f, p95 %.1f, p99 %.1f, max %.1f, "+
+		"standard deviation %.1f\n",
+		name, s.P50, s.P90, s.P95, s.P99, s.Max, s.StdDev)
+}
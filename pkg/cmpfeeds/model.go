@@ -1,19 +1,116 @@
 package cmpfeeds
 
-import "time"
+import (
+	"performance/internal/pkg/txfilter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
 
 type handler func() error
 
+// message is a message of a source. The received time is taken when the message was read
+// from the socket.
 type message struct {
-	hash  string
-	bytes []byte
-	err   error
+	hash     string
+	source   int
+	bytes    []byte
+	err      error
+	received time.Time
 }
 
 type hashEntry struct {
 	evmTimeReceived time.Time
 	bxrTimeReceived time.Time
 	hash            string
+	number          uint64
+
+	// contents are kept by source only if the contents are verified.
+	contents [2]*blockContents
+}
+
+// timeReceived returns the time the block was received from the source of the block feeds
+// comparison.
+func (e *hashEntry) timeReceived(source int) time.Time {
+	if source == gatewayIndex {
+		return e.bxrTimeReceived
+	}
+
+	return e.evmTimeReceived
+}
+
+func (e *hashEntry) setTimeReceived(source int, t time.Time) {
+	if source == gatewayIndex {
+		e.bxrTimeReceived = t
+	} else {
+		e.evmTimeReceived = t
+	}
+}
+
+// txEntry holds the times a transaction was received from each of the sources.
+type txEntry struct {
+	hash         string
+	timeReceived []time.Time
+}
+
+// txStats holds the results of the transaction feeds comparison.
+type txStats struct {
+	TxSeenByBothFeeds             int            `json:"txSeenByBothFeeds"`
+	TxSeenByBothFeedsGatewayFirst int            `json:"txSeenByBothFeedsGatewayFirst"`
+	TxSeenByBothFeedsEvmNodeFirst int            `json:"txSeenByBothFeedsEvmNodeFirst"`
+	PercentageSeenByGatewayFirst  int            `json:"percentageSeenByGatewayFirst"`
+	AvgDeltaGatewayFirstMs        int            `json:"avgDeltaGatewayFirstMs"`
+	AvgDeltaEvmNodeFirstMs        int            `json:"avgDeltaEvmNodeFirstMs"`
+	TotalTxFromGateway            int            `json:"totalTxFromGateway"`
+	TotalTxFromEvmNode            int            `json:"totalTxFromEvmNode"`
+	LowFeeTxIgnored               int            `json:"lowFeeTxIgnored"`
+	HighDeltaTxIgnored            int            `json:"highDeltaTxIgnored"`
+	TxIgnoredDueToOutages         int            `json:"txIgnoredDueToOutages"`
+	ContentsDropped               int            `json:"contentsDropped"`
+	NewTxFromGatewayFirst         int            `json:"newTxFromGatewayFirst"`
+	NewTxFromEvmNodeFirst         int            `json:"newTxFromEvmNodeFirst"`
+	GatewayOutages                int            `json:"gatewayOutages"`
+	GatewayDowntimeMs             int64          `json:"gatewayDowntimeMs"`
+	EvmNodeOutages                int            `json:"evmNodeOutages"`
+	EvmNodeDowntimeMs             int64          `json:"evmNodeDowntimeMs"`
+	Latency                       latencySummary `json:"latency"`
+	Sources                       *sourceStats   `json:"sources,omitempty"`
+
+	// pair is set if the gateway and EVM node counters above were collected.
+	pair bool
+}
+
+// bkStats holds the results of the block feeds comparison.
+type bkStats struct {
+	NewBkFromGatewayFirst         int            `json:"newBkFromGatewayFirst"`
+	NewBkFromEvmNodeFirst         int            `json:"newBkFromEvmNodeFirst"`
+	TotalBkFromGateway            int            `json:"totalBkFromGateway"`
+	TotalBkFromEvmNode            int            `json:"totalBkFromEvmNode"`
+	BkSeenByBothFeeds             int            `json:"bkSeenByBothFeeds"`
+	BkSeenByBothFeedsGatewayFirst int            `json:"bkSeenByBothFeedsGatewayFirst"`
+	BkSeenByBothFeedsEvmNodeFirst int            `json:"bkSeenByBothFeedsEvmNodeFirst"`
+	PercentageSeenByGatewayFirst  int            `json:"percentageSeenByGatewayFirst"`
+	AvgDeltaGatewayFirstMs        int            `json:"avgDeltaGatewayFirstMs"`
+	AvgDeltaEvmNodeFirstMs        int            `json:"avgDeltaEvmNodeFirstMs"`
+	BkIgnoredDueToOutages         int            `json:"bkIgnoredDueToOutages"`
+	ContentsDropped               int            `json:"contentsDropped"`
+	GatewayOutages                int            `json:"gatewayOutages"`
+	GatewayDowntimeMs             int64          `json:"gatewayDowntimeMs"`
+	EvmNodeOutages                int            `json:"evmNodeOutages"`
+	EvmNodeDowntimeMs             int64          `json:"evmNodeDowntimeMs"`
+	CanonicalBkFromGateway        int            `json:"canonicalBkFromGateway"`
+	OrphanedBkFromGateway         int            `json:"orphanedBkFromGateway"`
+	CanonicalBkFromEvmNode        int            `json:"canonicalBkFromEvmNode"`
+	OrphanedBkFromEvmNode         int            `json:"orphanedBkFromEvmNode"`
+	SiblingBlocks                 int            `json:"siblingBlocks"`
+	GatewayReorgs                 int            `json:"gatewayReorgs"`
+	GatewayMaxReorgDepth          int            `json:"gatewayMaxReorgDepth"`
+	EvmNodeReorgs                 int            `json:"evmNodeReorgs"`
+	EvmNodeMaxReorgDepth          int            `json:"evmNodeMaxReorgDepth"`
+	BkContentsVerified            int            `json:"bkContentsVerified"`
+	BkContentsMismatched          int            `json:"bkContentsMismatched"`
+	ContentMismatches             map[string]int `json:"contentMismatches,omitempty"`
+	Latency                       latencySummary `json:"latency"`
 }
 
 type evmTxFeedResponse struct {
@@ -25,21 +122,17 @@ type evmTxFeedResponse struct {
 
 type evmBkFeedResponse struct {
 	Params struct {
-		Subscription string `json:"subscription"`
-		Result       struct {
-			Hash string `json:"hash"`
-		} `json:"result"`
+		Subscription string      `json:"subscription"`
+		Result       blockHeader `json:"result"`
 	} `json:"params"`
 }
 
 type bxTxFeedResponse struct {
 	Params struct {
 		Result struct {
-			TxHash     string `json:"txHash"`
-			TxContents struct {
-				GasPrice *string `json:"gasPrice"`
-				To       *string `json:"to"`
-			} `json:"txContents"`
+			TxHash     string      `json:"txHash"`
+			Status     string      `json:"status"`
+			TxContents txfilter.Tx `json:"txContents"`
 		} `json:"result"`
 	} `json:"params"`
 }
@@ -47,20 +140,35 @@ type bxTxFeedResponse struct {
 type bxBkFeedResponse struct {
 	Params struct {
 		Result struct {
-			Hash string `json:"hash"`
+			Hash   string      `json:"hash"`
+			Header blockHeader `json:"header"`
 		} `json:"result"`
 	} `json:"params"`
 }
 
 type evmTxContentsResponse struct {
-	Result *struct {
-		GasPrice string `json:"gasPrice"`
-		To       string `json:"to"`
-	} `json:"result"`
+	Result *txfilter.Tx `json:"result"`
 }
 
 type evmBkContentsResponse struct {
-	Result *struct {
-		Hash string `json:"hash"`
+	Result *blockHeader `json:"result"`
+}
+
+type evmReceiptsResponse struct {
+	Result []struct {
+		BlockHash   string         `json:"blockHash"`
+		BlockNumber hexutil.Uint64 `json:"blockNumber"`
 	} `json:"result"`
 }
+
+type bxReceiptFeedResponse struct {
+	Params struct {
+		Result struct {
+			Receipt struct {
+				BlockHash       string         `json:"blockHash"`
+				BlockNumber     hexutil.Uint64 `json:"blockNumber"`
+				TransactionHash string         `json:"transactionHash"`
+			} `json:"receipt"`
+		} `json:"result"`
+	} `json:"params"`
+}
@@ -0,0 +1,332 @@
+package cmpfeeds
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"performance/internal/pkg/ws"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxRecordSize limits the size of a recorded message, so a corrupted recording does not
+// exhaust the memory.
+const maxRecordSize = 64 << 20
+
+// maxRecordingSuffix limits the number of recordings of a feed started in the same second.
+const maxRecordingSuffix = 100
+
+// Feeds of the comparisons, used in the names of the recordings and the metrics.
+const (
+	txFeed = "transactions"
+	bkFeed = "blocks"
+)
+
+// Kinds of the records. The gaps of the sources are recorded when they start and end.
+const (
+	recordFeed byte = iota
+	recordContents
+	recordGapStart
+	recordGapEnd
+)
+
+// recordingHeader describes the comparison a recording was made by. It is written as the
+// first line of the recording, followed by the binary records of the messages. The records
+// keep the receive times as offsets from Start, so the recorded time differences do not
+// depend on changes of the wall clock during the recording.
+type recordingHeader struct {
+	Feed            string           `json:"feed"`
+	FeedName        string           `json:"feedName"`
+	Start           time.Time        `json:"start"`
+	ExcludeContents bool             `json:"excludeContents"`
+	Filters         string           `json:"filters,omitempty"`
+	Sources         []recordedSource `json:"sources"`
+}
+
+type recordedSource struct {
+	Name string `json:"name"`
+	URI  string `json:"uri"`
+	Type string `json:"type"`
+}
+
+// record is a message or a gap read from a recording. The message of a gap record holds
+// only the source and the time the gap started or ended.
+type record struct {
+	kind byte
+	msg  *message
+	gap  *ws.Gap
+}
+
+// recorder appends the messages of a comparison to a recording. It is used only from the
+// handler goroutine.
+type recorder struct {
+	path  string
+	start time.Time
+	file  *os.File
+	w     *bufio.Writer
+	buf   []byte
+}
+
+// newRecorder creates a new recording of the comparison in dir.
+func newRecorder(dir string, header *recordingHeader) (*recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create directory %q: %v", dir, err)
+	}
+
+	// Comparisons started in the same second, e.g. of both feeds or after a restart, get
+	// numbered names, so a recording is never appended to another one.
+	var (
+		base = fmt.Sprintf("%s-%s", header.Feed, header.Start.UTC().Format("20060102T150405"))
+		path string
+		file *os.File
+		err  error
+	)
+	for i := 0; ; i++ {
+		name := base + ".rec"
+		if i > 0 {
+			name = fmt.Sprintf("%s-%d.rec", base, i)
+		}
+
+		path = filepath.Join(dir, name)
+		file, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if !os.IsExist(err) || i == maxRecordingSuffix {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot create file %q: %v", path, err)
+	}
+
+	r := &recorder{path: path, start: header.Start, file: file, w: bufio.NewWriter(file)}
+
+	data, err := json.Marshal(header)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	if _, err = r.w.Write(append(data, '\n')); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("cannot write header of file %q: %v", path, err)
+	}
+
+	log.Infof("Recording %s feed to %s", header.Feed, path)
+
+	return r, nil
+}
+
+// write appends a message to the recording. Messages which failed to be read are skipped.
+func (r *recorder) write(kind byte, msg *message) {
+	if msg.err != nil {
+		return
+	}
+
+	buf := append(r.buf[:0], kind)
+	buf = appendUvarint(buf, uint64(msg.source))
+	buf = appendVarint(buf, int64(msg.received.Sub(r.start)))
+	buf = appendUvarint(buf, uint64(len(msg.hash)))
+	buf = append(buf, msg.hash...)
+	buf = appendUvarint(buf, uint64(len(msg.bytes)))
+	buf = append(buf, msg.bytes...)
+	r.buf = buf
+
+	if _, err := r.w.Write(buf); err != nil {
+		log.Errorf("cannot write message to file %q: %v", r.path, err)
+	}
+}
+
+// writeGap appends the start of a gap of the source, or its end if the gap ended, to the
+// recording.
+func (r *recorder) writeGap(source int, gap ws.Gap) {
+	kind := recordGapStart
+	if !gap.End.IsZero() {
+		kind = recordGapEnd
+	}
+
+	buf := append(r.buf[:0], kind)
+	buf = appendUvarint(buf, uint64(source))
+	buf = appendVarint(buf, int64(gap.Start.Sub(r.start)))
+	if kind == recordGapEnd {
+		buf = appendVarint(buf, int64(gap.End.Sub(r.start)))
+	}
+	r.buf = buf
+
+	if _, err := r.w.Write(buf); err != nil {
+		log.Errorf("cannot write gap to file %q: %v", r.path, err)
+	}
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+func appendVarint(buf []byte, v int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutVarint(tmp[:], v)]...)
+}
+
+// Close flushes the recording and closes the file.
+func (r *recorder) Close() error {
+	if err := r.w.Flush(); err != nil {
+		_ = r.file.Close()
+		return fmt.Errorf("cannot flush file %q: %v", r.path, err)
+	}
+
+	return r.file.Close()
+}
+
+// recordingReader reads the records of a recording.
+type recordingReader struct {
+	header recordingHeader
+	file   *os.File
+	r      *bufio.Reader
+}
+
+// openRecording opens a recording and reads its header.
+func openRecording(path string) (*recordingReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open file %q: %v", path, err)
+	}
+
+	rr := &recordingReader{file: file, r: bufio.NewReader(file)}
+
+	line, err := rr.r.ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &rr.header)
+	}
+
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("cannot read header of file %q: %v", path, err)
+	}
+
+	return rr, nil
+}
+
+// next returns the next record, io.EOF is returned at the end of the recording.
+func (rr *recordingReader) next() (*record, error) {
+	kind, err := rr.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := binary.ReadUvarint(rr.r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	if int(source) >= len(rr.header.Sources) {
+		return nil, fmt.Errorf("unknown source %d", source)
+	}
+
+	switch kind {
+	case recordFeed, recordContents:
+		return rr.nextMessage(kind, int(source))
+	case recordGapStart, recordGapEnd:
+		return rr.nextGap(kind, int(source))
+	}
+
+	return nil, fmt.Errorf("unknown record kind %d", kind)
+}
+
+func (rr *recordingReader) nextMessage(kind byte, source int) (*record, error) {
+	received, err := rr.readTime()
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := rr.readBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := rr.readBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return &record{
+		kind: kind,
+		msg: &message{
+			hash:     string(hash),
+			source:   source,
+			bytes:    data,
+			received: received,
+		},
+	}, nil
+}
+
+func (rr *recordingReader) nextGap(kind byte, source int) (*record, error) {
+	var (
+		gap ws.Gap
+		err error
+	)
+
+	if gap.Start, err = rr.readTime(); err != nil {
+		return nil, err
+	}
+
+	at := gap.Start
+	if kind == recordGapEnd {
+		if gap.End, err = rr.readTime(); err != nil {
+			return nil, err
+		}
+		at = gap.End
+	}
+
+	return &record{
+		kind: kind,
+		msg:  &message{source: source, received: at},
+		gap:  &gap,
+	}, nil
+}
+
+// readTime reads a time recorded as an offset from the start of the recording.
+func (rr *recordingReader) readTime() (time.Time, error) {
+	offset, err := binary.ReadVarint(rr.r)
+	if err != nil {
+		return time.Time{}, unexpectedEOF(err)
+	}
+
+	return rr.header.Start.Add(time.Duration(offset)), nil
+}
+
+func (rr *recordingReader) readBytes() ([]byte, error) {
+	size, err := binary.ReadUvarint(rr.r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	if size > maxRecordSize {
+		return nil, fmt.Errorf("record of %d bytes exceeds the limit", size)
+	}
+
+	data := make([]byte, size)
+	if _, err = io.ReadFull(rr.r, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	return data, nil
+}
+
+// Close closes the file of the recording.
+func (rr *recordingReader) Close() error {
+	return rr.file.Close()
+}
+
+// unexpectedEOF reports a record truncated by the end of the recording.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
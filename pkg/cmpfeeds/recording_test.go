@@ -0,0 +1,97 @@
+package cmpfeeds
+
+import (
+	"performance/internal/pkg/ws"
+	"testing"
+	"time"
+)
+
+func TestNewRecorderUniqueNames(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		header = &recordingHeader{
+			Feed:    txFeed,
+			Start:   time.Now(),
+			Sources: []recordedSource{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+		}
+		paths = make(map[string]struct{})
+	)
+
+	// Recordings of the same feed started in the same second do not share a file.
+	for i := 0; i < 3; i++ {
+		r, err := newRecorder(dir, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r.write(recordFeed, &message{source: i, hash: hash(i), received: header.Start})
+		if err = r.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		paths[r.path] = struct{}{}
+	}
+
+	if len(paths) != 3 {
+		t.Fatalf("expected 3 recordings, got %v", paths)
+	}
+
+	for path := range paths {
+		rr, err := openRecording(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = rr.next(); err != nil {
+			t.Errorf("cannot read record of %s: %v", path, err)
+		}
+
+		if rec, err := rr.next(); err == nil {
+			t.Errorf("expected a single record in %s, got %+v", path, rec)
+		}
+		_ = rr.file.Close()
+	}
+}
+
+func TestRecordingReceiveOffsets(t *testing.T) {
+	var (
+		header = &recordingHeader{Feed: txFeed, Start: time.Now(), Sources: []recordedSource{{Name: "a"}}}
+		delay  = 1500 * time.Millisecond
+	)
+
+	r, err := newRecorder(t.TempDir(), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The times are recorded relative to the start of the recording.
+	r.write(recordFeed, &message{hash: hash(1), received: header.Start.Add(delay)})
+	r.writeGap(0, ws.Gap{Start: header.Start.Add(delay), End: header.Start.Add(2 * delay)})
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rr, err := openRecording(r.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rr.Close() }()
+
+	rec, err := rr.next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := rec.msg.received.Sub(rr.header.Start); d != delay {
+		t.Errorf("expected the message %s after the start, got %s", delay, d)
+	}
+
+	if rec, err = rr.next(); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.kind != recordGapEnd || rec.gap.Start.Sub(rr.header.Start) != delay ||
+		rec.gap.End.Sub(rr.header.Start) != 2*delay || !rec.msg.received.Equal(rec.gap.End) {
+		t.Errorf("expected a gap from %s to %s after the start, got %+v", delay, 2*delay, rec.gap)
+	}
+}
@@ -0,0 +1,273 @@
+package cmpfeeds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"performance/internal/pkg/flags"
+	"performance/internal/pkg/ws"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// clock is the source of time of a comparison.
+type clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time        { return time.Now() }
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// replayClock is the clock of a replayed comparison. Its time is advanced by the replayer
+// to the receive time of the replayed messages, so the intervals of the comparison end at
+// the same messages regardless of the replay speed. It supports a single sleeping goroutine.
+type replayClock struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	now      time.Time
+	deadline time.Time
+	sleeping bool
+	stopped  bool
+}
+
+func newReplayClock(start time.Time) *replayClock {
+	c := &replayClock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+
+	return c
+}
+
+func (c *replayClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Sleep blocks until the replayer advances the time past d.
+func (c *replayClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deadline = c.now.Add(d)
+	c.sleeping = true
+	c.cond.Broadcast()
+
+	for c.sleeping && !c.stopped {
+		c.cond.Wait()
+	}
+}
+
+// advance moves the time forward to t unless the clock was stopped. If the sleeping
+// goroutine is due before t, drain is called, the goroutine is woken at its deadline and
+// advance waits until it sleeps again, so the messages before the deadline are processed
+// before and the messages after the deadline are processed after the work of the goroutine.
+func (c *replayClock) advance(t time.Time, drain func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for {
+		for !c.sleeping && !c.stopped {
+			c.cond.Wait()
+		}
+
+		// The last interval may still be reported after the comparison stopped.
+		if c.stopped {
+			return
+		}
+
+		if c.deadline.After(t) {
+			break
+		}
+
+		c.mu.Unlock()
+		drain()
+		c.mu.Lock()
+
+		c.now = c.deadline
+		c.sleeping = false
+		c.cond.Broadcast()
+	}
+
+	if t.After(c.now) {
+		c.now = t
+	}
+}
+
+// stop wakes the sleeping goroutine and stops advance from waiting for it.
+func (c *replayClock) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stopped = true
+	c.cond.Broadcast()
+}
+
+// replayer feeds the messages of a recording to a comparison.
+type replayer struct {
+	recording *recordingReader
+	clock     *replayClock
+	speed     float64
+}
+
+// replayEnd is the time the clock is advanced to after the last message, so that all intervals
+// of the comparison complete.
+var replayEnd = time.Unix(1<<62, 0)
+
+// run pushes the recorded messages to the queues of the comparison and adds the recorded
+// gaps to the gaps of the sources until the recording or ctx is done. The records are
+// delayed to match the original timing scaled by the speed, a zero speed replays them as
+// fast as possible.
+func (r *replayer) run(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	feeds *feedQueue,
+	contents chan<- *message,
+	handlers chan<- handler,
+	gaps func(source int) *[]ws.Gap,
+) {
+	defer wg.Done()
+
+	go func() {
+		<-ctx.Done()
+		r.clock.stop()
+	}()
+
+	var (
+		drain   = func() { waitIdle(ctx, handlers, feeds, contents) }
+		started = time.Now()
+		first   time.Time
+	)
+
+	for {
+		rec, err := r.recording.next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Errorf("cannot read recording: %v", err)
+			}
+
+			r.clock.advance(replayEnd, drain)
+			return
+		}
+
+		if first.IsZero() {
+			first = rec.msg.received
+		}
+
+		if r.speed > 0 {
+			wait := time.Duration(float64(rec.msg.received.Sub(first))/r.speed) - time.Since(started)
+			if wait > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+			}
+		}
+
+		r.clock.advance(rec.msg.received, drain)
+
+		switch rec.kind {
+		case recordGapStart, recordGapEnd:
+			// The gaps are added by the handler goroutine, the same way as the live ones.
+			gapRecorder(ctx, handlers, feeds, rec.msg.source, gaps(rec.msg.source))(*rec.gap)
+		case recordContents:
+			select {
+			case <-ctx.Done():
+				return
+			case contents <- rec.msg:
+			}
+		default:
+			if !feeds.push(ctx, rec.msg, r.recording.header.Sources[rec.msg.source].Name) {
+				return
+			}
+		}
+	}
+}
+
+// waitIdle waits until the handler goroutine processed all queued messages.
+func waitIdle(ctx context.Context, handlers chan<- handler, feeds *feedQueue, contents chan<- *message) {
+	for {
+		idle := make(chan bool, 1)
+
+		select {
+		case <-ctx.Done():
+			return
+		case handlers <- func() error {
+			idle <- len(feeds.ch) == 0 && len(contents) == 0
+			return nil
+		}:
+		}
+
+		if <-idle {
+			return
+		}
+	}
+}
+
+// discardHashes consumes the hashes the comparison requests the contents of, the contents
+// are replayed from the recording instead.
+func discardHashes(ctx context.Context, wg *sync.WaitGroup, hashes <-chan string) {
+	defer wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hashes:
+		}
+	}
+}
+
+// ReplayService represents a service which replays a recording of the transactions or
+// blocks feeds comparison.
+type ReplayService struct{}
+
+// NewReplayService creates and initializes ReplayService instance.
+func NewReplayService() *ReplayService {
+	return &ReplayService{}
+}
+
+// Run is an entry point to the ReplayService.
+func (s *ReplayService) Run(c *cli.Context) error {
+	path := c.String(flags.Recording.Name)
+
+	recording, err := openRecording(path)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := recording.Close(); err != nil {
+			log.Errorf("cannot close file %q: %v", path, err)
+		}
+	}()
+
+	r := &replayer{
+		recording: recording,
+		clock:     newReplayClock(recording.header.Start),
+		speed:     c.Float64(flags.ReplaySpeed.Name),
+	}
+
+	log.Infof("Replaying %s feed recorded at %s", recording.header.Feed, recording.header.Start)
+
+	switch recording.header.Feed {
+	case txFeed:
+		svc := NewTxFeedsCompareService()
+		svc.clock, svc.replay = r.clock, r
+		return svc.Run(c)
+	case bkFeed:
+		svc := NewBkFeedsCompareService()
+		svc.clock, svc.replay = r.clock, r
+		return svc.Run(c)
+	}
+
+	return fmt.Errorf("unknown feed %q of recording %q", recording.header.Feed, path)
+}
@@ -0,0 +1,197 @@
+package cmpfeeds
+
+import (
+	"fmt"
+	"performance/internal/pkg/utils"
+	"performance/internal/pkg/ws"
+	"sort"
+	"strings"
+)
+
+// Types of feed sources.
+const (
+	sourceTypeEvm = "evm"
+	sourceTypeBX  = "bx"
+)
+
+// feedSource is a feed endpoint taking part in the comparison.
+type feedSource struct {
+	name       string
+	uri        string
+	typ        string
+	authHeader string
+
+	// hashes queues transaction hashes whose contents should be fetched from an EVM source.
+	hashes chan string
+	// dropped is the number of hashes dropped because the hashes queue was full, and
+	// droppedHashes are the hashes dropped during the current interval. The source never
+	// receives them, so they are excluded from the comparison.
+	dropped       int
+	droppedHashes utils.HashSet
+	gaps          []ws.Gap
+}
+
+func newFeedSource(name, uri, typ, authHeader string) *feedSource {
+	const bufSize = 8192
+	return &feedSource{
+		name:          name,
+		uri:           uri,
+		typ:           typ,
+		authHeader:    authHeader,
+		hashes:        make(chan string, bufSize),
+		droppedHashes: utils.NewHashSet(),
+	}
+}
+
+// parseSources parses values of the repeatable source flag. Each source has a form of
+// name=uri,type=evm|bx[,auth=header]. The cli library splits flag values on commas, so the
+// options are received as separate items and are applied to the preceding source.
+func parseSources(values []string, authHeader string) ([]*feedSource, error) {
+	var (
+		sources []*feedSource
+		names   = make(map[string]struct{})
+	)
+
+	for _, value := range values {
+		kv := strings.SplitN(strings.TrimSpace(value), "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid source %q, expected name=uri,type=evm|bx", value)
+		}
+
+		key, val := kv[0], kv[1]
+		switch key {
+		case "type", "auth":
+			if len(sources) == 0 {
+				return nil, fmt.Errorf("option %q must follow a source name=uri", value)
+			}
+
+			src := sources[len(sources)-1]
+			if key == "auth" {
+				src.authHeader = val
+				continue
+			}
+
+			if val != sourceTypeEvm && val != sourceTypeBX {
+				return nil, fmt.Errorf("invalid type %q of source %q, possible values: %q, %q",
+					val, src.name, sourceTypeEvm, sourceTypeBX)
+			}
+			src.typ = val
+		default:
+			if _, ok := names[key]; ok {
+				return nil, fmt.Errorf("duplicate source name %q", key)
+			}
+
+			names[key] = struct{}{}
+			sources = append(sources, newFeedSource(key, val, "", authHeader))
+		}
+	}
+
+	for _, src := range sources {
+		if src.typ == "" {
+			return nil, fmt.Errorf("type of source %q is not specified", src.name)
+		}
+	}
+
+	if len(sources) < 2 {
+		return nil, fmt.Errorf("at least two sources are required for comparison")
+	}
+
+	return sources, nil
+}
+
+// sourceStats holds the results of the comparison between every pair of sources.
+type sourceStats struct {
+	Sources []string `json:"sources"`
+	// Seen is the number of transactions received from each source.
+	Seen []int `json:"seen"`
+	// FirstSeen is the number of transactions each source was the first to deliver among
+	// the transactions received from at least two sources.
+	FirstSeen []int `json:"firstSeen"`
+	// Compared[i][j] is the number of transactions received from both i and j sources,
+	// and Wins[i][j] is how many of them were received from i first.
+	Compared [][]int `json:"compared"`
+	Wins     [][]int `json:"wins"`
+}
+
+func newSourceStats(names []string) *sourceStats {
+	res := &sourceStats{
+		Sources:   names,
+		Seen:      make([]int, len(names)),
+		FirstSeen: make([]int, len(names)),
+		Compared:  make([][]int, len(names)),
+		Wins:      make([][]int, len(names)),
+	}
+
+	for i := range names {
+		res.Compared[i] = make([]int, len(names))
+		res.Wins[i] = make([]int, len(names))
+	}
+
+	return res
+}
+
+// add accumulates the results of an interval into the run totals.
+func (r *sourceStats) add(other *sourceStats) {
+	for i := range r.Sources {
+		r.Seen[i] += other.Seen[i]
+		r.FirstSeen[i] += other.FirstSeen[i]
+		for j := range r.Sources {
+			r.Compared[i][j] += other.Compared[i][j]
+			r.Wins[i][j] += other.Wins[i][j]
+		}
+	}
+}
+
+func (r *sourceStats) winRate(i, j int) float64 {
+	if r.Compared[i][j] == 0 {
+		return 0
+	}
+
+	return float64(r.Wins[i][j]) / float64(r.Compared[i][j]) * 100
+}
+
+func (r *sourceStats) String() string {
+	var (
+		b     strings.Builder
+		order = make([]int, len(r.Sources))
+		width = len("100.0")
+	)
+
+	for i, name := range r.Sources {
+		order[i] = i
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		return r.FirstSeen[order[a]] > r.FirstSeen[order[b]]
+	})
+
+	b.WriteString("\nFirst seen by leaderboard:\n")
+	for place, i := range order {
+		fmt.Fprintf(&b, "%d. %-*s first: %d, total: %d\n",
+			place+1, width, r.Sources[i], r.FirstSeen[i], r.Seen[i])
+	}
+
+	b.WriteString("\nWin rate matrix (% of transactions seen by both, row source first):\n")
+	fmt.Fprintf(&b, "%-*s", width, "")
+	for _, name := range r.Sources {
+		fmt.Fprintf(&b, " %*s", width, name)
+	}
+	b.WriteString("\n")
+
+	for i, name := range r.Sources {
+		fmt.Fprintf(&b, "%-*s", width, name)
+		for j := range r.Sources {
+			if i == j {
+				fmt.Fprintf(&b, " %*s", width, "-")
+				continue
+			}
+			fmt.Fprintf(&b, " %*.1f", width, r.winRate(i, j))
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,37 @@
+package cmpfeeds
+
+import "testing"
+
+func TestParseSources(t *testing.T) {
+	sources, err := parseSources([]string{
+		"gw=wss://gateway/ws", "type=bx",
+		"node=ws://node:8546", "type=evm", "auth=secret",
+	}, "default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(sources) != 2 {
+		t.Fatalf("expected 2 sources, got %d", len(sources))
+	}
+
+	if gw := sources[0]; gw.name != "gw" || gw.uri != "wss://gateway/ws" || gw.typ != sourceTypeBX || gw.authHeader != "default" {
+		t.Fatalf("unexpected gateway source %+v", gw)
+	}
+
+	if node := sources[1]; node.name != "node" || node.uri != "ws://node:8546" || node.typ != sourceTypeEvm || node.authHeader != "secret" {
+		t.Fatalf("unexpected node source %+v", node)
+	}
+
+	for _, values := range [][]string{
+		{"gw=wss://gateway/ws", "type=bx"},
+		{"type=bx", "gw=wss://gateway/ws"},
+		{"gw=wss://gateway/ws", "type=bx", "node=ws://node:8546"},
+		{"gw=wss://gateway/ws", "type=bx", "gw=ws://node:8546", "type=evm"},
+		{"gw=wss://gateway/ws", "type=grpc", "node=ws://node:8546", "type=evm"},
+	} {
+		if _, err := parseSources(values, ""); err == nil {
+			t.Errorf("expected error for %q", values)
+		}
+	}
+}
@@ -1,25 +0,0 @@
-package cmpnodestxspeedhttp
-
-type nodeTxCountResponse struct {
-	Result *string `json:"result"`
-	Error  *struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	} `json:"error"`
-}
-
-type nodeBalanceResponse struct {
-	Result *string `json:"result"`
-	Error  *struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	} `json:"error"`
-}
-
-type nodeReceiptResponse struct {
-	Result interface{} `json:"result"`
-	Error  *struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	} `json:"error"`
-}
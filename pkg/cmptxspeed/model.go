@@ -1,25 +1,28 @@
 package cmptxspeed
 
-type nodeTxCountResponse struct {
-	Result *string `json:"result"`
-	Error  *struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	} `json:"error"`
-}
+import (
+	"performance/internal/pkg/inclusion"
+	"time"
+)
+
+// privateGroupResult holds the outcome of a group of transactions raced across the
+// submission paths.
+type privateGroupResult struct {
+	Nonce uint64 `json:"nonce"`
+	// Transactions and Responses are keyed by the submission path.
+	Transactions map[string]string    `json:"transactions"`
+	Responses    map[string]string    `json:"responses"`
+	LandedBy     string               `json:"landedBy,omitempty"`
+	Inclusion    *inclusion.Inclusion `json:"inclusion,omitempty"`
 
-type nodeBalanceResponse struct {
-	Result *string `json:"result"`
-	Error  *struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	} `json:"error"`
+	start time.Time
+	end   time.Time
 }
 
-type nodeReceiptResponse struct {
-	Result interface{} `json:"result"`
-	Error  *struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	} `json:"error"`
+// privateSummary holds the outcome of all groups raced across the submission paths.
+type privateSummary struct {
+	Groups          int                         `json:"groups"`
+	Landed          int                         `json:"landed"`
+	LandedByPath    map[string]int              `json:"landedByPath"`
+	InclusionByPath map[string]*inclusion.Stats `json:"inclusionByPath"`
 }
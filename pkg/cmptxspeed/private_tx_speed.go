@@ -0,0 +1,450 @@
+package cmptxspeed
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"performance/internal/pkg/flags"
+	"performance/internal/pkg/inclusion"
+	"performance/internal/pkg/keys"
+	"performance/internal/pkg/report"
+	"performance/internal/pkg/rpc"
+	"performance/internal/pkg/tlsconfig"
+	"performance/internal/pkg/txfee"
+	"performance/internal/pkg/ws"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// Submission paths which can be raced against each other.
+const (
+	pathBXTx           = "blxr_tx"
+	pathBXPrivateTx    = "blxr_private_tx"
+	pathBXBundle       = "blxr_submit_bundle"
+	pathRelayBundle    = "eth_sendBundle"
+	pathRelayPrivateTx = "eth_sendPrivateTransaction"
+	pathNodeRawTx      = "eth_sendRawTransaction"
+)
+
+// flashbotsSignatureHeader authenticates requests to Flashbots compatible relays.
+const flashbotsSignatureHeader = "X-Flashbots-Signature"
+
+// PrivateTxSpeedCompareService represents a service which compares private transaction and
+// bundle submission paths by racing transactions with the same nonce across them.
+type PrivateTxSpeedCompareService struct {
+	// Pauses between the steps of the benchmark.
+	sendWait time.Duration
+	// Interval between polls of the latest block by clients without subscriptions.
+	headInterval time.Duration
+}
+
+// submitter sends a signed transaction over one of the submission paths.
+type submitter struct {
+	nodeConn    rpc.Client
+	bxConn      rpc.Client
+	relay       string
+	relayKey    *ecdsa.PrivateKey
+	networkName string
+	maxBlocks   int
+}
+
+// Run is an entry point to the PrivateTxSpeedCompareService.
+func (s *PrivateTxSpeedCompareService) Run(c *cli.Context) error {
+	var (
+		gasLimit            = int64(22000)
+		relaySigningKey     = c.String(flags.RelaySigningKey.Name)
+		bxEndpoint          = c.String(flags.BXEndpoint.Name)
+		bxAuthHeader        = c.String(flags.BXAuthHeader.Name)
+		relayEndpoint       = c.String(flags.RelayEndpoint.Name)
+		paths               = c.StringSlice(flags.SubmissionPaths.Name)
+		numTxGroups         = c.Int(flags.NumTxGroups.Name)
+		chainID             = c.Int(flags.ChainID.Name)
+		confirmations       = c.Int(flags.Confirmations.Name)
+		confirmationTimeout = c.Int(flags.ConfirmationTimeout.Name)
+		delay               = c.Int(flags.Delay.Name)
+		nodeEndpoint        = c.String(flags.NodeWSEndpoint.Name)
+	)
+
+	if err := validatePaths(paths); err != nil {
+		return err
+	}
+
+	rep, err := report.New(c, map[string]string{
+		"node":    nodeEndpoint,
+		"gateway": bxEndpoint,
+		"relay":   relayEndpoint,
+	})
+	if err != nil {
+		return err
+	}
+	out := rep.Output()
+
+	secretKey, err := keys.FromFlags(c)
+	if err != nil {
+		return err
+	}
+
+	sub := &submitter{
+		relay:       relayEndpoint,
+		relayKey:    secretKey,
+		networkName: c.String(flags.NetworkName.Name),
+		maxBlocks:   c.Int(flags.MaxBlocks.Name),
+	}
+
+	if relaySigningKey != "" {
+		if sub.relayKey, err = keys.ParsePrivateKey(relaySigningKey); err != nil {
+			return err
+		}
+	}
+
+	address := crypto.PubkeyToAddress(secretKey.PublicKey).Hex()
+
+	sub.nodeConn, err = openConnection(nodeEndpoint, "")
+	if err != nil {
+		return err
+	}
+	defer closeConnection(sub.nodeConn, nodeEndpoint)
+
+	if usesGateway(paths) {
+		sub.bxConn, err = openConnection(bxEndpoint, bxAuthHeader)
+		if err != nil {
+			return err
+		}
+		defer closeConnection(sub.bxConn, bxEndpoint)
+	}
+
+	nonce, err := rpc.Nonce(sub.nodeConn, address)
+	if err != nil {
+		return err
+	}
+
+	balance, err := rpc.Balance(sub.nodeConn, address)
+	if err != nil {
+		return err
+	}
+
+	fees, err := txfee.FromFlags(c, sub.nodeConn)
+	if err != nil {
+		return err
+	}
+
+	expense := fees.MaxCost(numTxGroups, uint64(gasLimit))
+	if balance.Cmp(expense) < 0 {
+		fmt.Fprintf(out, "Sender %s does not have enough balance for %d groups of transactions.\n"+
+			"Sender's balance is %f Coins,\n"+
+			"while at least %f Coins is required\n",
+			address,
+			numTxGroups,
+			txfee.ToEther(balance),
+			txfee.ToEther(expense))
+
+		return nil
+	}
+
+	fmt.Fprintf(out, "Initial check completed, racing %s with %s. Sleeping %d sec.\n",
+		strings.Join(paths, ", "), fees, delay)
+	time.Sleep(time.Duration(delay) * time.Second)
+
+	var (
+		addr    = common.HexToAddress(address)
+		limit   = uint64(gasLimit)
+		chain   = big.NewInt(int64(chainID))
+		signer  = fees.Signer(chain)
+		groups  = make(map[int]*privateGroupResult)
+		tracker = inclusion.NewTracker(sub.nodeConn, []string{address}, confirmations)
+	)
+
+	if err := tracker.Follow(s.headInterval); err != nil {
+		return err
+	}
+
+	for i := 1; i <= numTxGroups; i++ {
+		groupStart := time.Now()
+
+		fmt.Fprintf(out, "Sending tx group %d\n", i)
+
+		head, err := rpc.BlockNumber(sub.nodeConn)
+		if err != nil {
+			return err
+		}
+
+		group := &privateGroupResult{
+			Nonce:        nonce,
+			Transactions: make(map[string]string),
+			Responses:    make(map[string]string),
+		}
+
+		rawTxs := make(map[string]string)
+		for j, path := range paths {
+			// Every path gets a different transaction, so the landed one identifies the path.
+			signedTx, err := types.SignTx(fees.NewTx(chain, nonce, &addr, limit, txfee.GroupData(j)), signer, secretKey)
+			if err != nil {
+				return err
+			}
+
+			encodedTx, err := rpc.EncodeTx(signedTx)
+			if err != nil {
+				return err
+			}
+
+			group.Transactions[path] = signedTx.Hash().Hex()
+			rawTxs[path] = encodedTx
+		}
+
+		type pathResponse struct {
+			path string
+			data []byte
+		}
+
+		submitted := time.Now()
+		responses := make(chan pathResponse, len(paths))
+		for _, path := range paths {
+			go func(path string) {
+				responses <- pathResponse{path, sub.submit(path, rawTxs[path], head)}
+			}(path)
+		}
+
+		for range paths {
+			res := <-responses
+			group.Responses[res.path] = string(res.data)
+			fmt.Fprintf(out, "%s response: %s\n", res.path, strings.TrimSpace(string(res.data)))
+		}
+		tracker.Track(i, address, nonce, group.Transactions, head, submitted)
+
+		group.start, group.end = groupStart, time.Now()
+		groups[i] = group
+
+		time.Sleep(s.sendWait)
+
+		nonce++
+		// Add a delay to all the groups except for the last group
+		if i < numTxGroups {
+			fmt.Fprintf(out, "Sleeping %d sec.\n", delay)
+			time.Sleep(time.Duration(delay) * time.Second)
+		}
+	}
+
+	fmt.Fprintf(out, "Waiting up to %d sec for the transactions to be confirmed.\n", confirmationTimeout)
+	if !tracker.Wait(time.Duration(confirmationTimeout) * time.Second) {
+		fmt.Fprintf(out, "%d transactions are still pending.\n", tracker.Pending())
+	}
+
+	var (
+		inclusions   = tracker.Stop()
+		landedByPath = make(map[string]int)
+		landed       = len(inclusions)
+	)
+
+	for groupNum, inc := range inclusions {
+		landedByPath[inc.Endpoint]++
+		groups[groupNum].LandedBy = inc.Endpoint
+		groups[groupNum].Inclusion = inc
+	}
+
+	fmt.Fprintf(out, "\n----------------------------------------------------------------\n"+
+		"Sent %d groups of transactions over %d submission paths,\n"+
+		"%d of them have landed:\n",
+		numTxGroups, len(paths), landed)
+
+	for _, path := range paths {
+		fmt.Fprintf(out, "Number of landed transactions is %d for %s\n", landedByPath[path], path)
+	}
+
+	for i := 1; i <= numTxGroups; i++ {
+		if inc := groups[i].Inclusion; inc != nil {
+			fmt.Fprintf(out, "Group %d landed by %s in block %d, %d blocks after submission\n",
+				i, inc.Endpoint, inc.BlockNumber, inc.BlocksToInclusion)
+		}
+	}
+
+	inclusionByPath := inclusion.Aggregate(inclusions)
+	fmt.Fprint(out, inclusion.Format(inclusionByPath))
+
+	agg := &privateSummary{
+		Groups:          numTxGroups,
+		Landed:          landed,
+		LandedByPath:    landedByPath,
+		InclusionByPath: inclusionByPath,
+	}
+
+	for i := 1; i <= numTxGroups; i++ {
+		rep.AddInterval(i, groups[i].start, groups[i].end, groups[i])
+	}
+	rep.SetAggregate(agg)
+
+	return rep.Write()
+}
+
+// submit sends the transaction over the path and returns the response, or the error text
+// if the request failed. Bundles are submitted for each of the next maxBlocks blocks after
+// the block, so one response per target block is returned.
+func (s *submitter) submit(path, rawTx string, block uint64) []byte {
+	var (
+		lastBlock = block + uint64(s.maxBlocks)
+		responses [][]byte
+	)
+
+	switch path {
+	case pathBXTx, pathBXPrivateTx:
+		responses = append(responses, s.callGateway(ws.NewRequest(1, path, []interface{}{
+			map[string]interface{}{
+				"transaction":        rawTx[2:],
+				"blockchain_network": s.networkName,
+			},
+		})))
+	case pathBXBundle:
+		for target := block + 1; target <= lastBlock; target++ {
+			responses = append(responses, s.callGateway(ws.NewRequest(1, path, []interface{}{
+				map[string]interface{}{
+					"transaction":        []string{rawTx[2:]},
+					"block_number":       hexutil.EncodeUint64(target),
+					"blockchain_network": s.networkName,
+				},
+			})))
+		}
+	case pathRelayBundle:
+		for target := block + 1; target <= lastBlock; target++ {
+			responses = append(responses, s.callRelay(ws.NewRequest(1, path, []interface{}{
+				map[string]interface{}{
+					"txs":         []string{rawTx},
+					"blockNumber": hexutil.EncodeUint64(target),
+				},
+			})))
+		}
+	case pathRelayPrivateTx:
+		responses = append(responses, s.callRelay(ws.NewRequest(1, path, []interface{}{
+			map[string]interface{}{
+				"tx":             rawTx,
+				"maxBlockNumber": hexutil.EncodeUint64(lastBlock),
+			},
+		})))
+	case pathNodeRawTx:
+		responses = append(responses, toResponse(rpc.SendRawTransaction(s.nodeConn, rawTx)))
+	}
+
+	return bytes.Join(responses, []byte("\n"))
+}
+
+func (s *submitter) callGateway(req *ws.Request) []byte {
+	return toResponse(s.bxConn.Call(req))
+}
+
+// callRelay makes a request to the relay which is authenticated with the signing key as
+// required by Flashbots.
+func (s *submitter) callRelay(req *ws.Request) []byte {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return []byte(err.Error())
+	}
+
+	signature, err := flashbotsSignature(body, s.relayKey)
+	if err != nil {
+		return []byte(err.Error())
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, s.relay, bytes.NewReader(body))
+	if err != nil {
+		return []byte(err.Error())
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(flashbotsSignatureHeader, signature)
+
+	resp, err := tlsconfig.HTTPClient(s.relay).Do(httpReq)
+	if err != nil {
+		return []byte(err.Error())
+	}
+	defer resp.Body.Close()
+
+	return toResponse(ioutil.ReadAll(resp.Body))
+}
+
+// flashbotsSignature signs the hex encoded hash of the request body and returns the value
+// of the X-Flashbots-Signature header.
+func flashbotsSignature(body []byte, key *ecdsa.PrivateKey) (string, error) {
+	hash := accounts.TextHash([]byte(hexutil.Encode(crypto.Keccak256(body))))
+
+	signature, err := crypto.Sign(hash, key)
+	if err != nil {
+		return "", fmt.Errorf("cannot sign relay request: %v", err)
+	}
+
+	return crypto.PubkeyToAddress(key.PublicKey).Hex() + ":" + hexutil.Encode(signature), nil
+}
+
+func toResponse(data []byte, err error) []byte {
+	if err != nil {
+		return []byte(err.Error())
+	}
+
+	return bytes.TrimSpace(data)
+}
+
+func validatePaths(paths []string) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("error: at least one --%s is required", flags.SubmissionPaths.Name)
+	}
+
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		switch path {
+		case pathBXTx, pathBXPrivateTx, pathBXBundle, pathRelayBundle, pathRelayPrivateTx, pathNodeRawTx:
+		default:
+			return fmt.Errorf("error: unknown submission path %q", path)
+		}
+
+		if seen[path] {
+			return fmt.Errorf("error: duplicate submission path %q", path)
+		}
+		seen[path] = true
+	}
+
+	return nil
+}
+
+func usesGateway(paths []string) bool {
+	for _, path := range paths {
+		if strings.HasPrefix(path, "blxr_") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func openConnection(uri, authHeader string) (rpc.Client, error) {
+	log.Debugf("initiating connection to %s", uri)
+	conn, err := rpc.Dial(uri, authHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("connection to %s established", uri)
+	return conn, nil
+}
+
+func closeConnection(conn rpc.Client, uri string) {
+	if err := conn.Close(); err != nil {
+		log.Errorf("cannot close connection to %s: %v", uri, err)
+	}
+	log.Debugf("connection to %s was closed", uri)
+}
+
+// NewPrivateTxSpeedCompareService creates and initializes PrivateTxSpeedCompareService instance.
+func NewPrivateTxSpeedCompareService() *PrivateTxSpeedCompareService {
+	return &PrivateTxSpeedCompareService{
+		sendWait:     5 * time.Second,
+		headInterval: time.Second,
+	}
+}
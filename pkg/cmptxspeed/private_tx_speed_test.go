@@ -0,0 +1,103 @@
+package cmptxspeed
+
+import (
+	"math/big"
+	"performance/internal/pkg/flags"
+	"performance/internal/pkg/keys"
+	"performance/internal/pkg/mock"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/urfave/cli/v2"
+)
+
+const testPrivateKey = "0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+func TestPrivateTxSpeedCompare(t *testing.T) {
+	gateway, node, relay := mock.NewGateway(), mock.NewNode(), mock.NewRelay()
+	defer gateway.Close()
+	defer node.Close()
+	defer relay.Close()
+
+	node.SetAccount(5, big.NewInt(params.Ether))
+	node.SetBlockNumber(100)
+	// Only the transactions sent with eth_sendPrivateTransaction, the second path, land.
+	relay.OnRawTransaction(func(tx *types.Transaction) {
+		if tx.Data()[0] == 2 {
+			node.Mine(tx, node.BlockNumber()+2)
+			node.NewHead()
+			node.NewHead()
+		}
+	})
+
+	var (
+		res privateSummary
+		svc = &PrivateTxSpeedCompareService{
+			sendWait: 10 * time.Millisecond,
+		}
+	)
+
+	err := <-mock.RunCommand(t, svc.Run, []cli.Flag{
+		flags.NodeWSEndpoint,
+		flags.BXEndpoint,
+		flags.BXAuthHeader,
+		flags.RelayEndpoint,
+		flags.RelaySigningKey,
+		flags.SubmissionPaths,
+		flags.MaxBlocks,
+		flags.SenderPrivateKey,
+		flags.ChainID,
+		flags.NetworkName,
+		flags.NumTxGroups,
+		flags.GasPrice,
+		flags.TxType,
+		flags.MaxFee,
+		flags.MaxPriorityFee,
+		flags.Confirmations,
+		flags.ConfirmationTimeout,
+		flags.Delay,
+	}, &res,
+		"--node-ws-endpoint", node.WSURL(),
+		"--blxr-endpoint", gateway.URL(),
+		"--relay-endpoint", relay.URL(),
+		"--paths", "blxr_private_tx,eth_sendPrivateTransaction,blxr_submit_bundle,eth_sendBundle",
+		"--max-blocks", "2",
+		"--sender-private-key", testPrivateKey,
+		"--num-tx-groups", "2",
+		"--gas-price", "10",
+		"--delay", "0",
+		"--confirmation-timeout", "5",
+	)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	if res.Groups != 2 || res.Landed != 2 || res.LandedByPath[pathRelayPrivateTx] != 2 {
+		t.Fatalf("expected both groups to land with %s, got %+v", pathRelayPrivateTx, res)
+	}
+
+	if inc := res.InclusionByPath[pathRelayPrivateTx]; inc == nil || inc.AvgBlocksToInclusion != 2 {
+		t.Errorf("expected transactions to land 2 blocks after submission, got %+v", inc)
+	}
+
+	// A private transaction and a bundle for each of the 2 target blocks per group.
+	if sent := len(gateway.Sent()); sent != 6 {
+		t.Errorf("expected 6 transactions to be sent to the gateway, got %d", sent)
+	}
+
+	if sent := len(relay.Sent()); sent != 6 {
+		t.Errorf("expected 6 transactions to be sent to the relay, got %d", sent)
+	}
+
+	key, err := keys.ParsePrivateKey(testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !relay.Signed(crypto.PubkeyToAddress(key.PublicKey)) {
+		t.Error("expected relay requests to be signed by the sender")
+	}
+}
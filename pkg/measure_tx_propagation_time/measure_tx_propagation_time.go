@@ -5,15 +5,20 @@ import (
 	"crypto/ecdsa"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math/big"
+	"os"
 	"performance/internal/pkg/flags"
-	"performance/internal/pkg/ws"
-	"performance/pkg/cmpnodestxspeedhttp"
+	"performance/internal/pkg/inclusion"
+	"performance/internal/pkg/keys"
+	"performance/internal/pkg/report"
+	"performance/internal/pkg/rpc"
+	"performance/internal/pkg/txfee"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/crypto"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 	"go.uber.org/zap"
@@ -23,6 +28,8 @@ import (
 // between EVM node and BX gateway.
 type MeasureTxPropagationTimeService struct {
 	txHashToFind string
+	out          io.Writer
+	headInterval time.Duration
 
 	propagatedTxs map[string]time.Duration
 }
@@ -31,6 +38,8 @@ type MeasureTxPropagationTimeService struct {
 func NewMeasureTxPropagationTimeService() *MeasureTxPropagationTimeService {
 	return &MeasureTxPropagationTimeService{
 		txHashToFind:  "foo",
+		out:           os.Stdout,
+		headInterval:  time.Second,
 		propagatedTxs: make(map[string]time.Duration),
 	}
 }
@@ -38,46 +47,61 @@ func NewMeasureTxPropagationTimeService() *MeasureTxPropagationTimeService {
 // Run is an entry point to the MeasureTxPropagationTimeService.
 func (s *MeasureTxPropagationTimeService) Run(c *cli.Context) error {
 	var (
-		gasLimit         = int64(22000)
-		senderPrivateKey = c.String(flags.SenderPrivateKey.Name)
-		gasPriceWei      = c.Int64(flags.GasPrice.Name) * params.GWei
-		chainID          = c.Int(flags.ChainID.Name)
-		nodeEndpoint     = c.String(flags.NodeEndpoint.Name)
+		gasLimit            = int64(22000)
+		chainID             = c.Int(flags.ChainID.Name)
+		nodeEndpoint        = c.String(flags.NodeEndpoint.Name)
+		txsFeedUri          = c.String(flags.FeedWSEndpoint.Name)
+		confirmationTimeout = c.Int(flags.ConfirmationTimeout.Name)
 	)
 
+	rep, err := report.New(c, map[string]string{"node": nodeEndpoint, "feed": txsFeedUri})
+	if err != nil {
+		return err
+	}
+	s.out = rep.Output()
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	secretKey, err := cmpnodestxspeedhttp.MakePrivateKey(senderPrivateKey)
+	secretKey, err := keys.FromFlags(c)
 	if err != nil {
 		zap.L().Error("error while making private key", zap.Error(err))
 		return err
 	}
 
-	address, err := cmpnodestxspeedhttp.GetSenderAddress(secretKey)
+	address := crypto.PubkeyToAddress(secretKey.PublicKey).Hex()
+
+	node, err := rpc.Dial(nodeEndpoint, "")
 	if err != nil {
-		zap.L().Error("error while getting sender address", zap.Error(err))
-		return err
+		return fmt.Errorf("cannot establish connection to %s: %v", nodeEndpoint, err)
 	}
+	defer func() {
+		if err := node.Close(); err != nil {
+			log.Errorf("cannot close connection to %s: %v", nodeEndpoint, err)
+		}
+	}()
 
-	balance, err := cmpnodestxspeedhttp.GetBalance(address, nodeEndpoint)
+	balance, err := rpc.Balance(node, address)
 	if err != nil {
 		zap.L().Error("error while getting balance", zap.Error(err))
 		return err
 	}
 
-	var (
-		txsFeedUri = c.String(flags.FeedWSEndpoint.Name)
-		txsCount   = c.Int(flags.TxCount.Name)
-	)
+	fees, err := txfee.FromFlags(c, node)
+	if err != nil {
+		return err
+	}
 
-	if expense := int64(txsCount) * gasPriceWei * gasLimit; balance < uint64(expense) {
+	txsCount := c.Int(flags.TxCount.Name)
+
+	expense := fees.MaxCost(txsCount, uint64(gasLimit))
+	if balance.Cmp(expense) < 0 {
 		var (
-			requiredEvm = float64(expense) / params.Ether
-			currentEvm  = float64(balance) / params.Ether
+			requiredEvm = txfee.ToEther(expense)
+			currentEvm  = txfee.ToEther(balance)
 		)
 
-		fmt.Printf("Sender %s does not have enough balance for %d groups of transactions.\n"+
+		fmt.Fprintf(s.out, "Sender %s does not have enough balance for %d groups of transactions.\n"+
 			"Sender's balance is %f Coins,\n"+
 			"while at least %f Coins is required\n",
 			address,
@@ -94,17 +118,30 @@ func (s *MeasureTxPropagationTimeService) Run(c *cli.Context) error {
 
 	foundTxHashChan := s.findTxHash(ctx, txFeedChan)
 
+	tracker := inclusion.NewTracker(node, []string{address}, c.Int(flags.Confirmations.Name))
+	if err := tracker.Follow(s.headInterval); err != nil {
+		return err
+	}
+	defer tracker.Stop()
+
 	averagePropagationTime := time.Duration(0)
-	fmt.Printf("Starting sending and waiting for tx, tx count in queue %d\n\n", txsCount)
+	fmt.Fprintf(s.out, "Starting sending and waiting for tx, tx count in queue %d\n\n", txsCount)
 	for i := 0; i < txsCount; i++ {
 		// check before send that previous tx already not pending
 
-		hash, err := s.sendTx(nodeEndpoint, address, gasLimit, gasPriceWei, int64(chainID), secretKey)
+		head, err := rpc.BlockNumber(node)
+		if err != nil {
+			return fmt.Errorf("cannot get head block: %v", err)
+		}
+
+		hash, nonce, err := s.sendTx(node, address, gasLimit, fees, int64(chainID), secretKey)
 		if err != nil {
 			zap.L().Error("Error while sendind tx", zap.Error(err))
 			return err
 		}
 		now := time.Now()
+		tracker.Track(i, address, nonce, map[string]string{nodeEndpoint: hash}, head, now)
+
 		message := <-foundTxHashChan
 		if message.err != nil {
 			zap.L().Error("Error while receiving message from tx feed", zap.Error(message.err))
@@ -112,26 +149,33 @@ func (s *MeasureTxPropagationTimeService) Run(c *cli.Context) error {
 		}
 		propagationTime := time.Since(now)
 		s.propagatedTxs[hash] = propagationTime
+		rep.AddInterval(i+1, now, now.Add(propagationTime), &propagationResult{
+			Hash:              hash,
+			PropagationTimeMs: propagationTime.Milliseconds(),
+		})
 		averagePropagationTime = averagePropagationTime + (propagationTime / time.Duration(txsCount))
-		fmt.Printf("\nTx with hash %s propagated in %s\nSleeping for %s\n\n", hash, propagationTime, c.Duration(flags.Delay.Name))
+		fmt.Fprintf(s.out, "\nTx with hash %s propagated in %s\nSleeping for %s\n\n", hash, propagationTime, c.Duration(flags.Delay.Name))
 		time.Sleep(c.Duration(flags.Delay.Name))
-		for {
-			if confirmed, err := cmpnodestxspeedhttp.IsConfirmed(s.txHashToFind, nodeEndpoint); !confirmed || err != nil {
-				fmt.Printf("Waiting for the tx '%s' to be confirmed, sleeping for 5s.\n", s.txHashToFind)
-				time.Sleep(5 * time.Second)
-				continue
-			}
-			break
+
+		// The next transaction reuses the nonce unless this one is confirmed.
+		fmt.Fprintf(s.out, "Waiting up to %d sec for the tx '%s' to be confirmed.\n", confirmationTimeout, hash)
+		if !tracker.Wait(time.Duration(confirmationTimeout) * time.Second) {
+			return fmt.Errorf("transaction %s was not confirmed in %d sec", hash, confirmationTimeout)
 		}
 	}
 
-	fmt.Println("\n\nResult:")
+	fmt.Fprintln(s.out, "\n\nResult:")
 	for hash, duration := range s.propagatedTxs {
-		fmt.Printf("%s propagated in %s\n", hash, duration)
+		fmt.Fprintf(s.out, "%s propagated in %s\n", hash, duration)
 	}
-	fmt.Printf("\nAverage propagation time is %s\n", averagePropagationTime)
+	fmt.Fprintf(s.out, "\nAverage propagation time is %s\n", averagePropagationTime)
+
+	rep.SetAggregate(&propagationSummary{
+		TxCount:                  txsCount,
+		AveragePropagationTimeMs: averagePropagationTime.Milliseconds(),
+	})
 
-	return nil
+	return rep.Write()
 }
 
 func (s *MeasureTxPropagationTimeService) findTxHash(
@@ -150,7 +194,7 @@ func (s *MeasureTxPropagationTimeService) findTxHash(
 				}
 
 				if data.hash == s.txHashToFind {
-					fmt.Printf("found tx with hash %s\n", data.hash)
+					fmt.Fprintf(s.out, "found tx with hash %s\n", data.hash)
 					foundTxChan <- data
 				}
 			}
@@ -163,16 +207,22 @@ func (s *MeasureTxPropagationTimeService) findTxHash(
 func (s *MeasureTxPropagationTimeService) readNewTxsFeed(
 	ctx context.Context, uri string,
 ) (<-chan *message, error) {
-	log.Debug("Initiating connection to %s", uri)
+	log.Debugf("Initiating connection to %s", uri)
 
-	conn, err := ws.NewConnection(uri, "")
+	client, err := rpc.Dial(uri, "")
 	if err != nil {
 		return nil, fmt.Errorf("cannot establish connection to %s: %v", uri, err)
 	}
 
-	log.Debug("Connection to %s established", uri)
+	conn, ok := client.(rpc.Subscriber)
+	if !ok {
+		_ = client.Close()
+		return nil, fmt.Errorf("error: feed endpoint %s does not support subscriptions", uri)
+	}
+
+	log.Debugf("Connection to %s established", uri)
 
-	sub, err := conn.SubscribeTxFeedEvm(1)
+	sub, err := conn.SubscribeTxFeedEvm()
 	if err != nil {
 		return nil, fmt.Errorf("cannot subscribe to EVM feed: %v", err)
 	}
@@ -204,7 +254,7 @@ func (s *MeasureTxPropagationTimeService) readNewTxsFeed(
 			var feedRes evmTxFeedResponse
 			err = json.Unmarshal(data, &feedRes)
 			if err != nil {
-				fmt.Printf("Error while unmarshaling tx feed: %v", err)
+				fmt.Fprintf(s.out, "Error while unmarshaling tx feed: %v", err)
 				return
 			}
 
@@ -221,50 +271,33 @@ func (s *MeasureTxPropagationTimeService) readNewTxsFeed(
 	return out, nil
 }
 
-func (s *MeasureTxPropagationTimeService) sendTx(nodeEndpoint, address string, gasLimit, gasPriceWei, chainID int64, secretKey *ecdsa.PrivateKey) (string, error) {
+func (s *MeasureTxPropagationTimeService) sendTx(node rpc.Client, address string, gasLimit int64, fees *txfee.Fees, chainID int64, secretKey *ecdsa.PrivateKey) (string, uint64, error) {
 	var (
 		addr   = common.HexToAddress(address)
-		value  = big.NewInt(0)
 		limit  = uint64(gasLimit)
-		price  = big.NewInt(gasPriceWei)
-		signer = types.NewEIP155Signer(big.NewInt(chainID))
+		chain  = big.NewInt(chainID)
+		signer = fees.Signer(chain)
 	)
-	nonce, err := cmpnodestxspeedhttp.GetNonce(address, nodeEndpoint)
+	nonce, err := rpc.Nonce(node, address)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
-	tx := types.NewTx(&types.LegacyTx{
-		To:       &addr,
-		Value:    value,
-		Gas:      limit,
-		GasPrice: price,
-		Nonce:    nonce,
-		Data:     []byte("0x11111111"),
-	})
+	tx := fees.NewTx(chain, nonce, &addr, limit, []byte("0x11111111"))
 	evmSignedTx, err := types.SignTx(tx, signer, secretKey)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	s.txHashToFind = evmSignedTx.Hash().Hex()
-	fmt.Printf("hash to find %s\n", evmSignedTx.Hash().Hex())
-
-	evmEncodedTx, err := cmpnodestxspeedhttp.EncodeSignedTx(evmSignedTx)
-	if err != nil {
-		return "", err
-	}
-
-	req := ws.NewRequest(1, "eth_sendRawTransaction", []interface{}{
-		evmEncodedTx,
-	})
+	fmt.Fprintf(s.out, "hash to find %s\n", evmSignedTx.Hash().Hex())
 
-	reqBody, err := json.Marshal(req)
+	evmEncodedTx, err := rpc.EncodeTx(evmSignedTx)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
-	data, err := cmpnodestxspeedhttp.DoRequest(nodeEndpoint, reqBody)
-	log.Debug("Send transaction response: %s, error: %v\n", string(data), err)
+	data, err := rpc.SendRawTransaction(node, evmEncodedTx)
+	log.Debugf("Send transaction response: %s, error: %v\n", string(data), err)
 
-	return evmSignedTx.Hash().Hex(), err
+	return evmSignedTx.Hash().Hex(), nonce, err
 }
@@ -14,3 +14,15 @@ type evmTxFeedResponse struct {
 		Result       string `json:"result"`
 	} `json:"params"`
 }
+
+// propagationResult holds the propagation time of a single transaction.
+type propagationResult struct {
+	Hash              string `json:"hash"`
+	PropagationTimeMs int64  `json:"propagationTimeMs"`
+}
+
+// propagationSummary holds the outcome of all transactions.
+type propagationSummary struct {
+	TxCount                  int   `json:"txCount"`
+	AveragePropagationTimeMs int64 `json:"averagePropagationTimeMs"`
+}
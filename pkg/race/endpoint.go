@@ -0,0 +1,132 @@
+package race
+
+import (
+	"fmt"
+	"performance/internal/pkg/rpc"
+	"performance/internal/pkg/ws"
+	"strings"
+)
+
+// Types of the submission endpoints.
+const (
+	endpointTypeEvmWS     = "evm-ws"
+	endpointTypeEvmHTTP   = "evm-http"
+	endpointTypeBloxroute = "bloxroute"
+)
+
+// endpoint is a submission endpoint taking part in the race. Network and authHeader are
+// used by the bloxroute endpoints.
+type endpoint struct {
+	name       string
+	uri        string
+	typ        string
+	network    string
+	authHeader string
+
+	client rpc.Client
+}
+
+// send submits the encoded transaction and returns the response, or the error text if the
+// request failed.
+func (e *endpoint) send(rawTx string) []byte {
+	var (
+		data []byte
+		err  error
+	)
+
+	if e.typ == endpointTypeBloxroute {
+		data, err = e.client.Call(ws.NewRequest(1, "blxr_tx", []interface{}{
+			map[string]interface{}{
+				"transaction":        rawTx[2:],
+				"blockchain_network": e.network,
+			},
+		}))
+	} else {
+		data, err = rpc.SendRawTransaction(e.client, rawTx)
+	}
+
+	if err != nil {
+		return []byte(err.Error())
+	}
+
+	return data
+}
+
+// parseEndpoints parses values of the repeatable endpoint flag. Each endpoint has a form of
+// name=uri,type=evm-ws|evm-http|bloxroute[,network=name][,auth=header]. The cli library
+// splits flag values on commas, so the options are received as separate items and are
+// applied to the preceding endpoint.
+func parseEndpoints(values []string, network, authHeader string) ([]*endpoint, error) {
+	var (
+		endpoints []*endpoint
+		names     = make(map[string]struct{})
+	)
+
+	for _, value := range values {
+		kv := strings.SplitN(strings.TrimSpace(value), "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid endpoint %q, expected name=uri,type=%s|%s|%s",
+				value, endpointTypeEvmWS, endpointTypeEvmHTTP, endpointTypeBloxroute)
+		}
+
+		key, val := kv[0], kv[1]
+		switch key {
+		case "type", "network", "auth":
+			if len(endpoints) == 0 {
+				return nil, fmt.Errorf("option %q must follow an endpoint name=uri", value)
+			}
+
+			e := endpoints[len(endpoints)-1]
+			switch key {
+			case "network":
+				e.network = val
+			case "auth":
+				e.authHeader = val
+			default:
+				e.typ = val
+			}
+		default:
+			if _, ok := names[key]; ok {
+				return nil, fmt.Errorf("duplicate endpoint name %q", key)
+			}
+
+			names[key] = struct{}{}
+			endpoints = append(endpoints, &endpoint{name: key, uri: val, network: network, authHeader: authHeader})
+		}
+	}
+
+	for _, e := range endpoints {
+		if err := e.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(endpoints) < 2 {
+		return nil, fmt.Errorf("at least two endpoints are required for the race")
+	}
+
+	return endpoints, nil
+}
+
+func (e *endpoint) validate() error {
+	isHTTP := strings.HasPrefix(e.uri, "http://") || strings.HasPrefix(e.uri, "https://")
+
+	switch e.typ {
+	case "":
+		return fmt.Errorf("type of endpoint %q is not specified", e.name)
+	case endpointTypeEvmWS:
+		if isHTTP {
+			return fmt.Errorf("endpoint %q of type %s must be a websocket or IPC endpoint", e.name, e.typ)
+		}
+	case endpointTypeEvmHTTP:
+		if !isHTTP {
+			return fmt.Errorf("endpoint %q of type %s must be an HTTP endpoint", e.name, e.typ)
+		}
+	case endpointTypeBloxroute:
+	default:
+		return fmt.Errorf("invalid type %q of endpoint %q, possible values: %q, %q, %q",
+			e.typ, e.name, endpointTypeEvmWS, endpointTypeEvmHTTP, endpointTypeBloxroute)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package race
+
+import (
+	"performance/internal/pkg/inclusion"
+	"time"
+)
+
+// groupResult holds the outcome of a group of conflicting transactions.
+type groupResult struct {
+	Sender string `json:"sender"`
+	Nonce  uint64 `json:"nonce"`
+	// Transactions and Responses are keyed by the endpoint the transaction was sent to.
+	Transactions map[string]string    `json:"transactions"`
+	Responses    map[string]string    `json:"responses"`
+	MinedBy      string               `json:"minedBy,omitempty"`
+	Inclusion    *inclusion.Inclusion `json:"inclusion,omitempty"`
+
+	start time.Time
+	end   time.Time
+}
+
+// summary holds the outcome of all groups of transactions. An endpoint wins a group when
+// its transaction is the confirmed one, and its win rate is the percentage of the
+// confirmed groups it won.
+type summary struct {
+	Groups              int                         `json:"groups"`
+	Confirmed           int                         `json:"confirmed"`
+	ConfirmedByEndpoint map[string]int              `json:"confirmedByEndpoint"`
+	WinRateByEndpoint   map[string]float64          `json:"winRateByEndpoint"`
+	InclusionByEndpoint map[string]*inclusion.Stats `json:"inclusionByEndpoint"`
+}
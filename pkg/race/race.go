@@ -0,0 +1,318 @@
+// Package race compares how fast the submission endpoints land transactions, by racing
+// conflicting transactions with the same nonce across them, so only one tx lands on chain.
+package race
+
+import (
+	"fmt"
+	"math/big"
+	"performance/internal/pkg/flags"
+	"performance/internal/pkg/inclusion"
+	"performance/internal/pkg/report"
+	"performance/internal/pkg/rpc"
+	"performance/internal/pkg/senders"
+	"performance/internal/pkg/txfee"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// gasLimit is the gas limit of the raced transactions.
+const gasLimit = 22000
+
+// RaceService represents a service which compares transaction sending speed between any
+// number of Evm nodes and BX gateways.
+type RaceService struct {
+	// Pauses between the steps of the benchmark.
+	sendWait time.Duration
+	// Interval between polls of the latest block by clients without subscriptions.
+	headInterval time.Duration
+}
+
+// NewRaceService creates and initializes RaceService instance.
+func NewRaceService() *RaceService {
+	return &RaceService{
+		sendWait:     5 * time.Second,
+		headInterval: time.Second,
+	}
+}
+
+// Run is an entry point of the race command, which races the endpoints of the flags.
+func (s *RaceService) Run(c *cli.Context) error {
+	endpoints, err := parseEndpoints(
+		c.StringSlice(flags.RaceEndpoint.Name),
+		c.String(flags.NetworkName.Name),
+		c.String(flags.BXAuthHeader.Name))
+	if err != nil {
+		return err
+	}
+
+	status := c.String(flags.StatusEndpoint.Name)
+	if status == "" {
+		for _, e := range endpoints {
+			if e.typ != endpointTypeBloxroute {
+				status = e.uri
+				break
+			}
+		}
+	}
+
+	if status == "" {
+		return fmt.Errorf("error: --%s is required when all endpoints are of type %s",
+			flags.StatusEndpoint.Name, endpointTypeBloxroute)
+	}
+
+	reportEndpoints := map[string]string{"status": status}
+	for _, e := range endpoints {
+		reportEndpoints[e.name] = e.uri
+	}
+
+	return s.race(c, endpoints, status, reportEndpoints)
+}
+
+// RunTxSpeed is an entry point of the txspeed command, which races the node against the
+// gateway.
+func (s *RaceService) RunTxSpeed(c *cli.Context) error {
+	var (
+		nodeEndpoint = c.String(flags.NodeWSEndpoint.Name)
+		bxEndpoint   = c.String(flags.BXEndpoint.Name)
+	)
+
+	return s.race(c, []*endpoint{
+		{name: bxEndpoint, uri: bxEndpoint, typ: endpointTypeBloxroute,
+			network: c.String(flags.NetworkName.Name), authHeader: c.String(flags.BXAuthHeader.Name)},
+		{name: nodeEndpoint, uri: nodeEndpoint, typ: endpointTypeEvmWS},
+	}, nodeEndpoint, map[string]string{"node": nodeEndpoint, "gateway": bxEndpoint})
+}
+
+// RunNodeTxSpeed is an entry point of the nodetxspeed command, which races two nodes.
+func (s *RaceService) RunNodeTxSpeed(c *cli.Context) error {
+	return s.runNodes(c, c.String(flags.NodeWSEndpoint.Name), c.String(flags.SecondNodeWSEndpoint.Name),
+		endpointTypeEvmWS)
+}
+
+// RunHTTPNodeTxSpeed is an entry point of the httpnodetxspeed command, which races two nodes
+// over HTTP.
+func (s *RaceService) RunHTTPNodeTxSpeed(c *cli.Context) error {
+	return s.runNodes(c, c.String(flags.NodeEndpoint.Name), c.String(flags.SecondNodeEndpoint.Name),
+		endpointTypeEvmHTTP)
+}
+
+// runNodes races the nodes, the first one is used to check status.
+func (s *RaceService) runNodes(c *cli.Context, nodeEndpoint, secondNodeEndpoint, typ string) error {
+	endpoints := []*endpoint{
+		{name: nodeEndpoint, uri: nodeEndpoint, typ: typ},
+		{name: secondNodeEndpoint, uri: secondNodeEndpoint, typ: typ},
+	}
+
+	return s.race(c, endpoints, nodeEndpoint, map[string]string{
+		"node":       nodeEndpoint,
+		"secondNode": secondNodeEndpoint,
+	})
+}
+
+// race sends the groups of conflicting transactions, one per endpoint, and reports which
+// endpoint landed each group. The status endpoint provides the nonces, the fees and the
+// chain head.
+func (s *RaceService) race(c *cli.Context, endpoints []*endpoint, statusURI string, reportEndpoints map[string]string) error {
+	var (
+		numTxGroups         = c.Int(flags.NumTxGroups.Name)
+		chainID             = c.Int(flags.ChainID.Name)
+		confirmations       = c.Int(flags.Confirmations.Name)
+		confirmationTimeout = c.Int(flags.ConfirmationTimeout.Name)
+		delay               = c.Int(flags.Delay.Name)
+	)
+
+	rep, err := report.New(c, reportEndpoints)
+	if err != nil {
+		return err
+	}
+	out := rep.Output()
+
+	pool, err := senders.FromFlags(c)
+	if err != nil {
+		return err
+	}
+
+	status, err := openConnection(statusURI, "")
+	if err != nil {
+		return err
+	}
+	defer closeConnection(status, statusURI)
+
+	for _, e := range endpoints {
+		if e.client, err = openConnection(e.uri, e.authHeader); err != nil {
+			return err
+		}
+		defer closeConnection(e.client, e.uri)
+	}
+
+	fees, err := txfee.FromFlags(c, status)
+	if err != nil {
+		return err
+	}
+
+	if err := senders.Refresh(status, pool); err != nil {
+		return err
+	}
+
+	if !senders.CheckBalances(out, pool, fees, numTxGroups, gasLimit) {
+		return nil
+	}
+
+	fmt.Fprintf(out, "Initial check completed, sending transactions with %s from %d senders. Sleeping %d sec.\n",
+		fees, len(pool), delay)
+	time.Sleep(time.Duration(delay) * time.Second)
+
+	var (
+		chain   = big.NewInt(int64(chainID))
+		signer  = fees.Signer(chain)
+		mu      sync.Mutex
+		groups  = make(map[int]*groupResult)
+		tracker = inclusion.NewTracker(status, senders.Addresses(pool), confirmations)
+	)
+
+	if err := tracker.Follow(s.headInterval); err != nil {
+		return err
+	}
+
+	err = senders.Run(pool, numTxGroups, time.Duration(delay)*time.Second, func(i int, acc *senders.Account) error {
+		var (
+			addr       = common.HexToAddress(acc.Address)
+			nonce      = acc.Nonce
+			rawTxs     = make([]string, len(endpoints))
+			groupStart = time.Now()
+			group      = &groupResult{
+				Sender:       acc.Address,
+				Nonce:        nonce,
+				Transactions: make(map[string]string),
+				Responses:    make(map[string]string),
+			}
+		)
+
+		// The senders share the output, which is written under the lock.
+		mu.Lock()
+		fmt.Fprintf(out, "Sending tx group %d from %s\n", i, acc.Address)
+		mu.Unlock()
+
+		for j, e := range endpoints {
+			// Every endpoint gets a different transaction, so the landed one identifies it.
+			signedTx, err := types.SignTx(fees.NewTx(chain, nonce, &addr, gasLimit, txfee.GroupData(j)), signer, acc.Key)
+			if err != nil {
+				return err
+			}
+
+			if rawTxs[j], err = rpc.EncodeTx(signedTx); err != nil {
+				return err
+			}
+			group.Transactions[e.name] = signedTx.Hash().Hex()
+		}
+
+		head, err := rpc.BlockNumber(status)
+		if err != nil {
+			return err
+		}
+
+		var (
+			submitted = time.Now()
+			responses = make([][]byte, len(endpoints))
+			wg        sync.WaitGroup
+		)
+
+		for j, e := range endpoints {
+			wg.Add(1)
+			go func(j int, e *endpoint) {
+				defer wg.Done()
+				responses[j] = e.send(rawTxs[j])
+			}(j, e)
+		}
+		wg.Wait()
+		tracker.Track(i, acc.Address, nonce, group.Transactions, head, submitted)
+
+		group.start, group.end = groupStart, time.Now()
+
+		mu.Lock()
+		for j, e := range endpoints {
+			group.Responses[e.name] = string(responses[j])
+			fmt.Fprintf(out, "%s response: %s\n", e.name, responses[j])
+		}
+		groups[i] = group
+		mu.Unlock()
+
+		time.Sleep(s.sendWait)
+
+		return nil
+	})
+	if err != nil {
+		tracker.Stop()
+		return err
+	}
+
+	fmt.Fprintf(out, "Waiting up to %d sec for the transactions to be confirmed.\n", confirmationTimeout)
+	if !tracker.Wait(time.Duration(confirmationTimeout) * time.Second) {
+		fmt.Fprintf(out, "%d transactions are still pending.\n", tracker.Pending())
+	}
+
+	var (
+		inclusions = tracker.Stop()
+		wins       = make(map[string]int)
+		winRates   = make(map[string]float64)
+	)
+
+	for groupNum, inc := range inclusions {
+		wins[inc.Endpoint]++
+		groups[groupNum].MinedBy = inc.Endpoint
+		groups[groupNum].Inclusion = inc
+	}
+
+	fmt.Fprintf(out, "\n----------------------------------------------------------------\n"+
+		"Sent %d groups of transactions to %d endpoints,\n"+
+		"%d of them have been confirmed:\n",
+		numTxGroups, len(endpoints), len(inclusions))
+
+	for _, e := range endpoints {
+		if len(inclusions) > 0 {
+			winRates[e.name] = float64(wins[e.name]) / float64(len(inclusions)) * 100
+		}
+
+		fmt.Fprintf(out, "Number of confirmed transactions is %d (%.1f%%) for %s endpoint %s\n",
+			wins[e.name], winRates[e.name], e.typ, e.name)
+	}
+
+	inclusionByEndpoint := inclusion.Aggregate(inclusions)
+	fmt.Fprint(out, inclusion.Format(inclusionByEndpoint))
+
+	for i := 1; i <= numTxGroups; i++ {
+		rep.AddInterval(i, groups[i].start, groups[i].end, groups[i])
+	}
+	rep.SetAggregate(&summary{
+		Groups:              numTxGroups,
+		Confirmed:           len(inclusions),
+		ConfirmedByEndpoint: wins,
+		WinRateByEndpoint:   winRates,
+		InclusionByEndpoint: inclusionByEndpoint,
+	})
+
+	return rep.Write()
+}
+
+func openConnection(uri, authHeader string) (rpc.Client, error) {
+	log.Debugf("initiating connection to %s", uri)
+	conn, err := rpc.Dial(uri, authHeader)
+	if err != nil {
+		return nil, fmt.Errorf("cannot establish connection to %s: %v", uri, err)
+	}
+
+	log.Debugf("connection to %s established", uri)
+	return conn, nil
+}
+
+func closeConnection(conn rpc.Client, uri string) {
+	if err := conn.Close(); err != nil {
+		log.Errorf("cannot close connection to %s: %v", uri, err)
+	}
+	log.Debugf("connection to %s was closed", uri)
+}
@@ -0,0 +1,354 @@
+package race
+
+import (
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"performance/internal/pkg/flags"
+	"performance/internal/pkg/mock"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/urfave/cli/v2"
+)
+
+const testPrivateKey = "0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+// senderFlags are the flags shared by all commands of the service.
+var senderFlags = []cli.Flag{
+	flags.BXEndpoint,
+	flags.BXAuthHeader,
+	flags.NetworkName,
+	flags.SenderPrivateKey,
+	flags.SenderKeystore,
+	flags.SenderMnemonicFile,
+	flags.DerivationPath,
+	flags.SendersKeystoreDir,
+	flags.SendersMnemonicFile,
+	flags.NumSenders,
+	flags.SenderPasswordFile,
+	flags.MnemonicPassphrase,
+	flags.ChainID,
+	flags.NumTxGroups,
+	flags.GasPrice,
+	flags.TxType,
+	flags.MaxFee,
+	flags.MaxPriorityFee,
+	flags.Confirmations,
+	flags.ConfirmationTimeout,
+	flags.Delay,
+}
+
+// withFlags returns the shared flags followed by the endpoint flags of a command.
+func withFlags(endpointFlags ...cli.Flag) []cli.Flag {
+	return append(endpointFlags, senderFlags...)
+}
+
+func newService() *RaceService {
+	return &RaceService{
+		sendWait:     10 * time.Millisecond,
+		headInterval: 10 * time.Millisecond,
+	}
+}
+
+func TestRace(t *testing.T) {
+	gateway, node, secondNode := mock.NewGateway(), mock.NewNode(), mock.NewNode()
+	defer gateway.Close()
+	defer node.Close()
+	defer secondNode.Close()
+
+	// Transactions of the gateway win the race, the first node is used to check status.
+	node.SetAccount(5, big.NewInt(params.Ether))
+	gateway.OnRawTransaction(func(tx *types.Transaction) {
+		node.Mine(tx, node.BlockNumber()+1)
+		node.NewHead()
+	})
+
+	var res summary
+	err := <-mock.RunCommand(t, newService().Run, withFlags(flags.RaceEndpoint, flags.StatusEndpoint), &res,
+		"--endpoint", "gw="+gateway.URL()+",type=bloxroute,network=BSC-Mainnet",
+		"--endpoint", "node="+node.WSURL()+",type=evm-ws",
+		"--endpoint", "second="+secondNode.HTTPURL()+",type=evm-http",
+		"--sender-private-key", testPrivateKey,
+		"--num-tx-groups", "2",
+		"--gas-price", "10",
+		"--delay", "0",
+		"--confirmation-timeout", "5",
+	)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	if res.Groups != 2 || res.Confirmed != 2 || res.ConfirmedByEndpoint["gw"] != 2 ||
+		res.WinRateByEndpoint["gw"] != 100 || res.WinRateByEndpoint["node"] != 0 {
+		t.Fatalf("expected both groups to be won by the gateway, got %+v", res)
+	}
+
+	// Every endpoint got its own transaction of each group.
+	data := make(map[string]struct{})
+	for _, sent := range [][]*types.Transaction{gateway.Sent(), node.Sent(), secondNode.Sent()} {
+		if len(sent) != 2 {
+			t.Fatalf("expected 2 transactions to be sent, got %d", len(sent))
+		}
+
+		for i, tx := range sent {
+			if tx.Nonce() != uint64(5+i) {
+				t.Errorf("expected nonce %d, got %d", 5+i, tx.Nonce())
+			}
+			if len(tx.Data()) != 4 {
+				t.Errorf("expected 4 bytes of calldata, got %#x", tx.Data())
+			}
+			data[string(tx.Data())] = struct{}{}
+		}
+	}
+
+	if len(data) != 3 {
+		t.Errorf("expected distinct calldata for 3 endpoints, got %d", len(data))
+	}
+}
+
+func TestTxSpeed(t *testing.T) {
+	gateway, node := mock.NewGateway(), mock.NewNode()
+	defer gateway.Close()
+	defer node.Close()
+
+	node.SetAccount(5, big.NewInt(params.Ether))
+	gateway.OnRawTransaction(func(tx *types.Transaction) {
+		node.Mine(tx, node.BlockNumber()+1)
+		node.NewHead()
+	})
+
+	var res summary
+	err := <-mock.RunCommand(t, newService().RunTxSpeed, withFlags(flags.NodeWSEndpoint), &res,
+		"--node-ws-endpoint", node.WSURL(),
+		"--blxr-endpoint", gateway.URL(),
+		"--sender-private-key", testPrivateKey,
+		"--num-tx-groups", "2",
+		"--gas-price", "10",
+		"--delay", "0",
+		"--confirmation-timeout", "5",
+	)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	if res.Groups != 2 || res.Confirmed != 2 || res.ConfirmedByEndpoint[gateway.URL()] != 2 {
+		t.Fatalf("expected both groups to be confirmed for the gateway, got %+v", res)
+	}
+
+	// Each group was mined as the only transaction of the block following submission.
+	if inc := res.InclusionByEndpoint[gateway.URL()]; inc == nil || inc.Included != 2 ||
+		inc.AvgBlocksToInclusion != 1 || inc.AvgTxIndex != 0 {
+		t.Errorf("unexpected inclusion stats of the gateway: %+v", inc)
+	}
+
+	for _, sent := range [][]*types.Transaction{gateway.Sent(), node.Sent()} {
+		if len(sent) != 2 {
+			t.Fatalf("expected 2 transactions to be sent, got %d", len(sent))
+		}
+
+		for i, tx := range sent {
+			if tx.Nonce() != uint64(5+i) {
+				t.Errorf("expected nonce %d, got %d", 5+i, tx.Nonce())
+			}
+
+			if tx.Type() != types.LegacyTxType || tx.GasPrice().Cmp(big.NewInt(10*params.GWei)) != 0 {
+				t.Errorf("unexpected gas price of transaction type %d: %s", tx.Type(), tx.GasPrice())
+			}
+		}
+	}
+}
+
+func TestTxSpeedDynamicFees(t *testing.T) {
+	gateway, node := mock.NewGateway(), mock.NewNode()
+	defer gateway.Close()
+	defer node.Close()
+
+	node.SetAccount(5, big.NewInt(params.Ether))
+	node.SetFeeHistory(
+		[]*big.Int{big.NewInt(10 * params.GWei), big.NewInt(12 * params.GWei)},
+		[]*big.Int{big.NewInt(2 * params.GWei)},
+	)
+	gateway.OnRawTransaction(func(tx *types.Transaction) {
+		node.Mine(tx, node.BlockNumber()+1)
+		node.NewHead()
+	})
+
+	var res summary
+	err := <-mock.RunCommand(t, newService().RunTxSpeed, withFlags(flags.NodeWSEndpoint), &res,
+		"--node-ws-endpoint", node.WSURL(),
+		"--blxr-endpoint", gateway.URL(),
+		"--sender-private-key", testPrivateKey,
+		"--num-tx-groups", "2",
+		"--tx-type", "dynamic",
+		"--delay", "0",
+		"--confirmation-timeout", "5",
+	)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	if res.Groups != 2 || res.Confirmed != 2 {
+		t.Fatalf("expected both groups to be confirmed, got %+v", res)
+	}
+
+	for _, sent := range [][]*types.Transaction{gateway.Sent(), node.Sent()} {
+		if len(sent) != 2 {
+			t.Fatalf("expected 2 transactions to be sent, got %d", len(sent))
+		}
+
+		for _, tx := range sent {
+			// The max fee allows the base fee of the next block to double.
+			if tx.Type() != types.DynamicFeeTxType ||
+				tx.GasTipCap().Cmp(big.NewInt(2*params.GWei)) != 0 ||
+				tx.GasFeeCap().Cmp(big.NewInt(26*params.GWei)) != 0 {
+				t.Errorf("unexpected fees of transaction type %d: tip cap %s, fee cap %s",
+					tx.Type(), tx.GasTipCap(), tx.GasFeeCap())
+			}
+		}
+	}
+}
+
+func TestNodeTxSpeed(t *testing.T) {
+	transports := map[string]struct {
+		run      func(s *RaceService, c *cli.Context) error
+		endpoint func(n *mock.Node) string
+		flags    []cli.Flag
+	}{
+		"websocket": {(*RaceService).RunNodeTxSpeed, (*mock.Node).WSURL,
+			[]cli.Flag{flags.NodeWSEndpoint, flags.SecondNodeWSEndpoint}},
+		"ipc": {(*RaceService).RunNodeTxSpeed, (*mock.Node).IPCPath,
+			[]cli.Flag{flags.NodeWSEndpoint, flags.SecondNodeWSEndpoint}},
+		"http": {(*RaceService).RunHTTPNodeTxSpeed, (*mock.Node).HTTPURL,
+			[]cli.Flag{flags.NodeEndpoint, flags.SecondNodeEndpoint}},
+	}
+
+	for name, tc := range transports {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			node, secondNode := mock.NewNode(), mock.NewNode()
+			defer node.Close()
+			defer secondNode.Close()
+
+			// Transactions of the second node win the race, the first node is used to check status.
+			node.SetAccount(5, big.NewInt(params.Ether))
+			secondNode.OnRawTransaction(func(tx *types.Transaction) {
+				node.Mine(tx, node.BlockNumber()+1)
+				node.NewHead()
+			})
+
+			var res summary
+			err := <-mock.RunCommand(t, func(c *cli.Context) error { return tc.run(newService(), c) }, withFlags(tc.flags...), &res,
+				"--"+tc.flags[0].Names()[0], tc.endpoint(node),
+				"--"+tc.flags[1].Names()[0], tc.endpoint(secondNode),
+				"--sender-private-key", testPrivateKey,
+				"--num-tx-groups", "2",
+				"--gas-price", "10",
+				"--delay", "0",
+				"--confirmation-timeout", "5",
+			)
+			if err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+
+			if res.Groups != 2 || res.Confirmed != 2 || res.ConfirmedByEndpoint[tc.endpoint(secondNode)] != 2 {
+				t.Fatalf("expected both groups to be confirmed for the second node, got %+v", res)
+			}
+		})
+	}
+}
+
+func TestRaceSenderPool(t *testing.T) {
+	gateway, node := mock.NewGateway(), mock.NewNode()
+	defer gateway.Close()
+	defer node.Close()
+
+	node.SetAccount(0, big.NewInt(params.Ether))
+	gateway.OnRawTransaction(func(tx *types.Transaction) {
+		node.Mine(tx, node.BlockNumber()+1)
+		node.NewHead()
+	})
+
+	mnemonicFile := filepath.Join(t.TempDir(), "mnemonic")
+	if err := ioutil.WriteFile(mnemonicFile, []byte("test test test test test test test test test test test junk"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var res summary
+	err := <-mock.RunCommand(t, newService().RunTxSpeed, withFlags(flags.NodeWSEndpoint), &res,
+		"--node-ws-endpoint", node.WSURL(),
+		"--blxr-endpoint", gateway.URL(),
+		"--senders-mnemonic-file", mnemonicFile,
+		"--num-senders", "2",
+		"--num-tx-groups", "4",
+		"--gas-price", "10",
+		"--delay", "0",
+		"--confirmation-timeout", "5",
+	)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	if res.Groups != 4 || res.Confirmed != 4 {
+		t.Fatalf("expected all groups to be confirmed, got %+v", res)
+	}
+
+	// Each sender sent two groups with consecutive nonces.
+	nonces := make(map[string][]uint64)
+	for _, tx := range gateway.Sent() {
+		from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonces[from.Hex()] = append(nonces[from.Hex()], tx.Nonce())
+	}
+
+	if len(nonces) != 2 {
+		t.Fatalf("expected 2 senders, got %v", nonces)
+	}
+
+	for sender, sent := range nonces {
+		if len(sent) != 2 || sent[0] != 0 || sent[1] != 1 {
+			t.Errorf("unexpected nonces of sender %s: %v", sender, sent)
+		}
+	}
+}
+
+func TestParseEndpoints(t *testing.T) {
+	endpoints, err := parseEndpoints([]string{
+		"gw=wss://gateway/ws", "type=bloxroute", "network=BSC-Mainnet",
+		"node=ws://node:8546", "type=evm-ws",
+		"http=http://node:8545", "type=evm-http", "auth=secret",
+	}, "Mainnet", "default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(endpoints) != 3 {
+		t.Fatalf("expected 3 endpoints, got %d", len(endpoints))
+	}
+
+	if gw := endpoints[0]; gw.typ != endpointTypeBloxroute || gw.network != "BSC-Mainnet" || gw.authHeader != "default" {
+		t.Errorf("unexpected gateway endpoint %+v", gw)
+	}
+
+	if http := endpoints[2]; http.typ != endpointTypeEvmHTTP || http.authHeader != "secret" {
+		t.Errorf("unexpected http endpoint %+v", http)
+	}
+
+	for _, values := range [][]string{
+		{"gw=wss://gateway/ws", "type=bloxroute"},
+		{"type=bloxroute", "gw=wss://gateway/ws"},
+		{"gw=wss://gateway/ws", "type=bloxroute", "node=ws://node:8546"},
+		{"gw=wss://gateway/ws", "type=bloxroute", "gw=ws://node:8546", "type=evm-ws"},
+		{"gw=wss://gateway/ws", "type=grpc", "node=ws://node:8546", "type=evm-ws"},
+		{"gw=wss://gateway/ws", "type=bloxroute", "node=http://node:8545", "type=evm-ws"},
+		{"gw=wss://gateway/ws", "type=bloxroute", "node=ws://node:8546", "type=evm-http"},
+	} {
+		if _, err := parseEndpoints(values, "", ""); err == nil {
+			t.Errorf("expected error for %q", values)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package scenario
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"performance/internal/pkg/config"
+	"performance/internal/pkg/flags"
+	"sort"
+
+	"github.com/urfave/cli/v2"
+)
+
+// commandName is the name of the command which runs scenarios, which cannot be run by a
+// scenario itself.
+const commandName = "scenario"
+
+// ScenarioService represents a service which runs the commands described by the named
+// scenarios of a config file.
+type ScenarioService struct {
+	run func(args []string) error
+	out io.Writer
+}
+
+// NewScenarioService creates and initializes ScenarioService instance. The run function
+// runs a command of the application with the arguments, starting with the command name.
+func NewScenarioService(run func(args []string) error) *ScenarioService {
+	return &ScenarioService{
+		run: run,
+		// The report may be written to stdout.
+		out: os.Stderr,
+	}
+}
+
+// Run runs the scenario which name is the first argument. The remaining arguments are the
+// flags of the command which override the values from the config file.
+func (s *ScenarioService) Run(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		return fmt.Errorf("error: scenario name is required")
+	}
+
+	cfg, err := config.Load(c.String(flags.ConfigFile.Name))
+	if err != nil {
+		return err
+	}
+
+	args, err := cfg.Args(name, c.Args().Tail())
+	if err != nil {
+		return err
+	}
+
+	// The command line starts with the words of the command, e.g. 'scenario run'.
+	if args[0] == commandName {
+		return fmt.Errorf("error: scenario %q cannot run the %s command", name, commandName)
+	}
+
+	sc := cfg.Scenarios[name]
+	fmt.Fprintf(s.out, "Running scenario %s with %s command", name, sc.Command)
+	if sc.Description != "" {
+		fmt.Fprintf(s.out, ": %s", sc.Description)
+	}
+	fmt.Fprintln(s.out)
+
+	for _, profile := range profiles(sc) {
+		if region := cfg.Endpoints[profile].Region; region != "" {
+			fmt.Fprintf(s.out, "Endpoint %s is in %s region\n", profile, region)
+		}
+	}
+
+	return s.run(args)
+}
+
+// List prints the names and the descriptions of the scenarios.
+func (s *ScenarioService) List(c *cli.Context) error {
+	cfg, err := config.Load(c.String(flags.ConfigFile.Name))
+	if err != nil {
+		return err
+	}
+
+	for _, name := range cfg.ScenarioNames() {
+		sc := cfg.Scenarios[name]
+		fmt.Fprintf(s.out, "%s\t%s\t%s\n", name, sc.Command, sc.Description)
+	}
+
+	return nil
+}
+
+// profiles returns the sorted names of the endpoint profiles used by the scenario.
+func profiles(sc *config.Scenario) []string {
+	set := make(map[string]struct{})
+	for _, profile := range sc.Endpoints {
+		set[profile] = struct{}{}
+	}
+
+	for _, profile := range sc.Sources {
+		set[profile] = struct{}{}
+	}
+
+	res := make([]string, 0, len(set))
+	for profile := range set {
+		res = append(res, profile)
+	}
+	sort.Strings(res)
+
+	return res
+}
@@ -0,0 +1,137 @@
+package scenario
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"performance/internal/pkg/flags"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+const testConfig = `
+endpoints:
+  gateway:
+    uri: wss://gateway.example.com/ws
+    auth: ${TEST_AUTH}
+    region: eu
+  node:
+    uri: ws://127.0.0.1:8546
+commands:
+  txspeed:
+    flags:
+      delay: 100
+  senders fund:
+    flags:
+      amount: 0.1
+scenarios:
+  speed:
+    command: txspeed
+    description: gateway vs node
+    endpoints:
+      blxr-endpoint: gateway
+      node-ws-endpoint: node
+    flags:
+      num-tx-groups: 5
+  fund:
+    command: senders fund
+    endpoints:
+      node-ws-endpoint: node
+  nested:
+    command: scenario
+  nestedRun:
+    command: scenario run
+`
+
+// runScenario runs the scenario command with the arguments and returns the arguments which
+// reached the run function of the service and the output of the service.
+func runScenario(t *testing.T, args ...string) ([]string, string, error) {
+	path := filepath.Join(t.TempDir(), "evmcompare.yaml")
+	if err := ioutil.WriteFile(path, []byte(testConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		out bytes.Buffer
+		ran []string
+		s   = &ScenarioService{
+			run: func(args []string) error {
+				ran = args
+				return nil
+			},
+			out: &out,
+		}
+		app = &cli.App{
+			Commands: []*cli.Command{{
+				Name:   "run",
+				Flags:  []cli.Flag{flags.ConfigFile},
+				Action: s.Run,
+			}},
+		}
+	)
+
+	err := app.Run(append([]string{"evmcompare", "run", "--config", path}, args...))
+
+	return ran, out.String(), err
+}
+
+func TestRun(t *testing.T) {
+	t.Setenv("TEST_AUTH", "secret")
+
+	args, out, err := runScenario(t, "speed", "--num-tx-groups", "2")
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	expected := []string{
+		"txspeed",
+		"--blxr-endpoint=wss://gateway.example.com/ws",
+		"--blxr-auth-header=secret",
+		"--node-ws-endpoint=ws://127.0.0.1:8546",
+		"--delay=100",
+		"--num-tx-groups", "2",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("unexpected arguments:\n%q\nexpected:\n%q", args, expected)
+	}
+
+	if !strings.Contains(out, "gateway vs node") || !strings.Contains(out, "Endpoint gateway is in eu region") {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
+
+func TestRunSubcommand(t *testing.T) {
+	t.Setenv("TEST_AUTH", "secret")
+
+	args, _, err := runScenario(t, "fund")
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	expected := []string{
+		"senders", "fund",
+		"--node-ws-endpoint=ws://127.0.0.1:8546",
+		"--amount=0.1",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("unexpected arguments:\n%q\nexpected:\n%q", args, expected)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	t.Setenv("TEST_AUTH", "secret")
+
+	for _, args := range [][]string{nil, {"unknown"}, {"nested"}, {"nestedRun"}} {
+		ran, _, err := runScenario(t, args...)
+		if err == nil {
+			t.Errorf("expected an error for arguments %q", args)
+		}
+
+		if ran != nil {
+			t.Errorf("expected no command to run for arguments %q, got %q", args, ran)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package senderpool
+
+import (
+	"performance/internal/pkg/inclusion"
+	"time"
+)
+
+// transferResult holds the outcome of a transfer between the master account and a sender
+// of the pool.
+type transferResult struct {
+	From      string               `json:"from"`
+	To        string               `json:"to"`
+	Nonce     uint64               `json:"nonce"`
+	Value     string               `json:"value"`
+	TxHash    string               `json:"txHash"`
+	Inclusion *inclusion.Inclusion `json:"inclusion,omitempty"`
+
+	start time.Time
+	end   time.Time
+}
+
+// summary holds the outcome of all transfers.
+type summary struct {
+	Senders   int `json:"senders"`
+	Transfers int `json:"transfers"`
+	Confirmed int `json:"confirmed"`
+}
@@ -0,0 +1,253 @@
+// Package senderpool tops up the senders of a pool from the master account and sweeps
+// their balances back to it.
+package senderpool
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"performance/internal/pkg/flags"
+	"performance/internal/pkg/inclusion"
+	"performance/internal/pkg/keys"
+	"performance/internal/pkg/report"
+	"performance/internal/pkg/rpc"
+	"performance/internal/pkg/senders"
+	"performance/internal/pkg/txfee"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// SenderPoolService represents a service which moves funds between the master account and
+// the senders of a pool.
+type SenderPoolService struct {
+	// Interval between polls of the latest block by clients without subscriptions.
+	headInterval time.Duration
+}
+
+// transfer is a value transfer which is about to be sent.
+type transfer struct {
+	from  *senders.Account
+	to    *senders.Account
+	value *big.Int
+}
+
+// Fund tops up every sender of the pool whose balance is below --amount from the master
+// account.
+func (s *SenderPoolService) Fund(c *cli.Context) error {
+	amount := c.Float64(flags.FundAmount.Name)
+	if amount <= 0 {
+		return fmt.Errorf("error: --%s must be positive", flags.FundAmount.Name)
+	}
+
+	return s.run(c, func(out io.Writer, master *senders.Account, pool []*senders.Account, fees *txfee.Fees) []*transfer {
+		var (
+			target    = txfee.FromEther(amount)
+			transfers []*transfer
+			total     = new(big.Int)
+		)
+
+		for _, acc := range pool {
+			if acc.Balance.Cmp(target) >= 0 {
+				fmt.Fprintf(out, "Sender %s already has %f Coins.\n", acc.Address, txfee.ToEther(acc.Balance))
+				continue
+			}
+
+			value := new(big.Int).Sub(target, acc.Balance)
+			transfers = append(transfers, &transfer{from: master, to: acc, value: value})
+			total.Add(total, value)
+		}
+
+		total.Add(total, fees.MaxCost(len(transfers), params.TxGas))
+		if master.Balance.Cmp(total) < 0 {
+			fmt.Fprintf(out, "Master account %s does not have enough balance to fund %d senders.\n"+
+				"Master's balance is %f Coins,\n"+
+				"while at least %f Coins is required\n",
+				master.Address,
+				len(transfers),
+				txfee.ToEther(master.Balance),
+				txfee.ToEther(total))
+
+			return nil
+		}
+
+		return transfers
+	})
+}
+
+// Sweep transfers the balance of every sender of the pool, less the fee of the transfer,
+// back to the master account.
+func (s *SenderPoolService) Sweep(c *cli.Context) error {
+	return s.run(c, func(out io.Writer, master *senders.Account, pool []*senders.Account, fees *txfee.Fees) []*transfer {
+		var (
+			cost      = fees.MaxCost(1, params.TxGas)
+			transfers []*transfer
+		)
+
+		for _, acc := range pool {
+			if acc.Balance.Cmp(cost) <= 0 {
+				fmt.Fprintf(out, "Sender %s has nothing to sweep.\n", acc.Address)
+				continue
+			}
+
+			transfers = append(transfers, &transfer{from: acc, to: master, value: new(big.Int).Sub(acc.Balance, cost)})
+		}
+
+		return transfers
+	})
+}
+
+// run sends the transfers planned from the refreshed accounts and waits for them to be
+// confirmed.
+func (s *SenderPoolService) run(
+	c *cli.Context,
+	plan func(out io.Writer, master *senders.Account, pool []*senders.Account, fees *txfee.Fees) []*transfer,
+) error {
+	var (
+		chainID             = c.Int(flags.ChainID.Name)
+		confirmations       = c.Int(flags.Confirmations.Name)
+		confirmationTimeout = c.Int(flags.ConfirmationTimeout.Name)
+		nodeEndpoint        = c.String(flags.NodeWSEndpoint.Name)
+	)
+
+	if !senders.IsPool(c) {
+		return fmt.Errorf("error: one of --%s and --%s is required",
+			flags.SendersKeystoreDir.Name, flags.SendersMnemonicFile.Name)
+	}
+
+	rep, err := report.New(c, map[string]string{"node": nodeEndpoint})
+	if err != nil {
+		return err
+	}
+	out := rep.Output()
+
+	key, err := keys.FromFlags(c)
+	if err != nil {
+		return err
+	}
+	master := senders.NewAccount(key)
+
+	pool, err := senders.FromFlags(c)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("initiating connection to %s", nodeEndpoint)
+	conn, err := rpc.Dial(nodeEndpoint, "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Errorf("cannot close connection to %s: %v", nodeEndpoint, err)
+		}
+	}()
+
+	fees, err := txfee.FromFlags(c, conn)
+	if err != nil {
+		return err
+	}
+
+	if err := senders.Refresh(conn, append([]*senders.Account{master}, pool...)); err != nil {
+		return err
+	}
+
+	transfers := plan(out, master, pool, fees)
+
+	var (
+		chain   = big.NewInt(int64(chainID))
+		signer  = fees.Signer(chain)
+		results = make(map[int]*transferResult)
+		tracker = inclusion.NewTracker(conn, append(senders.Addresses(pool), master.Address), confirmations)
+	)
+
+	if err := tracker.Follow(s.headInterval); err != nil {
+		return err
+	}
+
+	for i, t := range transfers {
+		var (
+			id    = i + 1
+			start = time.Now()
+			to    = common.HexToAddress(t.to.Address)
+		)
+
+		signedTx, err := types.SignTx(fees.NewTransfer(chain, t.from.Nonce, &to, t.value), signer, t.from.Key)
+		if err != nil {
+			tracker.Stop()
+			return err
+		}
+
+		head, err := rpc.BlockNumber(conn)
+		if err != nil {
+			tracker.Stop()
+			return err
+		}
+
+		fmt.Fprintf(out, "Transferring %f Coins from %s to %s\n", txfee.ToEther(t.value), t.from.Address, t.to.Address)
+		if err := sendTx(conn, signedTx); err != nil {
+			tracker.Stop()
+			return err
+		}
+		tracker.Track(id, t.from.Address, t.from.Nonce, map[string]string{nodeEndpoint: signedTx.Hash().Hex()}, head, start)
+
+		results[id] = &transferResult{
+			From:   t.from.Address,
+			To:     t.to.Address,
+			Nonce:  t.from.Nonce,
+			Value:  t.value.String(),
+			TxHash: signedTx.Hash().Hex(),
+			start:  start,
+			end:    time.Now(),
+		}
+		t.from.Nonce++
+	}
+
+	fmt.Fprintf(out, "Waiting up to %d sec for the transfers to be confirmed.\n", confirmationTimeout)
+	if !tracker.Wait(time.Duration(confirmationTimeout) * time.Second) {
+		fmt.Fprintf(out, "%d transfers are still pending.\n", tracker.Pending())
+	}
+
+	inclusions := tracker.Stop()
+	for id, inc := range inclusions {
+		results[id].Inclusion = inc
+	}
+
+	fmt.Fprintf(out, "%d of %d transfers have been confirmed.\n", len(inclusions), len(transfers))
+
+	for i := 1; i <= len(transfers); i++ {
+		rep.AddInterval(i, results[i].start, results[i].end, results[i])
+	}
+	rep.SetAggregate(&summary{
+		Senders:   len(pool),
+		Transfers: len(transfers),
+		Confirmed: len(inclusions),
+	})
+
+	return rep.Write()
+}
+
+func sendTx(conn rpc.Client, tx *types.Transaction) error {
+	rawTx, err := rpc.EncodeTx(tx)
+	if err != nil {
+		return err
+	}
+
+	var hash string
+	if err := rpc.Result(conn, &hash, "eth_sendRawTransaction", rawTx); err != nil {
+		return fmt.Errorf("cannot send transaction %s: %v", tx.Hash().Hex(), err)
+	}
+
+	return nil
+}
+
+// NewSenderPoolService creates and initializes SenderPoolService instance.
+func NewSenderPoolService() *SenderPoolService {
+	return &SenderPoolService{
+		headInterval: time.Second,
+	}
+}
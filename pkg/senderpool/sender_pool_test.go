@@ -0,0 +1,135 @@
+package senderpool
+
+import (
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"performance/internal/pkg/flags"
+	"performance/internal/pkg/mock"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	testPrivateKey = "0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+	testMnemonic   = "test test test test test test test test test test test junk"
+)
+
+// testSenders are the first two addresses derived from the test mnemonic.
+var testSenders = []string{
+	"0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266",
+	"0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+}
+
+func run(t *testing.T, action cli.ActionFunc, args ...string) (*mock.Node, *summary) {
+	node := mock.NewNode()
+	t.Cleanup(node.Close)
+
+	// Every account of the mock node has the same balance.
+	node.SetAccount(0, new(big.Int).Mul(big.NewInt(10), big.NewInt(params.Ether)))
+	node.SetFeeHistory(
+		[]*big.Int{big.NewInt(10 * params.GWei), big.NewInt(12 * params.GWei)},
+		[]*big.Int{big.NewInt(2 * params.GWei)},
+	)
+	node.OnRawTransaction(func(tx *types.Transaction) {
+		node.Mine(tx, node.BlockNumber()+1)
+		node.NewHead()
+	})
+
+	mnemonicFile := filepath.Join(t.TempDir(), "mnemonic")
+	if err := ioutil.WriteFile(mnemonicFile, []byte(testMnemonic), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var res summary
+	err := <-mock.RunCommand(t, action, []cli.Flag{
+		flags.NodeWSEndpoint,
+		flags.SenderPrivateKey,
+		flags.SenderKeystore,
+		flags.SenderMnemonicFile,
+		flags.DerivationPath,
+		flags.SendersKeystoreDir,
+		flags.SendersMnemonicFile,
+		flags.NumSenders,
+		flags.SenderPasswordFile,
+		flags.MnemonicPassphrase,
+		flags.FundAmount,
+		flags.ChainID,
+		flags.GasPrice,
+		flags.TxType,
+		flags.MaxFee,
+		flags.MaxPriorityFee,
+		flags.Confirmations,
+		flags.ConfirmationTimeout,
+	}, &res, append([]string{
+		"--node-ws-endpoint", node.WSURL(),
+		"--sender-private-key", testPrivateKey,
+		"--senders-mnemonic-file", mnemonicFile,
+		"--num-senders", "2",
+		"--tx-type", "dynamic",
+		"--confirmation-timeout", "5",
+	}, args...)...)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	return node, &res
+}
+
+func TestFund(t *testing.T) {
+	node, res := run(t, NewSenderPoolService().Fund, "--amount", "12")
+
+	if res.Senders != 2 || res.Transfers != 2 || res.Confirmed != 2 {
+		t.Fatalf("expected both senders to be funded, got %+v", res)
+	}
+
+	sent := node.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 transactions to be sent, got %d", len(sent))
+	}
+
+	for i, tx := range sent {
+		if tx.To().Hex() != testSenders[i] || tx.Nonce() != uint64(i) || tx.Gas() != params.TxGas {
+			t.Errorf("unexpected transfer %d to %s with nonce %d", i, tx.To().Hex(), tx.Nonce())
+		}
+
+		if expected := new(big.Int).Mul(big.NewInt(2), big.NewInt(params.Ether)); tx.Value().Cmp(expected) != 0 {
+			t.Errorf("expected a transfer of %s, got %s", expected, tx.Value())
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	node, res := run(t, NewSenderPoolService().Sweep)
+
+	if res.Transfers != 2 || res.Confirmed != 2 {
+		t.Fatalf("expected both senders to be swept, got %+v", res)
+	}
+
+	key, err := crypto.HexToECDSA(testPrivateKey[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	master := crypto.PubkeyToAddress(key.PublicKey)
+
+	sent := node.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 transactions to be sent, got %d", len(sent))
+	}
+
+	balance := new(big.Int).Mul(big.NewInt(10), big.NewInt(params.Ether))
+	for _, tx := range sent {
+		// The balance is swept less the maximum fee of the transfer.
+		var (
+			cost = new(big.Int).Mul(tx.GasFeeCap(), new(big.Int).SetUint64(params.TxGas))
+			left = new(big.Int).Sub(balance, tx.Value())
+		)
+		if *tx.To() != master || left.Cmp(cost) != 0 {
+			t.Errorf("unexpected transfer of %s to %s", tx.Value(), tx.To().Hex())
+		}
+	}
+}